@@ -6,6 +6,10 @@ import AdminPage from './pages/AdminPage';
 import VerifPage from './pages/VerifPage';
 import LoginAdminPage from './pages/LoginAdminPage';
 import RegisterPage from './pages/RegisterPage';
+import ResetPasswordPage from './pages/ResetPasswordPage';
+import ConfirmEmailPage from './pages/ConfirmEmailPage';
+import SSOCallbackPage from './pages/SSOCallbackPage';
+import BulletinPage from './pages/BulletinPage';
 
 function App() {
   return (
@@ -13,6 +17,10 @@ function App() {
       <Routes>
         <Route path="/login" element={<LoginPage />} />
         <Route path="/register" element={<RegisterPage />} />
+        <Route path="/reset-password" element={<ResetPasswordPage />} />
+        <Route path="/confirm-email" element={<ConfirmEmailPage />} />
+        <Route path="/sso-callback" element={<SSOCallbackPage />} />
+        <Route path="/bulletin" element={<BulletinPage />} />
         <Route path="/vote" element={<VotingPage />} />
         <Route path="/admin" element={<AdminPage />} />
         <Route path="/loginadmin" element={<LoginAdminPage />} />
@@ -4,4 +4,19 @@ const api = axios.create({
     baseURL: process.env.REACT_APP_API_URL || 'http://localhost:8080',
 });
 
+// Attach the session token issued at login to every request.
+api.interceptors.request.use((config) => {
+    const saved = localStorage.getItem('user');
+    if (saved) {
+        const { sessionToken } = JSON.parse(saved);
+        if (sessionToken) {
+            config.headers.Authorization = `Bearer ${sessionToken}`;
+        }
+    }
+    return config;
+});
+
+// Roles allowed into the admin dashboard (mirrors models.StaffRoles on the server).
+export const STAFF_ROLES = ['admin', 'verifier', 'auditor', 'observer'];
+
 export default api;
@@ -1,6 +1,7 @@
 import React from "react";
 import { Link, useLocation, useNavigate } from "react-router-dom";
 import { LayoutDashboard, Users, Vote, Settings, LogOut, CheckCircle2 } from "lucide-react";
+import api from "../api";
 
 interface AdminLayoutProps {
   children: React.ReactNode;
@@ -10,7 +11,9 @@ const AdminLayout: React.FC<AdminLayoutProps> = ({ children }) => {
   const location = useLocation();
   const navigate = useNavigate();
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    // End the session server-side; the local copy is dropped either way.
+    await api.post("/logout").catch(() => {});
     localStorage.removeItem("user");
     navigate("/loginadmin");
   };
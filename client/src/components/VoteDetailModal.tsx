@@ -8,7 +8,6 @@ interface VoteDetailModalProps {
         userName: string;
         userNim: string;
         userEmail: string;
-        candidateName: string;
         ktmImage: string;
         selfImage: string;
     } | null;
@@ -49,10 +48,6 @@ const VoteDetailModal: React.FC<VoteDetailModalProps> = ({ isOpen, onClose, vote
                         <label className="text-sm text-gray-400">Email:</label>
                         <p className="text-lg text-white">{vote.userEmail}</p>
                     </div>
-                    <div>
-                        <label className="text-sm text-gray-400">Memilih:</label>
-                        <p className="text-lg font-bold text-blue-400">{vote.candidateName}</p>
-                    </div>
                 </div>
 
                 <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
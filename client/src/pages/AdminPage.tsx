@@ -1,11 +1,11 @@
 import React, { useEffect, useState } from "react";
 import { useNavigate } from "react-router-dom";
-import api from "../api";
+import api, { STAFF_ROLES } from "../api";
 import UserDetailModal from "../components/UserDetailModal";
 import VoteDetailModal from "../components/VoteDetailModal";
 import AdminLayout from "../components/AdminLayout";
 import { useToast } from "../contexts/ToastContext";
-import { RefreshCw, CheckCircle2, XCircle, Plus, Trash2, Eye, EyeOff } from "lucide-react";
+import { RefreshCw, CheckCircle2, XCircle, Plus, Trash2, Eye, EyeOff, Shuffle, Pencil, Undo2, UserMinus } from "lucide-react";
 
 interface User {
   ID: number;
@@ -26,6 +26,18 @@ interface Verification {
   Email: string;
   ProfileImage: string;
   KTMImage: string;
+  onRoll: boolean;
+  rollName?: string;
+  rollCohort?: string;
+  nameMismatch: boolean;
+}
+
+// A line of an election's official voter roll.
+interface RollEntry {
+  ID: number;
+  NIM: string;
+  Name: string;
+  Cohort: string;
 }
 
 interface VoteRequest {
@@ -35,18 +47,115 @@ interface VoteRequest {
   userEmail: string;
   ktmImage: string;
   selfImage: string;
-  candidateName: string;
   rejectionReason?: string; // Added optional field
 }
 
+// One person of a candidate pair, in ballot order.
+interface CandidateMember {
+  ID: number;
+  Position: number;
+  Name: string;
+  NIM: string;
+  Role: string;
+  ImageURL: string;
+}
+
 interface Candidate {
   ID: number;
+  BallotNumber: number | null;
   Name: string;
   Visi: string;
   Misi: string;
   ImageURL: string;
+  Members: CandidateMember[];
+  WithdrawnAt: string | null;
+  WithdrawalReason: string;
 }
 
+// A race or question on the ballot with its options.
+interface Contest {
+  ID: number;
+  Title: string;
+  Kind: "candidates" | "referendum";
+  Method: "plurality" | "irv";
+  MaxChoices: number;
+  KotakKosong: boolean;
+  Candidates: Candidate[];
+}
+
+// Per-contest counts from /admin/results.
+interface ContestResult {
+  contestId: number;
+  title: string;
+  kind: string;
+  method: string;
+  results: { candidateId: number; name: string; imageUrl: string; count: number; withdrawn?: boolean }[];
+  rounds?: RunoffRound[];
+  outcome: { status: "winner" | "runoff" | "void"; winners?: number[]; runoff?: number[]; reason?: string };
+}
+
+// Turnout against the election's quorum, from /admin/results.
+interface Turnout {
+  eligible: number;
+  voted: number;
+  percent: number;
+  minTurnout: number;
+  quorumMet: boolean;
+}
+
+// One counting round of an instant-runoff contest; maps are keyed by candidate ID.
+interface RunoffRound {
+  round: number;
+  counts: Record<string, number>;
+  exhausted: number;
+  elected?: number;
+  eliminated?: number;
+  transfers?: Record<string, number>;
+  exhaustedTransfers?: number;
+}
+
+interface Election {
+  ID: number;
+  Name: string;
+  Phase: string;
+  RegistrationStart: string | null;
+  RegistrationEnd: string | null;
+  StartTime: string | null;
+  EndTime: string | null;
+  VotingMinutes: number;
+  SessionRegrants: number;
+  ShuffleOptions: boolean;
+  KotakKosongPlace: string;
+  NIMPrefixes: string;
+  NIMLength: number;
+  NIMPattern: string;
+  CohortPosition: number;
+  CohortMin: number;
+  CohortMax: number;
+  PublicKey: string;
+  Archived: boolean;
+  MinTurnout: number;
+  MajorityRule: string;
+  KotakKosongPolicy: string;
+  KotakKosongWins: string;
+  WithdrawnVotes: string;
+}
+
+// Election phases in order; the server only moves an election forward.
+const PHASES = ["draft", "registration", "verification", "voting", "counting", "published"];
+
+const emptyElectionForm = {
+  name: "", registrationStart: "", registrationEnd: "", startTime: "", endTime: "", nimPrefixes: "", publicKey: "",
+  votingMinutes: 5, sessionRegrants: 0, shuffleOptions: false, kotakKosongPlace: "last",
+  nimLength: 0, nimPattern: "", cohortPosition: 0, cohortMin: 0, cohortMax: 0,
+  minTurnout: 0, majorityRule: "simple", kotakKosongPolicy: "per_contest", kotakKosongWins: "void",
+  withdrawnVotes: "void",
+};
+
+// Election times are entered in WIB, as datetime-local values.
+const toWIBInput = (iso: string | null) =>
+  iso ? new Date(new Date(iso).getTime() + 7 * 3600 * 1000).toISOString().slice(0, 16) : "";
+
 interface Result {
   CandidateID?: number;
   candidateId?: number;
@@ -69,11 +178,16 @@ const AdminPage = () => {
   // Data States
   // Data States
   const [verifications, setVerifications] = useState<Verification[]>([]);
+  const [roll, setRoll] = useState<{ entries: RollEntry[]; approved: number; frozen: boolean }>({ entries: [], approved: 0, frozen: false });
   const [allUsers, setAllUsers] = useState<User[]>([]); // Data for "all_users"
   const [pendingVotes, setPendingVotes] = useState<VoteRequest[]>([]);
   const [rejectedVotes, setRejectedVotes] = useState<VoteRequest[]>([]); // Added rejected votes state
-  const [results, setResults] = useState<any[]>([]); // Using any to handle inconsistent casing from API if valid
-  const [candidates, setCandidates] = useState<Candidate[]>([]);
+  const [results, setResults] = useState<ContestResult[]>([]); // One table per contest on the ballot
+  const [ballotCount, setBallotCount] = useState(0);
+  const [spoiledCount, setSpoiledCount] = useState(0);
+  const [turnout, setTurnout] = useState<Turnout | null>(null);
+  const [provisional, setProvisional] = useState(true);
+  const [contests, setContests] = useState<Contest[]>([]);
 
 
 
@@ -94,31 +208,85 @@ const AdminPage = () => {
     name: "",
     visi: "",
     misi: "",
+    contestId: "",
+    ballotNumber: "",
   });
   const [candidateImg, setCandidateImg] = useState<File | null>(null);
+  // Chair first, then vice-chair
+  const emptyMembers = (): { name: string; nim: string; role: string; imageUrl?: string }[] => [{ name: "", nim: "", role: "Ketua" }, { name: "", nim: "", role: "Wakil Ketua" }];
+  // Set while the form edits an existing candidate
+  const [editingCandidateId, setEditingCandidateId] = useState<number | null>(null);
+  const [members, setMembers] = useState(emptyMembers());
+  const [memberImgs, setMemberImgs] = useState<(File | null)[]>([]);
   const [submitting, setSubmitting] = useState(false);
 
-  // Election Settings
-  const [electionSettings, setElectionSettings] = useState({
-    startTime: "",
-    endTime: ""
-  });
+  // Elections; every tab shows the selected one
+  const [elections, setElections] = useState<Election[]>([]);
+  const [electionId, setElectionId] = useState<number | null>(null);
+  const [electionForm, setElectionForm] = useState(emptyElectionForm);
   const [showSensitive, setShowSensitive] = useState(false);
   
   // Vote Success Modal State
   const [showSuccessModal, setShowSuccessModal] = useState(false);
-  const [successCandidateName, setSuccessCandidateName] = useState("");
   const [showResults, setShowResults] = useState(true);
 
+  // Tally ceremony
+  const [tally, setTally] = useState<any>(null);
+  const [tallyShare, setTallyShare] = useState("");
+
   useEffect(() => {
-    if (!user || user.Role !== "admin") {
+    if (!user || !STAFF_ROLES.includes(user.Role)) {
       navigate("/loginadmin");
       return;
     }
+    if (electionId === null) return;
     fetchData();
     const interval = setInterval(fetchData, 5000);
     return () => clearInterval(interval);
-  }, [user, navigate, activeTab]);
+  }, [user, navigate, activeTab, electionId]);
+
+  useEffect(() => {
+    fetchElections();
+  }, []);
+
+  useEffect(() => {
+    const selected = elections.find((e) => e.ID === electionId);
+    if (selected) {
+      setElectionForm({
+        name: selected.Name,
+        registrationStart: toWIBInput(selected.RegistrationStart),
+        registrationEnd: toWIBInput(selected.RegistrationEnd),
+        startTime: toWIBInput(selected.StartTime),
+        endTime: toWIBInput(selected.EndTime),
+        votingMinutes: selected.VotingMinutes,
+        sessionRegrants: selected.SessionRegrants,
+        shuffleOptions: selected.ShuffleOptions,
+        kotakKosongPlace: selected.KotakKosongPlace || "last",
+        nimPrefixes: selected.NIMPrefixes,
+        nimLength: selected.NIMLength,
+        nimPattern: selected.NIMPattern,
+        cohortPosition: selected.CohortPosition,
+        cohortMin: selected.CohortMin,
+        cohortMax: selected.CohortMax,
+        publicKey: selected.PublicKey,
+        minTurnout: selected.MinTurnout,
+        majorityRule: selected.MajorityRule,
+        kotakKosongPolicy: selected.KotakKosongPolicy,
+        kotakKosongWins: selected.KotakKosongWins,
+        withdrawnVotes: selected.WithdrawnVotes || "void",
+      });
+    }
+  }, [elections, electionId]);
+
+  const fetchElections = async () => {
+    try {
+      const res = await api.get("/admin/elections");
+      setElections(res.data.elections || []);
+      setElectionId((prev) => prev ?? (res.data.currentId || res.data.elections?.[0]?.ID || null));
+    } catch (err) { console.error(err); }
+  };
+
+  const electionParams = () => ({ params: { electionId } });
 
   const fetchData = () => {
     if (activeTab === "mahasiswa") fetchVerifications();
@@ -127,7 +295,15 @@ const AdminPage = () => {
     if (activeTab === "verifikasi_suara") fetchPendingVotes();
     if (activeTab === "votes_rejected") fetchRejectedVotes();
     if (activeTab === "kandidat") fetchCandidates();
-    if (activeTab === "recap") fetchResults();
+    if (activeTab === "recap") { fetchResults(); fetchTally(); }
+    if (activeTab === "settings") fetchRoll();
+  };
+
+  const fetchRoll = async () => {
+    try {
+      const res = await api.get(`/admin/elections/${electionId}/roll`);
+      setRoll(res.data);
+    } catch (err) { console.error(err); }
   };
 
   const fetchVerifications = async () => {
@@ -138,26 +314,36 @@ const AdminPage = () => {
   };
   const fetchPendingVotes = async () => {
     try {
-      const res = await api.get("/admin/votes/pending");
+      const res = await api.get("/admin/votes/pending", electionParams());
       setPendingVotes(Array.isArray(res.data) ? res.data : []);
     } catch (err) { console.error(err); setPendingVotes([]); }
   };
   const fetchRejectedVotes = async () => {
     try {
-      const res = await api.get("/admin/votes/rejected");
+      const res = await api.get("/admin/votes/rejected", electionParams());
       setRejectedVotes(Array.isArray(res.data) ? res.data : []);
     } catch (err) { console.error(err); setRejectedVotes([]); }
   };
   const fetchResults = async () => {
     try {
-      const res = await api.get("/admin/results");
-      setResults(res.data || []);
+      const res = await api.get("/admin/results", electionParams());
+      setResults(res.data?.contests || []);
+      setBallotCount(res.data?.ballots || 0);
+      setSpoiledCount(res.data?.spoiled || 0);
+      setTurnout(res.data?.turnout || null);
+      setProvisional(res.data?.provisional ?? true);
     } catch (err) { console.error(err); }
   };
+  const fetchTally = async () => {
+    try {
+      const res = await api.get("/admin/tally", electionParams());
+      setTally(res.data);
+    } catch (err) { setTally(null); }
+  };
   const fetchCandidates = async () => {
     try {
-      const res = await api.get("/candidates");
-      setCandidates(res.data || []);
+      const res = await api.get("/contests", electionParams());
+      setContests(res.data || []);
     } catch (err) { console.error(err); }
   };
 
@@ -167,6 +353,7 @@ const AdminPage = () => {
       if (searchQuery) params.append("q", searchQuery);
       if (filterVerification !== "all") params.append("verificationStatus", filterVerification);
       if (filterHasVoted !== "all") params.append("hasVoted", filterHasVoted);
+      if (electionId) params.append("electionId", electionId.toString());
 
       const res = await api.get(`/admin/users?${params.toString()}`);
       setAllUsers(res.data || []);
@@ -181,7 +368,7 @@ const AdminPage = () => {
       }, 500);
       return () => clearTimeout(timer);
     }
-  }, [searchQuery, filterVerification, filterHasVoted, activeTab]);
+  }, [searchQuery, filterVerification, filterHasVoted, activeTab, electionId]);
 
   const handleVerifyUser = async (userId: number, action: string) => {
     try {
@@ -191,16 +378,20 @@ const AdminPage = () => {
     } catch (err) { showError("Action failed"); }
   };
 
+  // Lets a voter whose booth session ran out, e.g. on a dropped connection, vote again.
+  const handleGrantSession = async (userId: number) => {
+    if (!window.confirm("Grant this voter a new voting session?")) return;
+    try {
+      const res = await api.post(`/admin/users/${userId}/voting-session`, {}, { params: { electionId } });
+      success(`New session granted (${res.data.remaining} left for this voter)`);
+    } catch (err: any) { showError(err.response?.data?.error || "Failed to grant session"); }
+  };
+
   const handleVerifyVote = async (voteId: number, action: string) => {
     try {
-      // Find the vote to get details before verifying
-      const vote = pendingVotes.find(v => v.id === voteId);
-      
       await api.post("/admin/votes/verify", { voteId, action });
       
-      if (action === "approve" && vote) {
-        // Show detailed success message for approval
-        setSuccessCandidateName(vote.candidateName);
+      if (action === "approve") {
         setShowSuccessModal(true);
       } else {
         success(`Vote ${action}ed successfully`);
@@ -216,7 +407,7 @@ const AdminPage = () => {
       await api.delete(`/admin/candidates/${id}`);
       success("Candidate deleted");
       fetchCandidates();
-    } catch (err) { showError("Delete failed"); }
+    } catch (err: any) { showError(err.response?.data?.error || "Delete failed"); }
   };
 
   const handleAddCandidate = async (e: React.FormEvent) => {
@@ -226,30 +417,232 @@ const AdminPage = () => {
     data.append("name", newCandidate.name);
     data.append("visi", newCandidate.visi);
     data.append("misi", newCandidate.misi);
+    if (newCandidate.contestId && !editingCandidateId) data.append("contestId", newCandidate.contestId);
+    // An empty number clears it when editing.
+    if (newCandidate.ballotNumber || editingCandidateId) data.append("ballotNumber", newCandidate.ballotNumber);
+    if (electionId) data.append("electionId", electionId.toString());
     if (candidateImg) data.append("image", candidateImg);
+    // Members without a name are left out, e.g. the vice-chair of a single candidate.
+    const listed = members.map((m, i) => ({ ...m, img: memberImgs[i] })).filter(m => m.name.trim() !== "");
+    if (listed.length > 0) {
+      data.append("members", JSON.stringify(listed.map(m => ({ name: m.name, nim: m.nim, role: m.role, imageUrl: m.imageUrl }))));
+      listed.forEach((m, i) => { if (m.img) data.append(`member_image_${i}`, m.img); });
+    } else if (editingCandidateId) {
+      data.append("members", "[]");
+    }
 
     try {
-      await api.post("/admin/candidates", data, {
-        headers: { "Content-Type": "multipart/form-data" },
-      });
-      success("Candidate added!");
-      setNewCandidate({ name: "", visi: "", misi: "" });
+      const config = { headers: { "Content-Type": "multipart/form-data" } };
+      if (editingCandidateId) {
+        await api.put(`/admin/candidates/${editingCandidateId}`, data, config);
+        success("Candidate updated");
+      } else {
+        await api.post("/admin/candidates", data, config);
+        success("Candidate added!");
+      }
+      setEditingCandidateId(null);
+      setNewCandidate({ name: "", visi: "", misi: "", contestId: "", ballotNumber: "" });
       setCandidateImg(null);
+      setMembers(emptyMembers());
+      setMemberImgs([]);
       setIsAddCandidateOpen(false);
       fetchCandidates();
-    } catch (err) { showError("Failed to add candidate"); }
+    } catch (err: any) { showError(err.response?.data?.error || "Failed to add candidate"); }
     finally { setSubmitting(false); }
   };
 
-  const handleSaveSettings = async (e: React.FormEvent) => {
+  const handleEditCandidate = (c: Candidate, contestId: number) => {
+    setEditingCandidateId(c.ID);
+    setNewCandidate({ name: c.Name, visi: c.Visi, misi: c.Misi, contestId: contestId.toString(), ballotNumber: c.BallotNumber ? c.BallotNumber.toString() : "" });
+    setMembers(c.Members && c.Members.length > 0
+      ? c.Members.map(m => ({ name: m.Name, nim: m.NIM, role: m.Role, imageUrl: m.ImageURL }))
+      : emptyMembers());
+    setMemberImgs([]);
+    setCandidateImg(null);
+    setIsAddCandidateOpen(true);
+  };
+
+  const closeCandidateForm = () => {
+    setIsAddCandidateOpen(false);
+    setEditingCandidateId(null);
+    setNewCandidate({ name: "", visi: "", misi: "", contestId: "", ballotNumber: "" });
+    setMembers(emptyMembers());
+    setMemberImgs([]);
+  };
+
+  // A withdrawn candidate stays in the results; the election decides whether ballots already cast for them count.
+  const handleWithdrawCandidate = async (c: Candidate) => {
+    const reason = window.prompt(`Withdraw ${c.Name}? They will take no new votes. Reason (optional):`);
+    if (reason === null) return;
+    try {
+      await api.post(`/admin/candidates/${c.ID}/withdraw`, { reason });
+      success("Candidate withdrawn");
+      fetchCandidates();
+    } catch (err: any) { showError(err.response?.data?.error || "Failed to withdraw candidate"); }
+  };
+
+  const handleReinstateCandidate = async (id: number) => {
+    try {
+      await api.post(`/admin/candidates/${id}/reinstate`);
+      success("Candidate reinstated");
+      fetchCandidates();
+    } catch (err: any) { showError(err.response?.data?.error || "Failed to reinstate candidate"); }
+  };
+
+  const updateMember = (i: number, field: "name" | "nim" | "role", value: string) => {
+    setMembers(members.map((m, j) => (j === i ? { ...m, [field]: value } : m)));
+  };
+
+  const setMemberImg = (i: number, file: File | null) => {
+    const next = [...memberImgs];
+    next[i] = file;
+    setMemberImgs(next);
+  };
+
+  // Numbers every candidate by lottery in two steps. Committing publishes the hash of a secret seed; drawing
+  // then mixes in entropy from the witnesses and reveals the seed, so the draw can be checked.
+  const handleDrawBallotNumbers = async () => {
+    if (!electionId) return;
+    try {
+      const draws = await api.get(`/ballot-draws?electionId=${electionId}`);
+      const committed = draws.data.find((d: any) => d.status === "committed");
+      if (committed) {
+        const witness = window.prompt(`Draw committed with seed hash ${committed.seedHash.slice(0, 12)}…\nEnter the witness entropy called out at the draw (e.g. dice rolls by each candidate):`);
+        if (!witness || !witness.trim()) return;
+        const res = await api.post(`/admin/elections/${electionId}/ballot-draw`, { witness });
+        success(`Ballot numbers drawn (seed ${res.data.seed.slice(0, 12)}…)`);
+        fetchCandidates();
+        return;
+      }
+      const redraw = draws.data.length > 0;
+      if (!window.confirm(redraw ? "Ballot numbers were already drawn. Commit a redraw? A second admin must complete it." : "Commit a ballot number draw? Publish its seed hash before collecting the witness entropy.")) return;
+      const res = await api.post(`/admin/elections/${electionId}/ballot-draw/commit`, { redraw });
+      success(`Draw committed (seed hash ${res.data.seedHash.slice(0, 12)}…). Publish it, then draw with the witness entropy.`);
+    } catch (err: any) { showError(err.response?.data?.error || "Failed to draw ballot numbers"); }
+  };
+
+  // A referendum gets its Setuju / Tidak Setuju options from the server.
+  const handleAddContest = async (kind: "candidates" | "referendum") => {
+    const title = window.prompt(kind === "referendum" ? "Referendum question" : "Name of the contest, e.g. Senat");
+    if (!title) return;
+    let method = "plurality";
+    let maxChoices = 1;
+    let kotakKosong = false;
+    if (kind === "candidates") {
+      if (window.confirm("Let voters rank the candidates and count with instant-runoff?")) method = "irv";
+      maxChoices = parseInt(window.prompt(method === "irv" ? "How many preferences may a voter rank?" : "How many candidates may a voter choose?", "1") || "1", 10) || 1;
+      kotakKosong = window.confirm("Offer Kotak Kosong in this contest?");
+    }
+    try {
+      await api.post("/admin/contests", { title, kind, method, maxChoices, kotakKosong, position: contests.length + 1 }, electionParams());
+      success("Contest added");
+      fetchCandidates();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to add contest");
+    }
+  };
+
+  const handleDeleteContest = async (id: number) => {
+    if (!window.confirm("Delete this contest and all its candidates?")) return;
+    try {
+      await api.delete(`/admin/contests/${id}`);
+      success("Contest deleted");
+      fetchCandidates();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Delete failed");
+    }
+  };
+
+  const handleSaveElection = async (e: React.FormEvent) => {
     e.preventDefault();
     try {
-      await api.post("/admin/settings", electionSettings);
-      success("Settings saved successfully");
-    } catch (err) {
-      showError("Failed to save settings");
+      await api.put(`/admin/elections/${electionId}`, electionForm);
+      success("Election saved successfully");
+      fetchElections();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to save election");
     }
-  }
+  };
+
+  const handleCreateElection = async () => {
+    const name = window.prompt("Name of the new election");
+    if (!name) return;
+    try {
+      const res = await api.post("/admin/elections", { ...emptyElectionForm, name });
+      success("Election created");
+      setElectionId(res.data.ID);
+      fetchElections();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to create election");
+    }
+  };
+
+  const handleArchiveElection = async (archived: boolean) => {
+    if (archived && !window.confirm("Archive this election? Voters will no longer see it.")) return;
+    try {
+      await api.post(`/admin/elections/${electionId}/archive`, { archived });
+      success(archived ? "Election archived" : "Election restored");
+      fetchElections();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to update election");
+    }
+  };
+
+  const handleAdvancePhase = async (phase: string) => {
+    if (!window.confirm(`Move this election to the ${phase} phase? This cannot be undone.`)) return;
+    try {
+      await api.post(`/admin/elections/${electionId}/phase`, { phase });
+      success(`Election moved to ${phase}`);
+      fetchElections();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to change phase");
+    }
+  };
+
+  // Replaces the election's voter roll with a CSV of NIM, name and cohort.
+  const handleImportRoll = async (e: React.ChangeEvent<HTMLInputElement>) => {
+    const file = e.target.files?.[0];
+    e.target.value = "";
+    if (!file) return;
+    const data = new FormData();
+    data.append("file", file);
+    try {
+      const res = await api.post(`/admin/elections/${electionId}/roll`, data);
+      success(res.data.message || "Roll imported");
+      fetchRoll();
+    } catch (err: any) {
+      const lines: string[] = err.response?.data?.lines || [];
+      showError([err.response?.data?.error || "Import failed", ...lines.slice(0, 5)].join("; "));
+    }
+  };
+
+  const handleDeleteRoll = async () => {
+    if (!window.confirm("Delete the voter roll? Eligibility falls back to NIM prefixes.")) return;
+    try {
+      await api.delete(`/admin/elections/${electionId}/roll`);
+      success("Roll deleted");
+      fetchRoll();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Delete failed");
+    }
+  };
+
+  const selectedElection = elections.find((e) => e.ID === electionId);
+  const nextPhase = selectedElection && PHASES[PHASES.indexOf(selectedElection.Phase) + 1];
+
+  const handleSubmitShare = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      const res = await api.post("/admin/tally/shares", { share: tallyShare.trim() }, electionParams());
+      success(res.data.message || "Key share accepted");
+      setTallyShare("");
+      fetchTally();
+      fetchResults();
+    } catch (err: any) {
+      showError(err.response?.data?.error || "Failed to submit key share");
+      fetchTally();
+    }
+  };
 
   const getImageSrc = (path: string) => {
     if (!path) return "";
@@ -289,6 +682,16 @@ const AdminPage = () => {
           </p>
         </div>
         <div className="flex gap-3">
+          <select
+            value={electionId ?? ""}
+            onChange={(e) => setElectionId(Number(e.target.value))}
+            className="bg-white border border-slate-200 rounded-xl px-3 text-sm font-medium text-slate-700 shadow-sm"
+            title="Election"
+          >
+            {elections.map((e) => (
+              <option key={e.ID} value={e.ID}>{e.Name} ({e.Phase})</option>
+            ))}
+          </select>
           <div className="bg-white border border-slate-200 rounded-xl p-1 flex shadow-sm">
             {["mahasiswa", "all_users", "verifikasi_suara", "votes_rejected", "kandidat", "recap", "settings"].map(tab => (
               <button
@@ -334,60 +737,171 @@ const AdminPage = () => {
             </button>
           </div>
 
+          {tally && (
+            <div className="bg-white border border-slate-200 rounded-2xl p-6 shadow-sm space-y-4">
+              <div className="flex flex-wrap justify-between items-center gap-2">
+                <h3 className="font-bold text-lg text-slate-900">Tally Ceremony</h3>
+                <span className="text-sm text-slate-500">
+                  {tally.decryptedBallots} decrypted / {tally.encryptedBallots} still encrypted
+                </span>
+              </div>
+              {tally.pendingReview > 0 && (
+                <p className="text-sm text-amber-600">
+                  {tally.pendingReview} votes still await review. The last key share is refused until they are approved or rejected.
+                </p>
+              )}
+              {!tally.publicKeyConfigured ? (
+                <p className="text-sm text-red-600">The election public key is not configured.</p>
+              ) : !tally.electionClosed ? (
+                <p className="text-sm text-slate-500">Ballots stay encrypted until the election closes. Key holders can submit their shares afterwards.</p>
+              ) : tally.encryptedBallots === 0 ? (
+                <p className="text-sm text-emerald-600">All ballots have been decrypted.</p>
+              ) : (
+                <>
+                  <p className="text-sm text-slate-500">
+                    Shares submitted: {tally.sharesSubmitted}{tally.sharesRequired > 0 && ` of ${tally.sharesRequired}`}
+                  </p>
+                  <form onSubmit={handleSubmitShare} className="flex gap-2">
+                    <input
+                      type="password"
+                      value={tallyShare}
+                      onChange={(e) => setTallyShare(e.target.value)}
+                      placeholder="Paste your key share"
+                      className="flex-1 px-4 py-2 border border-slate-200 rounded-lg font-mono text-sm"
+                      required
+                    />
+                    <button type="submit" className="px-4 py-2 bg-emerald-600 text-white rounded-lg font-medium hover:bg-emerald-700">
+                      Submit Share
+                    </button>
+                  </form>
+                </>
+              )}
+            </div>
+          )}
+
           {showResults ? (
             <>
               <div className="grid grid-cols-1 md:grid-cols-3 gap-6">
                 <StatCard
                   label="Total Votes Cast"
-                  value={results.reduce((acc, curr) => acc + (curr.Count || curr.count || 0), 0)}
+                  value={ballotCount}
                   color="text-emerald-600"
                 />
+                <StatCard
+                  label="Suara Hangus"
+                  value={spoiledCount}
+                  color="text-red-500"
+                />
+                {turnout && (
+                  <StatCard
+                    label={turnout.minTurnout > 0 ? `Turnout (quorum ${turnout.minTurnout}%)` : "Turnout"}
+                    value={`${turnout.percent.toFixed(1)}%`}
+                    color={turnout.quorumMet ? "text-emerald-600" : "text-amber-500"}
+                  />
+                )}
               </div>
 
-              <div className="bg-white border border-slate-200 rounded-2xl overflow-hidden shadow-sm">
-                <div className="p-6 border-b border-slate-100">
-                  <h3 className="font-bold text-lg text-slate-900">Live Results</h3>
-                </div>
-                <div className="overflow-x-auto">
-                  <table className="w-full text-left">
-                    <thead className="bg-slate-50 text-slate-500 text-xs font-bold uppercase tracking-wider">
-                      <tr>
-                        <th className="p-5">Candidate</th>
-                        <th className="p-5 text-right">Votes</th>
-                        <th className="p-5 text-right">Percentage</th>
-                      </tr>
-                    </thead>
-                    <tbody className="divide-y divide-slate-100">
-                      {results.map((r, idx) => {
-                        const total = results.reduce((acc, curr) => acc + (curr.Count || curr.count || 0), 0);
-                        const count = r.Count || r.count || 0;
-                        const percent = total > 0 ? ((count / total) * 100).toFixed(1) : "0";
-                        return (
-                          <tr key={idx} className="hover:bg-slate-50 transition-colors">
-                            <td className="p-5 font-medium text-slate-900 flex items-center gap-3">
-                              <div className="w-10 h-10 rounded-full bg-slate-100 overflow-hidden">
-                                {r.imageUrl && <img src={getImageSrc(r.imageUrl)} className="w-full h-full object-cover" />}
-                              </div>
-                              {r.Name || r.name}
-                            </td>
-                            <td className="p-5 text-right font-mono text-xl text-emerald-600 font-bold">
-                              {count}
-                            </td>
-                            <td className="p-5 text-right text-slate-500">
-                              {percent}%
-                            </td>
-                          </tr>
-                        );
-                      })}
-                      {results.length === 0 && (
+              {results.map((contest) => (
+                <div key={contest.contestId} className="bg-white border border-slate-200 rounded-2xl overflow-hidden shadow-sm">
+                  <div className="p-6 border-b border-slate-100">
+                    <h3 className="font-bold text-lg text-slate-900">{contest.title}</h3>
+                    {contest.method === "irv" && <p className="text-sm text-slate-500">First preferences below; instant-runoff rounds follow.</p>}
+                    {(() => {
+                      const nameOf = (id: number) => contest.results.find((r) => r.candidateId === id)?.name || `#${id}`;
+                      const { status, winners = [], runoff = [], reason } = contest.outcome;
+                      const style = status === "winner" ? "bg-emerald-50 text-emerald-700" : status === "runoff" ? "bg-amber-50 text-amber-700" : "bg-red-50 text-red-600";
+                      return (
+                        <div className={`mt-3 px-4 py-2 rounded-xl text-sm font-medium ${style}`}>
+                          {provisional && <span className="font-bold mr-2">Provisional:</span>}
+                          {status === "winner" && `Elected: ${winners.map(nameOf).join(", ")}`}
+                          {status === "runoff" && `Runoff needed between ${runoff.map(nameOf).join(", ")}${winners.length > 0 ? ` (elected: ${winners.map(nameOf).join(", ")})` : ""}`}
+                          {status === "void" && "Void"}
+                          {reason && ` — ${reason}`}
+                        </div>
+                      );
+                    })()}
+                  </div>
+                  <div className="overflow-x-auto">
+                    <table className="w-full text-left">
+                      <thead className="bg-slate-50 text-slate-500 text-xs font-bold uppercase tracking-wider">
                         <tr>
-                          <td colSpan={3} className="p-8 text-center text-slate-500">No votes recorded yet.</td>
+                          <th className="p-5">Candidate</th>
+                          <th className="p-5 text-right">Votes</th>
+                          <th className="p-5 text-right">Percentage</th>
                         </tr>
-                      )}
-                    </tbody>
-                  </table>
+                      </thead>
+                      <tbody className="divide-y divide-slate-100">
+                        {contest.results.map((r, idx) => {
+                          const total = contest.results.reduce((acc, curr) => acc + curr.count, 0);
+                          const count = r.count;
+                          const percent = total > 0 ? ((count / total) * 100).toFixed(1) : "0";
+                          return (
+                            <tr key={idx} className="hover:bg-slate-50 transition-colors">
+                              <td className="p-5 font-medium text-slate-900 flex items-center gap-3">
+                                <div className="w-10 h-10 rounded-full bg-slate-100 overflow-hidden">
+                                  {r.imageUrl && <img src={getImageSrc(r.imageUrl)} className="w-full h-full object-cover" />}
+                                </div>
+                                {r.name}
+                                {r.withdrawn && <span className="text-xs font-semibold text-amber-600 bg-amber-50 px-2 py-0.5 rounded-full">Withdrawn</span>}
+                              </td>
+                              <td className="p-5 text-right font-mono text-xl text-emerald-600 font-bold">
+                                {count}
+                              </td>
+                              <td className="p-5 text-right text-slate-500">
+                                {percent}%
+                              </td>
+                            </tr>
+                          );
+                        })}
+                        {ballotCount === 0 && (
+                          <tr>
+                            <td colSpan={3} className="p-8 text-center text-slate-500">No votes recorded yet.</td>
+                          </tr>
+                        )}
+                      </tbody>
+                    </table>
+                  </div>
+                  {contest.rounds && contest.rounds.length > 0 && (
+                    <div className="overflow-x-auto border-t border-slate-100">
+                      <table className="w-full text-left text-sm">
+                        <thead className="bg-slate-50 text-slate-500 text-xs font-bold uppercase tracking-wider">
+                          <tr>
+                            <th className="p-4">Round</th>
+                            {contest.results.map((r) => (
+                              <th key={r.candidateId} className="p-4 text-right">{r.name}</th>
+                            ))}
+                            <th className="p-4 text-right">Exhausted</th>
+                            <th className="p-4">Outcome</th>
+                          </tr>
+                        </thead>
+                        <tbody className="divide-y divide-slate-100">
+                          {contest.rounds.map((round) => {
+                            const nameOf = (id: number) => contest.results.find((r) => r.candidateId === id)?.name || `#${id}`;
+                            const transfers = Object.entries(round.transfers || {})
+                              .map(([id, n]) => `${n} to ${nameOf(Number(id))}`)
+                              .concat(round.exhaustedTransfers ? [`${round.exhaustedTransfers} exhausted`] : []);
+                            return (
+                              <tr key={round.round}>
+                                <td className="p-4 font-bold text-slate-900">{round.round}</td>
+                                {contest.results.map((r) => (
+                                  <td key={r.candidateId} className="p-4 text-right font-mono text-slate-700">
+                                    {round.counts[r.candidateId] ?? "—"}
+                                  </td>
+                                ))}
+                                <td className="p-4 text-right font-mono text-slate-500">{round.exhausted}</td>
+                                <td className="p-4 text-slate-600">
+                                  {round.elected !== undefined && <span className="font-bold text-emerald-600">{nameOf(round.elected)} elected</span>}
+                                  {round.eliminated !== undefined && <>{nameOf(round.eliminated)} eliminated{transfers.length > 0 && `: ${transfers.join(", ")}`}</>}
+                                </td>
+                              </tr>
+                            );
+                          })}
+                        </tbody>
+                      </table>
+                    </div>
+                  )}
                 </div>
-              </div>
+              ))}
             </>
           ) : (
             <div className="bg-white border border-slate-200 rounded-2xl p-12 text-center text-slate-400 flex flex-col items-center">
@@ -423,6 +937,13 @@ const AdminPage = () => {
                         </div>
                       </div>
                       <p className="text-slate-500 text-sm ml-16">{v.Email}</p>
+                      {!v.onRoll ? (
+                        <p className="text-amber-600 text-sm ml-16 mt-1">Not on any voter roll</p>
+                      ) : v.nameMismatch ? (
+                        <p className="text-red-600 text-sm ml-16 mt-1 font-medium">Name on roll: {v.rollName} ({v.rollCohort || "no cohort"})</p>
+                      ) : (
+                        <p className="text-emerald-600 text-sm ml-16 mt-1">On roll{v.rollCohort ? `, cohort ${v.rollCohort}` : ""}</p>
+                      )}
                     </div>
 
                     <div className="flex gap-4">
@@ -516,7 +1037,14 @@ const AdminPage = () => {
                       <td className="p-4 text-center">
                         {u.HasVoted ?
                           <CheckCircle2 size={20} className="text-emerald-500 mx-auto" /> :
-                          <span className="text-slate-300 text-xs font-medium">No</span>
+                          <div className="flex flex-col items-center gap-1">
+                            <span className="text-slate-300 text-xs font-medium">No</span>
+                            {u.VerificationStatus === 'approved' && (
+                              <button onClick={() => handleGrantSession(u.ID)} className="text-xs font-medium text-emerald-600 hover:text-emerald-700">
+                                New session
+                              </button>
+                            )}
+                          </div>
                         }
                       </td>
                     </tr>
@@ -643,21 +1171,65 @@ const AdminPage = () => {
         <div className="space-y-8">
           <div className="flex justify-between items-center bg-white p-5 rounded-2xl border border-slate-200 shadow-sm">
             <h2 className="font-bold text-xl ml-2 text-slate-900">Candidates</h2>
-            <button
-              onClick={() => setIsAddCandidateOpen(!isAddCandidateOpen)}
-              className={`flex items-center gap-2 px-5 py-2.5 rounded-xl font-medium transition-colors ${isAddCandidateOpen ? 'bg-red-50 text-red-600' : 'bg-emerald-600 hover:bg-emerald-700 text-white shadow-lg shadow-emerald-200'}`}
-            >
-              {isAddCandidateOpen ? <><XCircle size={18} /> Cancel</> : <><Plus size={18} /> Add Candidate</>}
-            </button>
+            <div className="flex items-center gap-3">
+              <button onClick={() => handleAddContest("candidates")} className="flex items-center gap-2 px-4 py-2.5 rounded-xl font-medium text-slate-600 hover:bg-slate-50 border border-slate-200 transition-colors">
+                <Plus size={18} /> Add Contest
+              </button>
+              <button onClick={() => handleAddContest("referendum")} className="flex items-center gap-2 px-4 py-2.5 rounded-xl font-medium text-slate-600 hover:bg-slate-50 border border-slate-200 transition-colors">
+                <Plus size={18} /> Add Referendum
+              </button>
+              <button onClick={handleDrawBallotNumbers} className="flex items-center gap-2 px-4 py-2.5 rounded-xl font-medium text-slate-600 hover:bg-slate-50 border border-slate-200 transition-colors">
+                <Shuffle size={18} /> Draw Ballot Numbers
+              </button>
+              <button
+                onClick={() => (isAddCandidateOpen ? closeCandidateForm() : setIsAddCandidateOpen(true))}
+                className={`flex items-center gap-2 px-5 py-2.5 rounded-xl font-medium transition-colors ${isAddCandidateOpen ? 'bg-red-50 text-red-600' : 'bg-emerald-600 hover:bg-emerald-700 text-white shadow-lg shadow-emerald-200'}`}
+              >
+                {isAddCandidateOpen ? <><XCircle size={18} /> Cancel</> : <><Plus size={18} /> Add Candidate</>}
+              </button>
+            </div>
           </div>
 
           {isAddCandidateOpen && (
             <div className="bg-white border border-slate-200 p-8 rounded-2xl max-w-2xl mx-auto shadow-xl animate-fade-in">
-              <h3 className="text-2xl font-bold mb-8 text-slate-900 text-center">New Candidate</h3>
+              <h3 className="text-2xl font-bold mb-8 text-slate-900 text-center">{editingCandidateId ? "Edit Candidate" : "New Candidate"}</h3>
               <form onSubmit={handleAddCandidate} className="space-y-6">
+                {!editingCandidateId && <div>
+                  <label className="block text-sm font-medium text-slate-700 mb-2">Contest</label>
+                  <select className="w-full bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" value={newCandidate.contestId} onChange={e => setNewCandidate({ ...newCandidate, contestId: e.target.value })}>
+                    {contests.filter(ct => ct.Kind === "candidates").map(ct => (
+                      <option key={ct.ID} value={ct.ID}>{ct.Title}</option>
+                    ))}
+                  </select>
+                </div>}
                 <div>
-                  <label className="block text-sm font-medium text-slate-700 mb-2">Name</label>
-                  <input className="w-full bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="Candidate Name" value={newCandidate.name} onChange={e => setNewCandidate({ ...newCandidate, name: e.target.value })} required />
+                  <label className="block text-sm font-medium text-slate-700 mb-2">Members</label>
+                  <div className="space-y-3">
+                    {members.map((m, i) => (
+                      <div key={i} className="grid grid-cols-1 md:grid-cols-4 gap-2 items-center">
+                        <input className="bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="Role" value={m.role} onChange={e => updateMember(i, "role", e.target.value)} />
+                        <input className="bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="Name" value={m.name} onChange={e => updateMember(i, "name", e.target.value)} />
+                        <input className="bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="NIM" value={m.nim} onChange={e => updateMember(i, "nim", e.target.value)} />
+                        <input type="file" className="block w-full text-sm text-slate-500 file:mr-2 file:py-2 file:px-3 file:rounded-xl file:border-0 file:bg-emerald-50 file:text-emerald-700 cursor-pointer" onChange={e => setMemberImg(i, e.target.files ? e.target.files[0] : null)} />
+                      </div>
+                    ))}
+                    <div className="flex gap-4 text-sm">
+                      <button type="button" onClick={() => setMembers([...members, { name: "", nim: "", role: "" }])} className="text-emerald-600 hover:text-emerald-700 font-medium">+ Add member</button>
+                      {members.length > 1 && (
+                        <button type="button" onClick={() => { setMembers(members.slice(0, -1)); setMemberImgs(memberImgs.slice(0, members.length - 1)); }} className="text-red-500 hover:text-red-600 font-medium">Remove last</button>
+                      )}
+                    </div>
+                  </div>
+                </div>
+                <div className="grid grid-cols-1 md:grid-cols-3 gap-6">
+                  <div className="md:col-span-2">
+                    <label className="block text-sm font-medium text-slate-700 mb-2">Name</label>
+                    <input className="w-full bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="Defaults to the members' names" value={newCandidate.name} onChange={e => setNewCandidate({ ...newCandidate, name: e.target.value })} />
+                  </div>
+                  <div>
+                    <label className="block text-sm font-medium text-slate-700 mb-2">Ballot Number</label>
+                    <input type="number" min={1} className="w-full bg-slate-50 border border-slate-200 rounded-xl p-3 text-slate-900 focus:ring-2 focus:ring-emerald-500 outline-none" placeholder="Drawn later" value={newCandidate.ballotNumber} onChange={e => setNewCandidate({ ...newCandidate, ballotNumber: e.target.value })} />
+                  </div>
                 </div>
                 <div className="grid grid-cols-1 gap-6">
                   <div>
@@ -671,59 +1243,286 @@ const AdminPage = () => {
                 </div>
                 <div>
                   <label className="block text-sm font-medium text-slate-700 mb-2">Photo</label>
-                  <input type="file" className="block w-full text-sm text-slate-500 file:mr-4 file:py-2.5 file:px-5 file:rounded-xl file:border-0 file:bg-emerald-50 file:text-emerald-700 hover:file:bg-emerald-100 cursor-pointer bg-slate-50 rounded-xl border border-slate-200" onChange={e => e.target.files && setCandidateImg(e.target.files[0])} required />
+                  <input type="file" className="block w-full text-sm text-slate-500 file:mr-4 file:py-2.5 file:px-5 file:rounded-xl file:border-0 file:bg-emerald-50 file:text-emerald-700 hover:file:bg-emerald-100 cursor-pointer bg-slate-50 rounded-xl border border-slate-200" onChange={e => e.target.files && setCandidateImg(e.target.files[0])} required={!editingCandidateId && !memberImgs.some(Boolean)} />
                 </div>
                 <button type="submit" disabled={submitting} className="w-full bg-emerald-600 hover:bg-emerald-700 text-white font-bold py-3.5 rounded-xl shadow-lg shadow-emerald-200 mt-4">
-                  {submitting ? "Saving..." : "Create Candidate"}
+                  {submitting ? "Saving..." : editingCandidateId ? "Save Candidate" : "Create Candidate"}
                 </button>
               </form>
             </div>
           )}
 
-          <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-6">
-            {candidates.map(c => (
-              <div key={c.ID} className="bg-white border border-slate-200 rounded-2xl overflow-hidden group hover:border-emerald-200 hover:shadow-lg transition-all duration-300">
-                <div className="aspect-video relative overflow-hidden">
-                  <img src={getImageSrc(c.ImageURL)} alt={c.Name} className="w-full h-full object-cover group-hover:scale-105 transition-transform duration-500" />
-                  <div className="absolute inset-0 bg-gradient-to-t from-slate-900/80 via-transparent to-transparent opacity-60" />
-                  <h3 className="absolute bottom-4 left-4 font-bold text-xl text-white drop-shadow-md">{c.Name}</h3>
+          {contests.map(contest => (
+            <section key={contest.ID} className="space-y-4">
+              <div className="flex justify-between items-center px-2">
+                <div>
+                  <h3 className="font-bold text-lg text-slate-900">{contest.Title}</h3>
+                  <p className="text-sm text-slate-500">
+                    {contest.Kind === "referendum" ? "Referendum" : contest.Method === "irv" ? `Ranked, up to ${contest.MaxChoices} preferences (instant-runoff)` : `Choose up to ${contest.MaxChoices}`}
+                    {contest.KotakKosong && " · Kotak Kosong offered"}
+                  </p>
                 </div>
-                <div className="p-6 space-y-4">
-                  <div>
-                    <h4 className="text-xs uppercase text-emerald-600 font-bold mb-1 tracking-wider">Visi</h4>
-                    <p className="text-sm text-slate-600 line-clamp-2">{c.Visi || "No vision provided."}</p>
-                  </div>
-                  <div>
-                    <h4 className="text-xs uppercase text-emerald-600 font-bold mb-1 tracking-wider">Misi</h4>
-                    <p className="text-sm text-slate-600 line-clamp-3">{c.Misi || "No mission provided."}</p>
-                  </div>
-                  <div className="pt-4 border-t border-slate-100">
-                    <button onClick={() => handleDeleteCandidate(c.ID)} className="w-full py-2.5 flex items-center justify-center gap-2 text-red-500 hover:bg-red-50 rounded-lg transition-colors text-sm font-medium">
-                      <Trash2 size={16} /> Delete Candidate
-                    </button>
+                <button onClick={() => handleDeleteContest(contest.ID)} className="flex items-center gap-2 text-sm font-medium text-red-500 hover:text-red-600">
+                  <Trash2 size={16} /> Delete Contest
+                </button>
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-6">
+                {contest.Candidates.map(c => (
+                  <div key={c.ID} className={`bg-white border border-slate-200 rounded-2xl overflow-hidden group hover:border-emerald-200 hover:shadow-lg transition-all duration-300 ${c.WithdrawnAt ? "opacity-60" : ""}`}>
+                    <div className="aspect-video relative overflow-hidden">
+                      <img src={getImageSrc(c.ImageURL)} alt={c.Name} className="w-full h-full object-cover group-hover:scale-105 transition-transform duration-500" />
+                      <div className="absolute inset-0 bg-gradient-to-t from-slate-900/80 via-transparent to-transparent opacity-60" />
+                      {c.BallotNumber && (
+                        <span className="absolute top-4 left-4 w-10 h-10 rounded-full bg-white text-slate-900 font-bold text-lg flex items-center justify-center shadow-md">{c.BallotNumber}</span>
+                      )}
+                      {c.WithdrawnAt && (
+                        <span className="absolute top-4 right-4 text-xs font-semibold text-amber-700 bg-amber-50 px-3 py-1 rounded-full" title={c.WithdrawalReason}>Withdrawn</span>
+                      )}
+                      <h3 className="absolute bottom-4 left-4 font-bold text-xl text-white drop-shadow-md">{c.Name}</h3>
+                    </div>
+                    <div className="p-6 space-y-4">
+                      {c.Members && c.Members.length > 0 && (
+                        <ul className="space-y-2">
+                          {c.Members.map(m => (
+                            <li key={m.ID} className="flex items-center gap-3 text-sm">
+                              {m.ImageURL && <img src={getImageSrc(m.ImageURL)} alt={m.Name} className="w-8 h-8 rounded-full object-cover" />}
+                              <span className="font-medium text-slate-900">{m.Name}</span>
+                              <span className="text-slate-500">{[m.Role, m.NIM].filter(Boolean).join(" · ")}</span>
+                            </li>
+                          ))}
+                        </ul>
+                      )}
+                      <div>
+                        <h4 className="text-xs uppercase text-emerald-600 font-bold mb-1 tracking-wider">Visi</h4>
+                        <p className="text-sm text-slate-600 line-clamp-2">{c.Visi || "No vision provided."}</p>
+                      </div>
+                      <div>
+                        <h4 className="text-xs uppercase text-emerald-600 font-bold mb-1 tracking-wider">Misi</h4>
+                        <p className="text-sm text-slate-600 line-clamp-3">{c.Misi || "No mission provided."}</p>
+                      </div>
+                      {c.WithdrawnAt && c.WithdrawalReason && (
+                        <p className="text-xs text-amber-700">Withdrew: {c.WithdrawalReason}</p>
+                      )}
+                      <div className="pt-4 border-t border-slate-100 grid grid-cols-3 gap-2">
+                        <button onClick={() => handleEditCandidate(c, contest.ID)} className="py-2.5 flex items-center justify-center gap-2 text-slate-600 hover:bg-slate-50 rounded-lg transition-colors text-sm font-medium">
+                          <Pencil size={16} /> Edit
+                        </button>
+                        {c.WithdrawnAt ? (
+                          <button onClick={() => handleReinstateCandidate(c.ID)} className="py-2.5 flex items-center justify-center gap-2 text-emerald-600 hover:bg-emerald-50 rounded-lg transition-colors text-sm font-medium">
+                            <Undo2 size={16} /> Reinstate
+                          </button>
+                        ) : (
+                          <button onClick={() => handleWithdrawCandidate(c)} className="py-2.5 flex items-center justify-center gap-2 text-amber-600 hover:bg-amber-50 rounded-lg transition-colors text-sm font-medium">
+                            <UserMinus size={16} /> Withdraw
+                          </button>
+                        )}
+                        <button onClick={() => handleDeleteCandidate(c.ID)} className="py-2.5 flex items-center justify-center gap-2 text-red-500 hover:bg-red-50 rounded-lg transition-colors text-sm font-medium">
+                          <Trash2 size={16} /> Delete
+                        </button>
+                      </div>
+                    </div>
                   </div>
-                </div>
+                ))}
               </div>
-            ))}
-          </div>
+            </section>
+          ))}
         </div>
       )}
 
       {/* --- SETTINGS TAB --- */}
       {activeTab === "settings" && (
         <div className="max-w-xl mx-auto bg-white border border-slate-200 rounded-2xl p-10 shadow-sm mt-8">
-          <h3 className="text-2xl font-bold mb-8 text-slate-900 border-b border-slate-100 pb-4">Election Timing</h3>
-          <form onSubmit={handleSaveSettings} className="space-y-6">
-            <div>
-              <label className="block text-slate-600 font-medium mb-2">Start Time</label>
-              <input type="datetime-local" value={electionSettings.startTime} onChange={(e) => setElectionSettings({ ...electionSettings, startTime: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+          <div className="flex justify-between items-center mb-8 border-b border-slate-100 pb-4">
+            <h3 className="text-2xl font-bold text-slate-900">Election</h3>
+            <button type="button" onClick={handleCreateElection} className="flex items-center gap-2 text-sm font-medium text-emerald-600 hover:text-emerald-700">
+              <Plus size={16} /> New Election
+            </button>
+          </div>
+          {selectedElection ? (
+            <form onSubmit={handleSaveElection} className="space-y-6">
+              <div className="flex justify-between items-center bg-slate-50 border border-slate-200 rounded-xl p-4">
+                <div>
+                  <span className="block text-xs font-bold uppercase tracking-wider text-slate-400">Phase</span>
+                  <span className="font-bold text-slate-900 capitalize">{selectedElection.Phase}</span>
+                </div>
+                {nextPhase && !selectedElection.Archived && (
+                  <button type="button" onClick={() => handleAdvancePhase(nextPhase)} className="text-sm font-medium text-emerald-600 hover:text-emerald-700">
+                    Advance to {nextPhase}
+                  </button>
+                )}
+              </div>
+              <div>
+                <label className="block text-slate-600 font-medium mb-2">Name</label>
+                <input type="text" value={electionForm.name} onChange={(e) => setElectionForm({ ...electionForm, name: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" required />
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Registration Opens (WIB)</label>
+                  <input type="datetime-local" value={electionForm.registrationStart} onChange={(e) => setElectionForm({ ...electionForm, registrationStart: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Registration Closes (WIB)</label>
+                  <input type="datetime-local" value={electionForm.registrationEnd} onChange={(e) => setElectionForm({ ...electionForm, registrationEnd: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+              </div>
+              <div>
+                <label className="block text-slate-600 font-medium mb-2">Voting Opens (WIB)</label>
+                <input type="datetime-local" value={electionForm.startTime} onChange={(e) => setElectionForm({ ...electionForm, startTime: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+              </div>
+              <div>
+                <label className="block text-slate-600 font-medium mb-2">Voting Closes (WIB)</label>
+                <input type="datetime-local" value={electionForm.endTime} onChange={(e) => setElectionForm({ ...electionForm, endTime: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Time in Voting Booth (minutes)</label>
+                  <input type="number" min={1} max={120} value={electionForm.votingMinutes} onChange={(e) => setElectionForm({ ...electionForm, votingMinutes: parseInt(e.target.value, 10) || 0 })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">New Sessions per Voter</label>
+                  <input type="number" min={0} max={10} value={electionForm.sessionRegrants} onChange={(e) => setElectionForm({ ...electionForm, sessionRegrants: parseInt(e.target.value, 10) || 0 })} title="How many times an admin may let a voter whose session was cut off vote again" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <label className="flex items-center gap-3 text-slate-600 font-medium">
+                  <input
+                    type="checkbox"
+                    checked={electionForm.shuffleOptions}
+                    onChange={(e) => setElectionForm({
+                      ...electionForm,
+                      shuffleOptions: e.target.checked,
+                      kotakKosongPlace: !e.target.checked && electionForm.kotakKosongPlace === "shuffled" ? "last" : electionForm.kotakKosongPlace,
+                    })}
+                    className="w-5 h-5 accent-emerald-600"
+                  />
+                  Show each voter the candidates in their own random order
+                </label>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Kotak Kosong Placement</label>
+                  <select value={electionForm.kotakKosongPlace} onChange={(e) => setElectionForm({ ...electionForm, kotakKosongPlace: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none">
+                    <option value="last">After the candidates</option>
+                    <option value="first">Before the candidates</option>
+                    <option value="shuffled" disabled={!electionForm.shuffleOptions}>Shuffled in with the candidates</option>
+                  </select>
+                </div>
+              </div>
+              <div>
+                <label className="block text-slate-600 font-medium mb-2">Eligible NIM Prefixes</label>
+                <input type="text" value={electionForm.nimPrefixes} onChange={(e) => setElectionForm({ ...electionForm, nimPrefixes: e.target.value })} placeholder="e.g. 15022,15023 (empty = all approved voters)" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">NIM Length</label>
+                  <input type="number" min={0} max={32} value={electionForm.nimLength} onChange={(e) => setElectionForm({ ...electionForm, nimLength: parseInt(e.target.value, 10) || 0 })} title="0 = any length" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">NIM Pattern (regex)</label>
+                  <input type="text" value={electionForm.nimPattern} onChange={(e) => setElectionForm({ ...electionForm, nimPattern: e.target.value })} placeholder="Empty = digits only" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 font-mono text-sm focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Cohort at Digit</label>
+                  <input type="number" min={0} value={electionForm.cohortPosition} onChange={(e) => setElectionForm({ ...electionForm, cohortPosition: parseInt(e.target.value, 10) || 0 })} title="Position of the two-digit cohort year in the NIM, e.g. 4 for 150[22]045; 0 = no cohort rule" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Cohorts From</label>
+                  <input type="number" min={0} value={electionForm.cohortMin} onChange={(e) => setElectionForm({ ...electionForm, cohortMin: parseInt(e.target.value, 10) || 0 })} placeholder="e.g. 2020" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Cohorts To</label>
+                  <input type="number" min={0} value={electionForm.cohortMax} onChange={(e) => setElectionForm({ ...electionForm, cohortMax: parseInt(e.target.value, 10) || 0 })} placeholder="e.g. 2024" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+              </div>
+              <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Minimum Turnout (%)</label>
+                  <input type="number" min={0} max={100} value={electionForm.minTurnout} onChange={(e) => setElectionForm({ ...electionForm, minTurnout: parseInt(e.target.value, 10) || 0 })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Majority</label>
+                  <select value={electionForm.majorityRule} onChange={(e) => setElectionForm({ ...electionForm, majorityRule: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none">
+                    <option value="simple">Simple (most votes)</option>
+                    <option value="absolute">Absolute (more than half)</option>
+                  </select>
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Kotak Kosong</label>
+                  <select value={electionForm.kotakKosongPolicy} onChange={(e) => setElectionForm({ ...electionForm, kotakKosongPolicy: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none">
+                    <option value="per_contest">As set on each contest</option>
+                    <option value="always">Always offered</option>
+                    <option value="single_candidate">Only with a single candidate</option>
+                    <option value="never">Never offered</option>
+                  </select>
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">If Kotak Kosong Wins</label>
+                  <select value={electionForm.kotakKosongWins} onChange={(e) => setElectionForm({ ...electionForm, kotakKosongWins: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none">
+                    <option value="void">The contest is void</option>
+                    <option value="runner_up">The best candidate is elected</option>
+                  </select>
+                </div>
+                <div>
+                  <label className="block text-slate-600 font-medium mb-2">Ballots For A Withdrawn Candidate</label>
+                  <select value={electionForm.withdrawnVotes} onChange={(e) => setElectionForm({ ...electionForm, withdrawnVotes: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none">
+                    <option value="void">Count for no one (ranked ballots pass to the next preference)</option>
+                    <option value="count">Still count for the candidate</option>
+                  </select>
+                </div>
+              </div>
+              <div>
+                <label className="block text-slate-600 font-medium mb-2">Election Public Key</label>
+                <input type="text" value={electionForm.publicKey} onChange={(e) => setElectionForm({ ...electionForm, publicKey: e.target.value })} placeholder="From cmd/electionkey (empty = server default)" className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 font-mono text-sm focus:ring-2 focus:ring-emerald-500 outline-none" />
+              </div>
+              <button type="submit" className="w-full bg-emerald-600 hover:bg-emerald-700 text-white font-bold py-3.5 rounded-xl transition-colors shadow-lg shadow-emerald-200 mt-4">Save Election</button>
+              <button type="button" onClick={() => handleArchiveElection(!selectedElection.Archived)} className="w-full border border-slate-200 hover:bg-slate-50 text-slate-600 font-bold py-3.5 rounded-xl transition-colors">
+                {selectedElection.Archived ? "Restore Election" : "Archive Election"}
+              </button>
+            </form>
+          ) : (
+            <p className="text-slate-500 text-center">No election yet. Create one to get started.</p>
+          )}
+        </div>
+      )}
+      {activeTab === "settings" && selectedElection && (
+        <div className="max-w-xl mx-auto bg-white border border-slate-200 rounded-2xl p-10 shadow-sm mt-8">
+          <div className="flex justify-between items-center mb-6 border-b border-slate-100 pb-4">
+            <h3 className="text-2xl font-bold text-slate-900">Voter Roll</h3>
+            {roll.frozen && <span className="text-xs font-bold uppercase tracking-wide text-slate-500 bg-slate-100 px-2 py-1 rounded">Frozen</span>}
+          </div>
+          <p className="text-slate-500 text-sm mb-4">
+            {roll.entries.length > 0
+              ? `${roll.entries.length} voters on the roll, ${roll.approved} of them registered and approved. Only NIMs on the roll can register, log in and vote.`
+              : "No roll imported: any NIM matching the eligible prefixes can register."}
+          </p>
+          {!roll.frozen && (
+            <div className="flex gap-4 mb-6">
+              <label className="flex-1 text-center cursor-pointer bg-emerald-600 hover:bg-emerald-700 text-white font-bold py-3 rounded-xl transition-colors">
+                Import CSV (NIM, name, cohort)
+                <input type="file" accept=".csv,text/csv" onChange={handleImportRoll} className="hidden" />
+              </label>
+              {roll.entries.length > 0 && (
+                <button type="button" onClick={handleDeleteRoll} className="px-4 border border-red-200 text-red-500 hover:bg-red-50 font-bold rounded-xl transition-colors">
+                  <Trash2 size={16} />
+                </button>
+              )}
             </div>
-            <div>
-              <label className="block text-slate-600 font-medium mb-2">End Time</label>
-              <input type="datetime-local" value={electionSettings.endTime} onChange={(e) => setElectionSettings({ ...electionSettings, endTime: e.target.value })} className="w-full bg-slate-50 border border-slate-200 text-slate-900 rounded-xl p-3.5 focus:ring-2 focus:ring-emerald-500 outline-none" />
+          )}
+          {roll.entries.length > 0 && (
+            <div className="max-h-64 overflow-y-auto border border-slate-100 rounded-xl">
+              <table className="w-full text-left text-sm">
+                <tbody className="divide-y divide-slate-100">
+                  {roll.entries.map((r) => (
+                    <tr key={r.ID}>
+                      <td className="p-2 font-mono text-slate-600">{r.NIM}</td>
+                      <td className="p-2 text-slate-900">{r.Name}</td>
+                      <td className="p-2 text-slate-500">{r.Cohort}</td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
             </div>
-            <button type="submit" className="w-full bg-emerald-600 hover:bg-emerald-700 text-white font-bold py-3.5 rounded-xl transition-colors shadow-lg shadow-emerald-200 mt-4">Save Settings</button>
-          </form>
+          )}
         </div>
       )}
       {/* --- SUCCESS MODAL --- */}
@@ -734,12 +1533,7 @@ const AdminPage = () => {
                <CheckCircle2 size={40} strokeWidth={3} />
             </div>
             <h3 className="text-2xl font-bold text-slate-900 mb-2">Vote Recorded!</h3>
-            <p className="text-slate-500 mb-6">The vote has been successfully verified and added to the count.</p>
-            
-            <div className="bg-slate-50 border border-slate-100 rounded-2xl p-4 w-full mb-8">
-              <span className="text-xs font-bold text-slate-400 uppercase tracking-wider block mb-1">Voted For</span>
-              <span className="text-lg font-bold text-emerald-700">{successCandidateName || "Unknown Candidate"}</span>
-            </div>
+            <p className="text-slate-500 mb-8">The vote has been verified and its sealed ballot added to the count. The choice is never shown to admins.</p>
 
             <button 
               onClick={() => setShowSuccessModal(false)}
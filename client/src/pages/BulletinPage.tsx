@@ -0,0 +1,106 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useLocation } from 'react-router-dom';
+import api from '../api';
+
+interface BulletinEntry {
+    receipt: string;
+    status: 'counted' | 'spoiled';
+}
+
+// Public bulletin board of ballot receipts. Right after voting it also shows
+// the voter their own receipt code.
+const BulletinPage = () => {
+    const location = useLocation();
+    const ownReceipt: string | undefined = (location.state as any)?.receipt;
+    const [electionId, setElectionId] = useState<number | undefined>((location.state as any)?.electionId);
+    const [elections, setElections] = useState<{ ID: number; Name: string }[]>([]);
+    const [electionName, setElectionName] = useState('');
+    const [entries, setEntries] = useState<BulletinEntry[] | null>(null);
+    const [pendingReview, setPendingReview] = useState(0);
+    const [notice, setNotice] = useState('');
+    const [query, setQuery] = useState(ownReceipt || '');
+
+    useEffect(() => {
+        api.get('/elections').then((res) => setElections(res.data || [])).catch(() => {});
+    }, []);
+
+    useEffect(() => {
+        setEntries(null);
+        setNotice('');
+        api.get('/bulletin', { params: { electionId } })
+            .then((res) => {
+                setEntries(res.data.ballots);
+                setPendingReview(res.data.pendingReview);
+                setElectionName(res.data.election);
+            })
+            .catch((err) => setNotice(err.response?.data?.error || 'Papan buletin belum tersedia.'));
+    }, [electionId]);
+
+    const normalized = query.trim().toUpperCase();
+    const match = entries?.find((e) => e.receipt === normalized);
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-slate-50 p-4">
+            <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-lg border border-slate-100">
+                <h2 className="text-3xl font-bold text-slate-900 tracking-tight mb-2 text-center">Papan Buletin</h2>
+                {elections.length > 1 ? (
+                    <select
+                        value={electionId ?? ''}
+                        onChange={(e) => setElectionId(e.target.value ? Number(e.target.value) : undefined)}
+                        className="w-full px-4 py-2 mb-6 bg-slate-50 border border-slate-200 rounded-xl text-sm"
+                    >
+                        <option value="">Pemilihan saat ini</option>
+                        {elections.map((e) => <option key={e.ID} value={e.ID}>{e.Name}</option>)}
+                    </select>
+                ) : (
+                    <p className="text-slate-500 text-center mb-6">{electionName}</p>
+                )}
+
+                {ownReceipt && (
+                    <div className="bg-emerald-50 border border-emerald-100 rounded-2xl p-4 mb-6 text-center">
+                        <span className="text-xs font-bold text-emerald-600 uppercase tracking-wider block mb-1">Kode Tanda Terima Anda</span>
+                        <span className="text-xl font-mono font-bold text-emerald-800">{ownReceipt}</span>
+                        <p className="text-xs text-slate-500 mt-2">Simpan kode ini. Setelah pemilihan ditutup, cari kode ini di halaman ini untuk memastikan suara Anda ikut dihitung.</p>
+                    </div>
+                )}
+
+                {notice && <p className="text-slate-500 text-center">{notice}</p>}
+
+                {entries && (
+                    <>
+                        <input
+                            value={query}
+                            onChange={(e) => setQuery(e.target.value)}
+                            className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl outline-none text-sm font-mono mb-3"
+                            placeholder="XXXX-XXXX-XXXX-XXXX"
+                        />
+                        {normalized && (
+                            <p className={`text-sm mb-4 ${match ? 'text-emerald-700' : 'text-red-600'}`}>
+                                {match
+                                    ? match.status === 'counted' ? 'Suara ini ikut dihitung.' : 'Suara ini dinyatakan hangus.'
+                                    : 'Kode tidak ditemukan.'}
+                            </p>
+                        )}
+                        <p className="text-xs text-slate-400 mb-2">
+                            {entries.length} surat suara{pendingReview > 0 && `, ${pendingReview} masih diverifikasi`}
+                        </p>
+                        <ul className="max-h-80 overflow-y-auto divide-y divide-slate-100 text-sm font-mono">
+                            {entries.map((e) => (
+                                <li key={e.receipt} className="flex justify-between py-1.5">
+                                    <span>{e.receipt}</span>
+                                    <span className={e.status === 'counted' ? 'text-emerald-600' : 'text-red-500'}>{e.status}</span>
+                                </li>
+                            ))}
+                        </ul>
+                    </>
+                )}
+
+                <div className="mt-8 pt-6 border-t border-slate-100 text-center">
+                    <Link to="/login" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Back to Sign In</Link>
+                </div>
+            </div>
+        </div>
+    );
+};
+
+export default BulletinPage;
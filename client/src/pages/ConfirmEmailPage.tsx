@@ -0,0 +1,39 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import api from '../api';
+
+// Landing page for the confirmation link emailed by Register.
+const ConfirmEmailPage = () => {
+    const [searchParams] = useSearchParams();
+    const [message, setMessage] = useState('Mengonfirmasi email...');
+    const [failed, setFailed] = useState(false);
+
+    useEffect(() => {
+        const confirm = async () => {
+            try {
+                const res = await api.post('/register/confirm', { token: searchParams.get('token') });
+                setMessage(res.data.message);
+            } catch (err: any) {
+                setFailed(true);
+                setMessage(err.response?.data?.error || 'Konfirmasi email gagal');
+            }
+        };
+        confirm();
+    }, [searchParams]);
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-slate-50">
+            <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-md border border-slate-100 text-center">
+                <h2 className="text-3xl font-bold text-slate-900 tracking-tight mb-6">Email Confirmation</h2>
+                <p className={failed ? 'text-red-600' : 'text-slate-600'}>{message}</p>
+                <div className="mt-8 pt-6 border-t border-slate-100">
+                    <Link to={failed ? '/register' : '/login'} className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">
+                        {failed ? 'Register again' : 'Back to Sign In'}
+                    </Link>
+                </div>
+            </div>
+        </div>
+    );
+};
+
+export default ConfirmEmailPage;
@@ -1,29 +1,108 @@
 import React, { useState } from "react";
 import { useNavigate } from "react-router-dom";
-import api from "../api";
+import api, { STAFF_ROLES } from "../api";
+
+// Login steps: credentials, then (if needed) TOTP code, forced password change,
+// TOTP enrollment and finally the one-time recovery codes.
+type Step = "login" | "mfa" | "changePassword" | "enroll" | "recoveryCodes";
+
+const inputClass = "w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 focus:border-transparent outline-none transition-all text-sm";
+const buttonClass = "w-full py-3 bg-slate-900 hover:bg-emerald-600 text-white font-semibold rounded-xl shadow-lg shadow-slate-900/20 hover:shadow-emerald-600/20 transition-all transform active:scale-95";
 
 const LoginAdminPage = () => {
     const [email, setEmail] = useState("");
     const [password, setPassword] = useState("");
     const [error, setError] = useState("");
+    const [step, setStep] = useState<Step>("login");
+    const [newPassword, setNewPassword] = useState("");
+    const [mfaToken, setMfaToken] = useState("");
+    const [code, setCode] = useState("");
+    const [enrollment, setEnrollment] = useState<{ secret: string; provisioningUri: string } | null>(null);
+    const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
     const navigate = useNavigate();
 
+    const updateStoredUser = (changes: object) => {
+        const user = JSON.parse(localStorage.getItem("user") || "{}");
+        localStorage.setItem("user", JSON.stringify({ ...user, ...changes }));
+    };
+
+    // Decide where to go once a session has been issued.
+    const continueSession = async (user: any) => {
+        setError("");
+        setCode("");
+        if (user.mustChangePassword) {
+            setStep("changePassword");
+        } else if (user.mfaEnrollmentRequired) {
+            const res = await api.post("/admin/mfa/enroll");
+            setEnrollment(res.data);
+            setStep("enroll");
+        } else {
+            navigate("/admin");
+        }
+    };
+
+    const startSession = async (user: any) => {
+        if (!STAFF_ROLES.includes(user.Role)) {
+            setError("Access denied. Not an admin account.");
+            return;
+        }
+        localStorage.setItem("user", JSON.stringify(user));
+        await continueSession(user);
+    };
+
     const handleLogin = async (e: React.FormEvent) => {
         e.preventDefault();
         try {
             const response = await api.post("/admin/login", { email, password });
-            const user = response.data;
-            if (user.Role !== 'admin') {
-                setError("Access denied. Not an admin account.");
+            if (response.data.mfaRequired) {
+                setError("");
+                setMfaToken(response.data.mfaToken);
+                setStep("mfa");
                 return;
             }
-            localStorage.setItem("user", JSON.stringify(user));
-            navigate("/admin");
+            await startSession(response.data);
         } catch (err: any) {
             setError(err.response?.data?.error || "Login failed");
         }
     };
 
+    const handleMfa = async (e: React.FormEvent) => {
+        e.preventDefault();
+        try {
+            // Codes with letters are recovery codes; authenticator codes are 6 digits.
+            const body = /^\d{6}$/.test(code) ? { mfaToken, code } : { mfaToken, recoveryCode: code };
+            const response = await api.post("/admin/login/mfa", body);
+            await startSession(response.data);
+        } catch (err: any) {
+            setError(err.response?.data?.error || "Verification failed");
+        }
+    };
+
+    const handleChangePassword = async (e: React.FormEvent) => {
+        e.preventDefault();
+        try {
+            const res = await api.post("/account/password", { currentPassword: password, newPassword });
+            // Changing the password revokes the old session token.
+            updateStoredUser({ sessionToken: res.data.sessionToken, mustChangePassword: false });
+            await continueSession(JSON.parse(localStorage.getItem("user") || "{}"));
+        } catch (err: any) {
+            setError(err.response?.data?.error || "Failed to change password");
+        }
+    };
+
+    const handleEnroll = async (e: React.FormEvent) => {
+        e.preventDefault();
+        try {
+            const res = await api.post("/admin/mfa/verify", { code });
+            updateStoredUser({ mfaEnrollmentRequired: false });
+            setRecoveryCodes(res.data.recoveryCodes);
+            setError("");
+            setStep("recoveryCodes");
+        } catch (err: any) {
+            setError(err.response?.data?.error || "Verification failed");
+        }
+    };
+
     return (
     <div className="min-h-screen flex items-center justify-center bg-slate-50 py-12 px-4 sm:px-6 lg:px-8">
       <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-md border border-slate-100">
@@ -43,37 +122,60 @@ const LoginAdminPage = () => {
 
         {error && <div className="bg-red-50 text-red-600 p-4 rounded-xl border border-red-100 text-sm mb-6">{error}</div>}
 
+        {step === "login" && (
         <form onSubmit={handleLogin} className="space-y-5">
             <div>
                 <label className="block text-sm font-medium text-slate-700 mb-1">Email</label>
-                <input 
-                    type="email" 
-                    value={email} 
-                    onChange={(e) => setEmail(e.target.value)} 
-                    required
-                    className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 focus:border-transparent outline-none transition-all text-sm"
-                    placeholder="email"
-                />
+                <input type="email" value={email} onChange={(e) => setEmail(e.target.value)} required className={inputClass} placeholder="email" />
             </div>
             <div>
                 <label className="block text-sm font-medium text-slate-700 mb-1">Password</label>
-                <input 
-                    type="password" 
-                    value={password} 
-                    onChange={(e) => setPassword(e.target.value)} 
-                    required
-                    className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 focus:border-transparent outline-none transition-all text-sm"
-                    placeholder="••••••••"
-                />
+                <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} required className={inputClass} placeholder="••••••••" />
             </div>
+            <button type="submit" className={buttonClass}>Start Admin Session</button>
+        </form>
+        )}
+
+        {step === "mfa" && (
+        <form onSubmit={handleMfa} className="space-y-5">
+            <p className="text-sm text-slate-600">Enter the 6-digit code from your authenticator app, or one of your recovery codes.</p>
+            <input type="text" value={code} onChange={(e) => setCode(e.target.value.trim())} required autoFocus className={inputClass} placeholder="123456" />
+            <button type="submit" className={buttonClass}>Verify</button>
+        </form>
+        )}
+
+        {step === "changePassword" && (
+        <form onSubmit={handleChangePassword} className="space-y-5">
+            <p className="text-sm text-slate-600">This account uses a temporary password. Choose a new one to continue.</p>
+            <div>
+                <label className="block text-sm font-medium text-slate-700 mb-1">New Password</label>
+                <input type="password" value={newPassword} onChange={(e) => setNewPassword(e.target.value)} required minLength={8} className={inputClass} placeholder="••••••••" />
+            </div>
+            <button type="submit" className={buttonClass}>Update Password</button>
+        </form>
+        )}
 
-            <button 
-                type="submit" 
-                className="w-full py-3 bg-slate-900 hover:bg-emerald-600 text-white font-semibold rounded-xl shadow-lg shadow-slate-900/20 hover:shadow-emerald-600/20 transition-all transform active:scale-95"
-            >
-                Start Admin Session
-            </button>
+        {step === "enroll" && enrollment && (
+        <form onSubmit={handleEnroll} className="space-y-5">
+            <p className="text-sm text-slate-600">Two-factor authentication is required. Add this account to your authenticator app, then enter the code it shows.</p>
+            <div className="bg-slate-50 border border-slate-200 rounded-xl p-4 text-xs font-mono break-all">
+                <p className="mb-2"><span className="font-semibold">Secret:</span> {enrollment.secret}</p>
+                <a href={enrollment.provisioningUri} className="text-emerald-600 hover:underline">{enrollment.provisioningUri}</a>
+            </div>
+            <input type="text" value={code} onChange={(e) => setCode(e.target.value.trim())} required className={inputClass} placeholder="123456" />
+            <button type="submit" className={buttonClass}>Enable Two-Factor</button>
         </form>
+        )}
+
+        {step === "recoveryCodes" && (
+        <div className="space-y-5">
+            <p className="text-sm text-slate-600">Save these recovery codes somewhere safe. Each can be used once if you lose your authenticator. They will not be shown again.</p>
+            <ul className="bg-slate-50 border border-slate-200 rounded-xl p-4 text-sm font-mono grid grid-cols-1 gap-1">
+                {recoveryCodes.map((c) => <li key={c}>{c}</li>)}
+            </ul>
+            <button type="button" onClick={() => navigate("/admin")} className={buttonClass}>Continue to Dashboard</button>
+        </div>
+        )}
       </div>
     </div>
     );
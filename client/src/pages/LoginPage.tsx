@@ -1,6 +1,6 @@
 import React, { useState, useEffect } from "react";
 import { useNavigate, Link, useLocation } from "react-router-dom";
-import api from "../api";
+import api, { STAFF_ROLES } from "../api";
 import { useToast } from "../contexts/ToastContext";
 import CountdownTimer from "../components/CountdownTimer";
 
@@ -12,6 +12,7 @@ const LoginPage = () => {
   const [token, setToken] = useState("");
   const [startTime, setStartTime] = useState<string | null>(null);
   const [endTime, setEndTime] = useState<string | null>(null);
+  const [sso, setSso] = useState<{ enabled: boolean; name: string } | null>(null);
   const navigate = useNavigate();
   const location = useLocation();
   const { success, error } = useToast();
@@ -30,11 +31,17 @@ const LoginPage = () => {
       }
     };
     fetchSettings();
+    api.get("/auth/oidc").then((res) => setSso(res.data)).catch(() => {});
+
+    const ssoError = new URLSearchParams(location.search).get("ssoError");
+    if (ssoError) {
+      error(ssoError);
+    }
 
     if (location.state?.message) {
       success(location.state.message);
     }
-  }, [location, success]);
+  }, [location, success, error]);
 
   const handleLogin = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -43,25 +50,33 @@ const LoginPage = () => {
       const user = response.data;
       localStorage.setItem("user", JSON.stringify(user));
 
-      if (user.Role === 'admin') {
+      if (STAFF_ROLES.includes(user.Role)) {
         success("Welcome back, Admin!");
         navigate("/admin");
       } else {
-        if (user.HasVoted) {
-          error("Account has already voted.");
-        } else {
-          success("Login successful!");
-          navigate("/verif");
-          // Or go to /vote if already verified?
-          // Logic usually: if verified -> vote, if pending -> wait/verif status page?
-          // For now, sending to verif as requested/implied flow
-        }
+        // Whether they already voted is per election; the voting page shows it.
+        success("Login successful!");
+        navigate("/verif");
       }
     } catch (err: any) {
       error(err.response?.data?.error || "Login failed. Check your credentials.");
     }
   };
 
+  // Emails a fresh voting token using the email and password typed above.
+  const handleReissueToken = async () => {
+    if (!email || !password) {
+      error("Isi email dan password terlebih dahulu.");
+      return;
+    }
+    try {
+      const res = await api.post("/token/reissue", { email, password });
+      success(res.data.message);
+    } catch (err: any) {
+      error(err.response?.data?.error || "Gagal mengirim token baru.");
+    }
+  };
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-slate-50">
       <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-md border border-slate-100">
@@ -128,10 +143,28 @@ const LoginPage = () => {
           </button>
         </form>
 
+        {sso?.enabled && (
+          <a
+            href={`${api.defaults.baseURL}/auth/oidc/login`}
+            className="mt-4 block w-full py-3 text-center bg-white border border-slate-200 hover:bg-slate-50 text-slate-700 font-semibold rounded-xl transition-all"
+          >
+            Masuk dengan {sso.name}
+          </a>
+        )}
+
         <div className="mt-8 text-center pt-6 border-t border-slate-100">
           <p className="text-sm text-slate-500">
             No account yet? <Link to="/register" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Create Account</Link>
           </p>
+          <p className="text-sm text-slate-500 mt-2">
+            <button type="button" onClick={handleReissueToken} className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Lost your token?</button>
+          </p>
+          <p className="text-sm text-slate-500 mt-2">
+            <Link to="/reset-password" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Forgot password?</Link>
+          </p>
+          <p className="text-sm text-slate-500 mt-2">
+            <Link to="/bulletin" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Check your ballot receipt</Link>
+          </p>
         </div>
       </div>
     </div>
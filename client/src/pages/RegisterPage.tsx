@@ -1,8 +1,48 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import api from '../api';
 import { useToast } from '../contexts/ToastContext';
 
+// NIM rules of the election open for registration, from /config.
+interface NIMRules {
+    prefixes: string[] | null;
+    length: number;
+    pattern: string;
+    cohortPosition: number;
+    cohortMin: number;
+    cohortMax: number;
+}
+
+// Mirrors the server's check so mistakes show before the photos are uploaded.
+const nimError = (rules: NIMRules, nim: string): string => {
+    if (!nim) return '';
+    if (rules.pattern) {
+        let re: RegExp;
+        try {
+            re = new RegExp(`^(?:${rules.pattern})$`);
+        } catch (err) {
+            return '';
+        }
+        if (!re.test(nim)) return 'Format NIM tidak sesuai ketentuan pemilihan ini';
+    } else if (!/^[0-9]+$/.test(nim)) {
+        return 'NIM hanya boleh berisi angka';
+    }
+    if (rules.length > 0 && nim.length !== rules.length) return `NIM harus terdiri dari ${rules.length} karakter`;
+    const prefixes = rules.prefixes || [];
+    if (prefixes.length > 0 && !prefixes.some((p) => nim.startsWith(p))) {
+        return `NIM harus diawali dengan ${prefixes.join(' atau ')}`;
+    }
+    if (rules.cohortPosition > 0) {
+        const yy = nim.slice(rules.cohortPosition - 1, rules.cohortPosition + 1);
+        if (!/^[0-9]{2}$/.test(yy)) return 'Angkatan tidak dapat dibaca dari NIM';
+        const year = 2000 + parseInt(yy, 10);
+        if ((rules.cohortMin && year < rules.cohortMin) || (rules.cohortMax && year > rules.cohortMax)) {
+            return `Angkatan ${year} tidak termasuk dalam pemilihan ini`;
+        }
+    }
+    return '';
+};
+
 const RegisterPage = () => {
     const navigate = useNavigate();
     const { success, error } = useToast();
@@ -15,6 +55,16 @@ const RegisterPage = () => {
     const [profileImg, setProfileImg] = useState<File | null>(null);
     const [ktmImg, setKtmImg] = useState<File | null>(null);
     const [loading, setLoading] = useState(false);
+    const [config, setConfig] = useState<{ electionId: number; name: string; nim: NIMRules } | null>(null);
+
+    useEffect(() => {
+        api.get('/config').then((res) => setConfig(res.data)).catch(() => setConfig(null));
+    }, []);
+
+    const nimMessage = config ? nimError(config.nim, formData.nim) : '';
+    const nimPlaceholder = config?.nim.prefixes?.length
+        ? config.nim.prefixes[0] + 'x'.repeat(Math.max(config.nim.length - config.nim.prefixes[0].length, 3))
+        : 'NIM';
 
     const handleInputChange = (e: React.ChangeEvent<HTMLInputElement>) => {
         setFormData({ ...formData, [e.target.name]: e.target.value });
@@ -29,6 +79,11 @@ const RegisterPage = () => {
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault();
 
+        if (nimMessage) {
+            error(nimMessage);
+            return;
+        }
+
         if (!profileImg || !ktmImg) {
             error('Please upload both Profile and KTM photos.');
             return;
@@ -36,6 +91,7 @@ const RegisterPage = () => {
 
         setLoading(true);
         const data = new FormData();
+        if (config) data.append('electionId', config.electionId.toString());
         data.append('name', formData.name);
         data.append('nim', formData.nim);
         data.append('email', formData.email);
@@ -47,7 +103,7 @@ const RegisterPage = () => {
             await api.post('/register', data, {
                 headers: { 'Content-Type': 'multipart/form-data' }
             });
-            success('Registration successful! Please login.');
+            success('Registration received! Check your email to confirm your address.');
             navigate('/login');
         } catch (err: any) {
             console.error(err);
@@ -64,7 +120,7 @@ const RegisterPage = () => {
             <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-lg border border-slate-100">
                 <div className="text-center mb-10">
                     <h2 className="text-3xl font-bold text-slate-900">Create Account</h2>
-                    <p className="text-slate-500 mt-2">Join the HMS Election 2025</p>
+                    <p className="text-slate-500 mt-2">{config ? `Join ${config.name}` : 'Join the HMS Election 2025'}</p>
                 </div>
 
                 <form onSubmit={handleSubmit} className="space-y-6">
@@ -81,8 +137,9 @@ const RegisterPage = () => {
                                 <label className="block text-sm font-medium text-slate-700 mb-1">NIM</label>
                                 <input type="text" name="nim" value={formData.nim} onChange={handleInputChange} required
                                     className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 outline-none transition-all"
-                                    placeholder="13321xxx"
+                                    placeholder={nimPlaceholder}
                                 />
+                                {nimMessage && <p className="text-xs text-red-500 mt-1">{nimMessage}</p>}
                             </div>
                             <div>
                                 <label className="block text-sm font-medium text-slate-700 mb-1">Email</label>
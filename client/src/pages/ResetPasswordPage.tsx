@@ -0,0 +1,97 @@
+import React, { useState } from 'react';
+import { useNavigate, Link, useSearchParams } from 'react-router-dom';
+import api from '../api';
+import { useToast } from '../contexts/ToastContext';
+
+// Handles both steps of the reset flow: requesting a link (no token in the URL)
+// and choosing a new password (token from the emailed link).
+const ResetPasswordPage = () => {
+    const navigate = useNavigate();
+    const [searchParams] = useSearchParams();
+    const token = searchParams.get('token');
+    const { success, error } = useToast();
+    const [email, setEmail] = useState('');
+    const [password, setPassword] = useState('');
+    const [loading, setLoading] = useState(false);
+
+    const handleRequest = async (e: React.FormEvent) => {
+        e.preventDefault();
+        setLoading(true);
+        try {
+            const res = await api.post('/password/forgot', { email });
+            success(res.data.message);
+        } catch (err: any) {
+            error(err.response?.data?.error || 'Gagal mengirim tautan reset');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const handleReset = async (e: React.FormEvent) => {
+        e.preventDefault();
+        setLoading(true);
+        try {
+            const res = await api.post('/password/reset', { token, password });
+            localStorage.removeItem('user');
+            navigate('/login', { state: { message: res.data.message } });
+        } catch (err: any) {
+            error(err.response?.data?.error || 'Gagal mengubah password');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-slate-50">
+            <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-md border border-slate-100">
+                <div className="text-center mb-8">
+                    <h2 className="text-3xl font-bold text-slate-900 tracking-tight">Reset Password</h2>
+                </div>
+
+                <form onSubmit={token ? handleReset : handleRequest} className="space-y-5">
+                    {token ? (
+                        <div>
+                            <label className="block text-sm font-medium text-slate-700 mb-1">New Password</label>
+                            <input
+                                type="password"
+                                value={password}
+                                onChange={(e) => setPassword(e.target.value)}
+                                required
+                                minLength={8}
+                                className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 focus:border-transparent outline-none transition-all text-sm"
+                                placeholder="••••••••"
+                            />
+                        </div>
+                    ) : (
+                        <div>
+                            <label className="block text-sm font-medium text-slate-700 mb-1">Email</label>
+                            <input
+                                type="email"
+                                value={email}
+                                onChange={(e) => setEmail(e.target.value)}
+                                required
+                                className="w-full px-4 py-3 bg-slate-50 border border-slate-200 rounded-xl focus:ring-2 focus:ring-emerald-500 focus:border-transparent outline-none transition-all text-sm"
+                                placeholder="email@gmail.com"
+                            />
+                        </div>
+                    )}
+                    <button
+                        type="submit"
+                        disabled={loading}
+                        className="w-full py-3 bg-emerald-600 hover:bg-emerald-700 text-white font-semibold rounded-xl shadow-lg shadow-emerald-600/20 transition-all transform active:scale-95 disabled:opacity-70"
+                    >
+                        {token ? 'Set New Password' : 'Send Reset Link'}
+                    </button>
+                </form>
+
+                <div className="mt-8 text-center pt-6 border-t border-slate-100">
+                    <p className="text-sm text-slate-500">
+                        <Link to="/login" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Back to Sign In</Link>
+                    </p>
+                </div>
+            </div>
+        </div>
+    );
+};
+
+export default ResetPasswordPage;
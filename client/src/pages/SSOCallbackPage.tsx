@@ -0,0 +1,58 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useNavigate } from 'react-router-dom';
+import api from '../api';
+import { useToast } from '../contexts/ToastContext';
+
+// Landing page after campus SSO: the server passes the session token in the
+// URL fragment so it never reaches server logs.
+const SSOCallbackPage = () => {
+    const navigate = useNavigate();
+    const { success, error } = useToast();
+    const [message, setMessage] = useState('Menyelesaikan login SSO...');
+
+    useEffect(() => {
+        const params = new URLSearchParams(window.location.hash.slice(1));
+        const sessionToken = params.get('sessionToken');
+        window.history.replaceState(null, '', window.location.pathname);
+        if (!sessionToken) {
+            setMessage('Login SSO gagal, silakan coba lagi.');
+            return;
+        }
+
+        const finish = async () => {
+            try {
+                const res = await api.get('/me', { headers: { Authorization: `Bearer ${sessionToken}` } });
+                const user = { ...res.data, sessionToken, expiresAt: params.get('expiresAt') };
+                localStorage.setItem('user', JSON.stringify(user));
+
+                if (user.VerificationStatus === 'approved' || user.VerificationStatus === 'none') {
+                    success('Login successful!');
+                    navigate('/verif');
+                } else if (user.VerificationStatus === 'rejected') {
+                    error('Verifikasi Anda ditolak. Silakan daftar ulang.');
+                    navigate('/register');
+                } else {
+                    success('Data Anda sedang diverifikasi admin.');
+                    navigate('/login');
+                }
+            } catch (err: any) {
+                setMessage(err.response?.data?.error || 'Login SSO gagal, silakan coba lagi.');
+            }
+        };
+        finish();
+    }, [navigate, success, error]);
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-slate-50">
+            <div className="bg-white p-10 rounded-2xl shadow-xl w-full max-w-md border border-slate-100 text-center">
+                <h2 className="text-3xl font-bold text-slate-900 tracking-tight mb-6">Campus SSO</h2>
+                <p className="text-slate-600">{message}</p>
+                <div className="mt-8 pt-6 border-t border-slate-100">
+                    <Link to="/login" className="text-emerald-600 hover:text-emerald-700 font-semibold hover:underline">Back to Sign In</Link>
+                </div>
+            </div>
+        </div>
+    );
+};
+
+export default SSOCallbackPage;
@@ -43,10 +43,15 @@ const VerifPage = () => {
         formData.append('ktm_image', ktmImg);
 
         try {
-            await api.post('/upload-verification', formData, {
+            const res = await api.post('/upload-verification', formData, {
                 headers: { 'Content-Type': 'multipart/form-data' }
             });
             success("Verification data uploaded successfully");
+            // SSO accounts without pre-verified identity wait for admin review first.
+            if (res.data.user?.VerificationStatus !== 'approved') {
+                navigate('/login', { state: { message: 'Data Anda sedang diverifikasi admin.' } });
+                return;
+            }
             navigate('/vote');
         } catch (err: any) {
             console.error(err);
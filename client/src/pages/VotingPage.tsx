@@ -1,28 +1,65 @@
-import React, { useEffect, useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import api from '../api';
 import Steps from '../components/Steps';
 import { useToast } from '../contexts/ToastContext';
 import { Ticket, AlertCircle, Clock, Calendar } from 'lucide-react';
 
+// One person of a candidate pair, in ballot order.
+interface CandidateMember {
+    ID: number;
+    Name: string;
+    NIM: string;
+    Role: string;
+}
+
 interface Candidate {
     ID: number;
+    BallotNumber: number | null;
     Name: string;
     Visi: string;
     Misi: string;
     ImageURL: string;
+    Members: CandidateMember[];
+    WithdrawnAt: string | null;
+}
+
+// One race or question on the ballot, from /contests.
+interface Contest {
+    ID: number;
+    Title: string;
+    Kind: 'candidates' | 'referendum';
+    Method: 'plurality' | 'irv';
+    MaxChoices: number;
+    KotakKosong: boolean;
+    Candidates: Candidate[];
 }
 
+// Chosen candidate IDs per contest ID, in order of preference; 0 is Kotak Kosong.
+type Selections = Record<number, number[]>;
+
 interface User {
     ID: number;
+}
+
+// An election the voter may vote in, from /me/elections.
+interface Election {
+    ID: number;
+    Name: string;
+    Phase: string;
+    StartTime: string | null;
     HasVoted: boolean;
 }
 
 const VotingPage = () => {
-    const [candidates, setCandidates] = useState<Candidate[]>([]);
+    const [contests, setContests] = useState<Contest[]>([]);
     const [user, setUser] = useState<User | null>(null);
-    const [selectedCandidate, setSelectedCandidate] = useState<number | null>(null);
-    const [selectedCandidateName, setSelectedCandidateName] = useState<string>("");
+    const [elections, setElections] = useState<Election[]>([]);
+    const [election, setElection] = useState<Election | null>(null);
+    const [selections, setSelections] = useState<Selections>({});
+    // Option IDs per contest ID in the order this voter sees them, from their voting session
+    const [ballotOrder, setBallotOrder] = useState<Record<number, number[]>>({});
+    const [confirming, setConfirming] = useState(false);
     const [voting, setVoting] = useState(false);
 
     // Election Status State
@@ -30,8 +67,13 @@ const VotingPage = () => {
     const [isElectionOpen, setIsElectionOpen] = useState(false);
     const [loadingSettings, setLoadingSettings] = useState(true);
 
-    // Timer State
+    // Voting session issued by the server; its nonce must accompany the ballot
     const [timeLeft, setTimeLeft] = useState(300); // 5 minutes default
+    const [sessionNonce, setSessionNonce] = useState('');
+    const [sessionExpired, setSessionExpired] = useState(false);
+    // Sent with every attempt at this session's ballot, so a retry after a
+    // dropped response gets the original receipt instead of an error
+    const idempotencyKey = useRef('');
 
     const navigate = useNavigate();
     const { success, error: showError } = useToast();
@@ -49,21 +91,25 @@ const VotingPage = () => {
 
     const fetchInitialData = async () => {
         try {
-            // Fetch Candidates
-            const candRes = await api.get('/candidates');
-            setCandidates(candRes.data);
-
-            // Fetch Settings for Timing
-            const setRes = await api.get('/settings');
-            const settings = setRes.data;
-            if (settings.startTime) {
-                const start = new Date(settings.startTime);
-                setElectionStart(start);
-                checkElectionStatus(start);
-            } else {
-                // If no start time set, assume open
-                setIsElectionOpen(true);
-            }
+            // Elections this voter takes part in; vote in them one at a time
+            const elRes = await api.get('/me/elections');
+            const mine: Election[] = elRes.data || [];
+            const next = mine.find((e) => !e.HasVoted) || mine[0] || null;
+            setElections(mine);
+            setElection(next);
+            if (!next) return;
+
+            // Fetch the ballot: every contest with its candidates, less those who withdrew
+            const contestRes = await api.get('/contests', { params: { electionId: next.ID } });
+            setContests(((contestRes.data || []) as Contest[]).map((contest) => ({
+                ...contest,
+                Candidates: contest.Candidates.filter((cand) => !cand.WithdrawnAt),
+            })));
+            setSelections({});
+
+            // The server tracks the election's phase; ballots are only taken while voting
+            if (next.StartTime) setElectionStart(new Date(next.StartTime));
+            setIsElectionOpen(next.Phase === 'voting');
         } catch (error) {
             console.error('Error fetching data:', error);
             // If error fetching settings, default to open to avoid blocking if not configured
@@ -73,47 +119,32 @@ const VotingPage = () => {
         }
     };
 
-    const checkElectionStatus = (start: Date) => {
-        const now = new Date();
-        if (now >= start) {
-            setIsElectionOpen(true);
-        } else {
-            setIsElectionOpen(false);
-        }
-    };
-
     // 2. Persistent Timer Logic (Server Synced)
     useEffect(() => {
-        if (!isElectionOpen || !user || user.HasVoted) return;
+        if (!isElectionOpen || !user || !election || election.HasVoted) return;
 
-        const VOTING_DURATION_SEC = 5 * 60; // 5 minutes
         let timerId: ReturnType<typeof setInterval>;
 
         const initializeTimer = async () => {
             try {
-                // Always fetch/sync with server first
-                const res = await api.post('/enter-voting', { userId: user.ID });
-                
-                // Server returns the fixed entry time (either existing or just created)
-                const serverEntryTimeStr = res.data.entryTime;
-                if (!serverEntryTimeStr) {
-                    console.error("No entry time returned from server");
-                    return;
-                }
+                // Starts the session, or resumes it with a fresh nonce after a refresh
+                const res = await api.post('/enter-voting', {}, { params: { electionId: election.ID } });
+                setSessionNonce(res.data.sessionNonce);
+                setBallotOrder(res.data.order || {});
+                if (!idempotencyKey.current) idempotencyKey.current = crypto.randomUUID();
+                setSessionExpired(false);
+
+                // Count down on the server's clock, not the device's
+                const offsetMs = new Date(res.data.serverTime).getTime() - Date.now();
+                const expiresMs = new Date(res.data.expiresAt).getTime();
 
-                const entryTimeMs = new Date(serverEntryTimeStr).getTime();
-                
-                // Function to update remaining time
                 const updateTimer = () => {
-                    const now = Date.now();
-                    // Calculate elapsed time since the SERVER entry time
-                    const elapsedSec = Math.floor((now - entryTimeMs) / 1000);
-                    const remaining = VOTING_DURATION_SEC - elapsedSec;
+                    const remaining = Math.floor((expiresMs - (Date.now() + offsetMs)) / 1000);
 
                     if (remaining <= 0) {
                         setTimeLeft(0);
                         clearInterval(timerId); // Stop counting
-                        handleAutoAbstain(); // Trigger auto-vote
+                        handleAutoAbstain(res.data.sessionNonce); // Trigger auto-vote
                     } else {
                         setTimeLeft(remaining);
                     }
@@ -125,9 +156,13 @@ const VotingPage = () => {
                 // Start interval
                 timerId = setInterval(updateTimer, 1000);
 
-            } catch (error) {
-                console.error("Failed to sync voting entry time:", error);
-                showError("Gagal menyinkronkan waktu voting. Silakan refresh halaman.");
+            } catch (error: any) {
+                if (error.response?.data?.sessionExpired) {
+                    setSessionExpired(true);
+                    return;
+                }
+                console.error("Failed to start voting session:", error);
+                showError(error.response?.data?.error || "Gagal memulai sesi pemungutan suara. Silakan refresh halaman.");
             }
         };
 
@@ -136,33 +171,101 @@ const VotingPage = () => {
         return () => {
             if (timerId) clearInterval(timerId);
         };
-    }, [isElectionOpen, user]);
+    }, [isElectionOpen, user, election]);
 
-    const handleAutoAbstain = () => {
+    const handleAutoAbstain = (nonce: string) => {
         // Prevent double submission if already voting
         if (voting) return;
 
         console.log("Timer expired. Auto-voting for Kotak Kosong.");
-        handleSubmitVote(0, true); // 0 = Kotak Kosong
+        const abstain: Selections = {};
+        contests.forEach((contest) => {
+            if (contest.KotakKosong) abstain[contest.ID] = [0]; // 0 = Kotak Kosong
+        });
+        handleSubmitVote(abstain, true, nonce);
+    };
+
+    // Picks or unpicks an option. Single-choice contests swap the choice,
+    // and Kotak Kosong never combines with other options except on a ranked
+    // ballot, where clicks add the next preference.
+    const handleVoteClick = (contest: Contest, candidateId: number) => {
+        setSelections((prev) => {
+            const current = prev[contest.ID] || [];
+            let next: number[];
+            if (current.includes(candidateId)) {
+                next = current.filter((id) => id !== candidateId);
+            } else if (contest.Method === 'irv') {
+                if (current.length >= contest.MaxChoices) return prev;
+                next = [...current, candidateId];
+            } else if (candidateId === 0 || contest.MaxChoices === 1) {
+                next = [candidateId];
+            } else {
+                next = [...current.filter((id) => id !== 0), candidateId];
+                if (next.length > contest.MaxChoices) return prev;
+            }
+            return { ...prev, [contest.ID]: next };
+        });
     };
 
-    const handleVoteClick = (candidateId: number, candidateName: string) => {
-        setSelectedCandidate(candidateId);
-        setSelectedCandidateName(candidateName);
+    // Where an option sits on this voter's ballot; Kotak Kosong (0) is last unless the session says otherwise.
+    const optionPosition = (contest: Contest, id: number) => {
+        const order = ballotOrder[contest.ID];
+        const position = order ? order.indexOf(id) : -1;
+        if (position >= 0) return position;
+        return id === 0 ? contest.Candidates.length : contest.Candidates.findIndex((cand) => cand.ID === id);
     };
 
-    const handleSubmitVote = async (candidateId: number, isAuto: boolean = false) => {
-        if (!user) return;
+    const orderedCandidates = (contest: Contest) =>
+        [...contest.Candidates].sort((a, b) => optionPosition(contest, a.ID) - optionPosition(contest, b.ID));
+
+    const isSelected = (contest: Contest, candidateId: number) =>
+        (selections[contest.ID] || []).includes(candidateId);
+
+    const rankOf = (contest: Contest, candidateId: number) =>
+        (selections[contest.ID] || []).indexOf(candidateId) + 1;
+
+    const ballotComplete = contests.length > 0 && contests.every((contest) => (selections[contest.ID] || []).length > 0);
+
+    const choiceNames = (contest: Contest) =>
+        (selections[contest.ID] || [])
+            .map((id) => (id === 0 ? 'Kotak Kosong' : contest.Candidates.find((cand) => cand.ID === id)?.Name || ''))
+            .join(contest.Method === 'irv' ? ' > ' : ', ');
+
+    // Retries when the connection drops; the server answers a repeat with the first result
+    const postBallot = async (data: FormData, attempt: number = 1): Promise<any> => {
+        try {
+            return await api.post('/vote', data, { headers: { 'Idempotency-Key': idempotencyKey.current } });
+        } catch (err: any) {
+            if (err.response || attempt >= 3) throw err;
+            await new Promise((resolve) => setTimeout(resolve, 1000 * attempt));
+            return postBallot(data, attempt + 1);
+        }
+    };
+
+    const handleSubmitVote = async (ballot: Selections, isAuto: boolean = false, nonce: string = sessionNonce) => {
+        if (!user || !election) return;
         setVoting(true);
         const data = new FormData();
         data.append('userId', user.ID.toString());
-        data.append('candidateId', candidateId.toString());
+        data.append('electionId', election.ID.toString());
+        data.append('selections', JSON.stringify(ballot));
+        data.append('sessionNonce', nonce);
 
         try {
-            await api.post('/vote', data);
+            const res = await postBallot(data);
+            idempotencyKey.current = '';
+
+            // Other elections still waiting for this voter: move on to the next
+            if (elections.some((e) => e.ID !== election.ID && !e.HasVoted)) {
+                success(`Suara untuk ${election.Name} terkirim. Kode tanda terima: ${res.data.receipt}`);
+                setConfirming(false);
+                setVoting(false);
+                setTimeLeft(300);
+                fetchInitialData();
+                return;
+            }
 
-            // Clear timer storage
-            localStorage.removeItem(`vote_entry_time_${user.ID}`);
+            await api.post('/logout').catch(() => {});
             localStorage.removeItem('user');
 
             const msg = isAuto
@@ -172,19 +275,21 @@ const VotingPage = () => {
             if (isAuto) showError(msg);
             else success(msg);
 
-            navigate('/login', { state: { message: msg } });
+            navigate('/bulletin', { state: { receipt: res.data.receipt, electionId: election.ID } });
 
         } catch (error: any) {
             console.error('Error voting:', error);
+            if (error.response?.data?.sessionExpired) setSessionExpired(true);
             showError(error.response?.data?.error || 'Gagal mengirim suara.');
+            setConfirming(false);
             setVoting(false);
         }
     };
 
     const handleConfirmVote = async (e: React.FormEvent) => {
         e.preventDefault();
-        if (selectedCandidate === null) return;
-        await handleSubmitVote(selectedCandidate);
+        if (!ballotComplete) return;
+        await handleSubmitVote(selections);
     };
 
     // Format Time Display
@@ -204,7 +309,8 @@ const VotingPage = () => {
     }
 
     // Render Waiting for Election
-    if (!isElectionOpen && electionStart) {
+    if (!isElectionOpen && election && !election.HasVoted) {
+        const closed = ['counting', 'published', 'archived'].includes(election.Phase);
         return (
             <div className="min-h-screen bg-slate-50 p-6 md:p-12 font-sans">
                 <div className="max-w-4xl mx-auto">
@@ -214,18 +320,26 @@ const VotingPage = () => {
                         <div className="w-24 h-24 bg-amber-100 text-amber-600 rounded-full flex items-center justify-center mx-auto mb-6 animate-pulse">
                             <Calendar size={48} />
                         </div>
-                        <h1 className="text-3xl font-bold text-slate-800 mb-4">Election Has Not Started</h1>
+                        <h1 className="text-3xl font-bold text-slate-800 mb-4">
+                            {closed ? 'Voting Has Closed' : 'Election Has Not Started'}
+                        </h1>
                         <p className="text-slate-500 text-lg mb-8">
-                            Please wait until the election officially begins on:
+                            {closed
+                                ? 'Ballots are no longer accepted for this election.'
+                                : electionStart
+                                    ? 'Please wait until the election officially begins on:'
+                                    : 'Please wait until the committee opens voting.'}
                         </p>
-                        <div className="bg-slate-50 py-4 px-8 rounded-2xl inline-block border border-slate-200">
-                            <span className="text-2xl font-mono font-bold text-slate-700">
-                                {electionStart.toLocaleString('id-ID', {
-                                    dateStyle: 'full',
-                                    timeStyle: 'short'
-                                })}
-                            </span>
-                        </div>
+                        {!closed && electionStart && (
+                            <div className="bg-slate-50 py-4 px-8 rounded-2xl inline-block border border-slate-200">
+                                <span className="text-2xl font-mono font-bold text-slate-700">
+                                    {electionStart.toLocaleString('id-ID', {
+                                        dateStyle: 'full',
+                                        timeStyle: 'short'
+                                    })}
+                                </span>
+                            </div>
+                        )}
                         <p className="mt-8 text-sm text-slate-400">
                             You can refresh this page when the time comes.
                         </p>
@@ -241,13 +355,41 @@ const VotingPage = () => {
         );
     }
 
+    // Render Expired Session
+    if (sessionExpired && election && !election.HasVoted) {
+        return (
+            <div className="min-h-screen bg-slate-50 p-6 md:p-12 font-sans">
+                <div className="max-w-4xl mx-auto">
+                    <Steps currentStep={2} />
+
+                    <div className="mt-12 bg-white p-12 rounded-[2.5rem] shadow-xl text-center border border-slate-100 max-w-2xl mx-auto">
+                        <div className="w-24 h-24 bg-red-100 text-red-600 rounded-full flex items-center justify-center mx-auto mb-6">
+                            <Clock size={48} />
+                        </div>
+                        <h1 className="text-3xl font-bold text-slate-800 mb-4">Voting Session Expired</h1>
+                        <p className="text-slate-500 text-lg mb-8">
+                            Your time in the voting booth ran out before a ballot was received. If your connection failed,
+                            contact the committee; they may grant you a new session.
+                        </p>
+                        <button
+                            onClick={() => window.location.reload()}
+                            className="mt-6 px-6 py-2 bg-emerald-600 text-white rounded-full font-bold hover:bg-emerald-700 transition"
+                        >
+                            Refresh Page
+                        </button>
+                    </div>
+                </div>
+            </div>
+        );
+    }
+
     return (
         <div className="min-h-screen bg-slate-50 p-6 md:p-12 font-sans">
             <div className="max-w-7xl mx-auto">
                 <Steps currentStep={2} />
 
                 {/* Timer Display */}
-                {!user?.HasVoted && timeLeft > 0 && (
+                {election && !election.HasVoted && timeLeft > 0 && (
                     <div className="fixed top-4 right-4 z-50 animate-bounce-in">
                         <div className={`flex items-center gap-2 px-4 py-2 rounded-full font-mono font-bold shadow-lg border-2 ${timeLeft < 60 ? 'bg-red-50 text-red-600 border-red-200 animate-pulse' : 'bg-white text-slate-700 border-slate-200'
                             }`}>
@@ -261,12 +403,17 @@ const VotingPage = () => {
                     <span className="bg-emerald-100 text-emerald-800 text-xs font-bold px-3 py-1 rounded-full uppercase tracking-wider mb-2 inline-block">Official Ballot</span>
                     <h1 className="text-4xl md:text-5xl font-extrabold text-slate-900 mb-4 tracking-tight">Cast Your Vote</h1>
                     <p className="text-slate-500 max-w-2xl mx-auto text-lg leading-relaxed">
-                        Select your preferred candidate for the <strong>Chairman of BP HMS 2025/2026</strong>.
+                        Make your choices on every part of the ballot for <strong>{election?.Name || 'the election'}</strong>.
                         Choose wisely, as your vote cannot be changed once submitted.
                     </p>
                 </div>
 
-                {user?.HasVoted ? (
+                {!election ? (
+                    <div className="bg-white border border-slate-200 p-12 rounded-3xl text-center max-w-2xl mx-auto shadow-sm">
+                        <h2 className="text-2xl font-bold text-slate-800 mb-2">No Election Available</h2>
+                        <p className="text-slate-500">Anda tidak terdaftar sebagai pemilih pada pemilihan yang sedang berlangsung.</p>
+                    </div>
+                ) : election.HasVoted ? (
                     <div className="bg-emerald-50 border border-emerald-200 p-12 rounded-3xl text-center max-w-2xl mx-auto shadow-sm">
                         <div className="w-16 h-16 bg-emerald-100 text-emerald-600 rounded-full flex items-center justify-center mx-auto mb-4">
                             <Ticket size={32} />
@@ -275,85 +422,150 @@ const VotingPage = () => {
                         <p className="text-emerald-700">Terima kasih! Suara Anda telah direkam.</p>
                     </div>
                 ) : (
-                    <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-8 px-4 md:px-0">
-                        {candidates.map((candidate, index) => (
-                            <div
-                                key={candidate.ID}
-                                className="bg-white rounded-[2rem] shadow-xl shadow-slate-200/60 overflow-hidden flex flex-col hover:shadow-2xl hover:shadow-emerald-100/50 hover:-translate-y-2 transition-all duration-300 border border-slate-100 relative group"
-                                style={{ animationDelay: `${index * 0.1}s` }}
-                            >
-                                <div className="absolute top-0 w-full h-32 bg-gradient-to-b from-emerald-50 to-transparent z-0 opacity-0 group-hover:opacity-100 transition-opacity" />
-
-                                <div className="p-8 flex-1 flex flex-col items-center z-10">
-                                    <div className="w-48 h-48 rounded-full p-1.5 bg-gradient-to-tr from-emerald-400 to-emerald-600 shadow-lg mb-6 group-hover:scale-105 transition-transform duration-500">
-                                        <div className="w-full h-full rounded-full border-4 border-white overflow-hidden bg-slate-200 relative">
-                                            <img
-                                                src={
-                                                    candidate.ImageURL?.startsWith('http')
-                                                        ? candidate.ImageURL
-                                                        : `${process.env.REACT_APP_API_URL || 'http://localhost:8080'}${candidate.ImageURL}`
-                                                }
-                                                alt={candidate.Name}
-                                                className="w-full h-full object-cover"
-                                            />
-                                        </div>
-                                    </div>
-
-                                    <h3 className="text-2xl font-bold text-slate-900 mb-6 text-center leading-tight">{candidate.Name}</h3>
-
-                                    <div className="w-full space-y-4 mb-4">
-                                        <div className="bg-slate-50 p-4 rounded-2xl border border-slate-100 hover:border-emerald-100 transition-colors">
-                                            <h4 className="text-xs font-bold text-emerald-600 uppercase tracking-wider mb-2 text-center">Vision</h4>
-                                            <p className="text-sm text-slate-600 text-center leading-relaxed line-clamp-3">{candidate.Visi || '-'}</p>
-                                        </div>
-                                        <div className="bg-slate-50 p-4 rounded-2xl border border-slate-100 hover:border-emerald-100 transition-colors">
-                                            <h4 className="text-xs font-bold text-emerald-600 uppercase tracking-wider mb-2 text-center">Mission</h4>
-                                            <p className="text-sm text-slate-600 text-center leading-relaxed line-clamp-3">{candidate.Misi || '-'}</p>
-                                        </div>
-                                    </div>
+                    <div className="space-y-16">
+                        {contests.map((contest) => (
+                            <section key={contest.ID} className="animate-fade-in-up">
+                                <div className="flex flex-wrap items-end justify-between gap-2 mb-6 px-4 md:px-0">
+                                    <h2 className="text-2xl md:text-3xl font-bold text-slate-900">{contest.Title}</h2>
+                                    <span className="text-sm font-bold text-slate-500">
+                                        {contest.Method === 'irv'
+                                            ? `Urutkan hingga ${contest.MaxChoices} pilihan sesuai preferensi Anda`
+                                            : contest.MaxChoices > 1
+                                            ? `Pilih hingga ${contest.MaxChoices} (${(selections[contest.ID] || []).length} dipilih)`
+                                            : 'Pilih satu'}
+                                    </span>
                                 </div>
 
-                                <button
-                                    onClick={() => handleVoteClick(candidate.ID, candidate.Name)}
-                                    className="w-full py-5 bg-slate-900 hover:bg-emerald-600 text-white font-bold text-lg transition-colors flex items-center justify-center gap-2 group-hover:pb-6"
-                                >
-                                    <Ticket size={20} className="opacity-50 group-hover:opacity-100" />
-                                    Vote Candidate
-                                </button>
-                            </div>
-                        ))}
-
-                        {/* Kotak Kosong */}
-                        <div className="bg-white rounded-[2rem] shadow-xl shadow-slate-200/60 overflow-hidden flex flex-col hover:shadow-2xl hover:shadow-slate-300/50 hover:-translate-y-2 transition-all duration-300 border border-slate-100 relative group">
-
-                            <div className="p-8 flex-1 flex flex-col items-center z-10">
-                                <div className="w-48 h-48 rounded-full p-1.5 bg-slate-200 shadow-inner mb-6 flex items-center justify-center group-hover:bg-slate-300 transition-colors">
-                                    <div className="w-full h-full rounded-full border-4 border-white bg-slate-100 flex items-center justify-center overflow-hidden">
-                                        <img className="w-24 h-24 rounded-lg" src="./kotakkosong.png"></img>
+                                {contest.Kind === 'referendum' ? (
+                                    <div className="grid grid-cols-1 md:grid-cols-2 gap-6 px-4 md:px-0 max-w-3xl">
+                                        {contest.Candidates.map((option) => (
+                                            <button
+                                                key={option.ID}
+                                                onClick={() => handleVoteClick(contest, option.ID)}
+                                                className={`py-8 rounded-[2rem] text-xl font-bold shadow-xl shadow-slate-200/60 border transition-all ${isSelected(contest, option.ID)
+                                                    ? 'bg-emerald-600 text-white border-emerald-600'
+                                                    : 'bg-white text-slate-800 border-slate-100 hover:border-emerald-300'}`}
+                                            >
+                                                {option.Name}
+                                            </button>
+                                        ))}
                                     </div>
-                                </div>
-
-                                <h2 className="text-2xl font-bold text-slate-900 mb-4 text-center">Abstain / Empty Box</h2>
-                                <p className="text-slate-500 text-sm text-center mb-6 leading-relaxed px-4">
-                                    By selecting this option, you verify your attendance but choose not to vote for any of the available candidates.
-                                </p>
-                            </div>
+                                ) : (
+                                    <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-8 px-4 md:px-0">
+                                        {orderedCandidates(contest).map((candidate, index) => (
+                                            <div
+                                                key={candidate.ID}
+                                                className={`bg-white rounded-[2rem] shadow-xl shadow-slate-200/60 overflow-hidden flex flex-col hover:shadow-2xl hover:shadow-emerald-100/50 hover:-translate-y-2 transition-all duration-300 border relative group ${isSelected(contest, candidate.ID) ? 'border-emerald-500 ring-4 ring-emerald-200' : 'border-slate-100'}`}
+                                                style={{ animationDelay: `${index * 0.1}s`, order: optionPosition(contest, candidate.ID) }}
+                                            >
+                                                <div className="absolute top-0 w-full h-32 bg-gradient-to-b from-emerald-50 to-transparent z-0 opacity-0 group-hover:opacity-100 transition-opacity" />
+
+                                                <div className="p-8 flex-1 flex flex-col items-center z-10">
+                                                    {candidate.BallotNumber && (
+                                                        <span className="absolute top-6 left-6 w-12 h-12 rounded-full bg-emerald-600 text-white font-bold text-xl flex items-center justify-center shadow-lg">{candidate.BallotNumber}</span>
+                                                    )}
+                                                    <div className="w-48 h-48 rounded-full p-1.5 bg-gradient-to-tr from-emerald-400 to-emerald-600 shadow-lg mb-6 group-hover:scale-105 transition-transform duration-500">
+                                                        <div className="w-full h-full rounded-full border-4 border-white overflow-hidden bg-slate-200 relative">
+                                                            <img
+                                                                src={
+                                                                    candidate.ImageURL?.startsWith('http')
+                                                                        ? candidate.ImageURL
+                                                                        : `${process.env.REACT_APP_API_URL || 'http://localhost:8080'}${candidate.ImageURL}`
+                                                                }
+                                                                alt={candidate.Name}
+                                                                className="w-full h-full object-cover"
+                                                            />
+                                                        </div>
+                                                    </div>
+
+                                                    <h3 className="text-2xl font-bold text-slate-900 mb-6 text-center leading-tight">{candidate.Name}</h3>
+
+                                                    {candidate.Members && candidate.Members.length > 0 && (
+                                                        <ul className="w-full mb-6 space-y-1 text-center">
+                                                            {candidate.Members.map((m) => (
+                                                                <li key={m.ID} className="text-sm text-slate-600">
+                                                                    {m.Role && <span className="font-semibold text-emerald-600">{m.Role}: </span>}
+                                                                    {m.Name}
+                                                                </li>
+                                                            ))}
+                                                        </ul>
+                                                    )}
+
+                                                    <div className="w-full space-y-4 mb-4">
+                                                        <div className="bg-slate-50 p-4 rounded-2xl border border-slate-100 hover:border-emerald-100 transition-colors">
+                                                            <h4 className="text-xs font-bold text-emerald-600 uppercase tracking-wider mb-2 text-center">Vision</h4>
+                                                            <p className="text-sm text-slate-600 text-center leading-relaxed line-clamp-3">{candidate.Visi || '-'}</p>
+                                                        </div>
+                                                        <div className="bg-slate-50 p-4 rounded-2xl border border-slate-100 hover:border-emerald-100 transition-colors">
+                                                            <h4 className="text-xs font-bold text-emerald-600 uppercase tracking-wider mb-2 text-center">Mission</h4>
+                                                            <p className="text-sm text-slate-600 text-center leading-relaxed line-clamp-3">{candidate.Misi || '-'}</p>
+                                                        </div>
+                                                    </div>
+                                                </div>
+
+                                                <button
+                                                    onClick={() => handleVoteClick(contest, candidate.ID)}
+                                                    className={`w-full py-5 ${isSelected(contest, candidate.ID) ? 'bg-emerald-600' : 'bg-slate-900'} hover:bg-emerald-600 text-white font-bold text-lg transition-colors flex items-center justify-center gap-2 group-hover:pb-6`}
+                                                >
+                                                    <Ticket size={20} className="opacity-50 group-hover:opacity-100" />
+                                                    {contest.Method === 'irv'
+                                                        ? (isSelected(contest, candidate.ID) ? `Pilihan #${rankOf(contest, candidate.ID)}` : 'Rank Candidate')
+                                                        : (isSelected(contest, candidate.ID) ? 'Selected' : 'Vote Candidate')}
+                                                </button>
+                                            </div>
+                                        ))}
+
+                                        {/* Kotak Kosong */}
+                                        {contest.KotakKosong && (
+                                            <div
+                                                className={`bg-white rounded-[2rem] shadow-xl shadow-slate-200/60 overflow-hidden flex flex-col hover:shadow-2xl hover:shadow-slate-300/50 hover:-translate-y-2 transition-all duration-300 border relative group ${isSelected(contest, 0) ? 'border-slate-800 ring-4 ring-slate-300' : 'border-slate-100'}`}
+                                                style={{ order: optionPosition(contest, 0) }}
+                                            >
+
+                                                <div className="p-8 flex-1 flex flex-col items-center z-10">
+                                                    <div className="w-48 h-48 rounded-full p-1.5 bg-slate-200 shadow-inner mb-6 flex items-center justify-center group-hover:bg-slate-300 transition-colors">
+                                                        <div className="w-full h-full rounded-full border-4 border-white bg-slate-100 flex items-center justify-center overflow-hidden">
+                                                            <img className="w-24 h-24 rounded-lg" src="./kotakkosong.png"></img>
+                                                        </div>
+                                                    </div>
+
+                                                    <h2 className="text-2xl font-bold text-slate-900 mb-4 text-center">Abstain / Empty Box</h2>
+                                                    <p className="text-slate-500 text-sm text-center mb-6 leading-relaxed px-4">
+                                                        By selecting this option, you verify your attendance but choose not to vote for any of the available candidates.
+                                                    </p>
+                                                </div>
+
+                                                <button
+                                                    onClick={() => handleVoteClick(contest, 0)}
+                                                    className="w-full py-5 bg-slate-200 hover:bg-slate-800 hover:text-white text-slate-500 font-bold text-lg transition-all flex items-center justify-center gap-2 group-hover:pb-6 mt-auto"
+                                                >
+                                                    <span className="w-5 h-5 border-2 border-current rounded-sm"></span>
+                                                    {contest.Method === 'irv'
+                                                        ? (isSelected(contest, 0) ? `Pilihan #${rankOf(contest, 0)}` : 'Rank Empty Box')
+                                                        : (isSelected(contest, 0) ? 'Empty Box Selected' : 'Select Empty Box')}
+                                                </button>
+                                            </div>
+                                        )}
+                                    </div>
+                                )}
+                            </section>
+                        ))}
 
+                        <div className="flex justify-center">
                             <button
-                                onClick={() => handleVoteClick(0, "Kotak Kosong")}
-                                className="w-full py-5 bg-slate-200 hover:bg-slate-800 hover:text-white text-slate-500 font-bold text-lg transition-all flex items-center justify-center gap-2 group-hover:pb-6 mt-auto"
+                                onClick={() => setConfirming(true)}
+                                disabled={!ballotComplete}
+                                className="px-10 py-4 bg-emerald-600 hover:bg-emerald-700 text-white rounded-full font-bold text-lg shadow-lg shadow-emerald-200 transition-all disabled:opacity-50 disabled:cursor-not-allowed"
                             >
-                                <span className="w-5 h-5 border-2 border-current rounded-sm"></span>
-                                Select Empty Box
+                                Review Ballot
                             </button>
                         </div>
                     </div>
-
                 )}
             </div>
 
             {/* Voting Verification Modal */}
-            {selectedCandidate !== null && (
+            {confirming && (
                 <div className="fixed inset-0 bg-slate-900/40 backdrop-blur-sm flex items-center justify-center p-4 z-50 animate-fade-in">
                     <div className="bg-white rounded-3xl p-8 max-w-sm w-full shadow-2xl transform scale-100 animate-scale-in">
                         <div className="w-12 h-12 bg-emerald-100 text-emerald-600 rounded-full flex items-center justify-center mb-4 mx-auto">
@@ -361,8 +573,16 @@ const VotingPage = () => {
                         </div>
                         <h2 className="text-2xl font-bold mb-2 text-center text-slate-900">Confirm Vote</h2>
                         <p className="text-slate-500 mb-8 text-center leading-relaxed">
-                            Are you sure you want to cast your vote for <strong className="text-slate-900 block mt-1 text-lg">{selectedCandidateName}</strong>?
+                            Are you sure you want to cast this ballot?
                         </p>
+                        <ul className="mb-6 space-y-2 text-sm">
+                            {contests.map((contest) => (
+                                <li key={contest.ID} className="bg-slate-50 border border-slate-100 rounded-xl p-3">
+                                    <span className="block text-xs font-bold text-slate-400 uppercase tracking-wider">{contest.Title}</span>
+                                    <strong className="text-slate-900">{choiceNames(contest)}</strong>
+                                </li>
+                            ))}
+                        </ul>
 
                         <div className="bg-amber-50 border border-amber-100 p-3 rounded-xl flex items-start gap-3 mb-6">
                             <AlertCircle className="text-amber-500 shrink-0 mt-0.5" size={18} />
@@ -373,7 +593,7 @@ const VotingPage = () => {
                             <button type="submit" disabled={voting} className="w-full py-3.5 bg-emerald-600 hover:bg-emerald-700 rounded-xl font-bold text-white shadow-lg shadow-emerald-200 transition-all disabled:opacity-70">
                                 {voting ? 'Submitting...' : 'Yes, Submit Vote'}
                             </button>
-                            <button type="button" onClick={() => setSelectedCandidate(null)} className="w-full py-3.5 border border-slate-200 hover:bg-slate-50 rounded-xl font-bold text-slate-600 transition-colors">
+                            <button type="button" onClick={() => setConfirming(false)} className="w-full py-3.5 border border-slate-200 hover:bg-slate-50 rounded-xl font-bold text-slate-600 transition-colors">
                                 Cancel
                             </button>
                         </form>
@@ -0,0 +1,72 @@
+// Command electionkey creates the election key for threshold-encrypted
+// ballots and lets committee members check their shares.
+//
+//	go run ./cmd/electionkey generate -k 3 -n 5
+//	go run ./cmd/electionkey check -public-key <key> <share> <share> <share>
+//
+// generate prints the public key for ELECTION_PUBLIC_KEY and one share per
+// committee member. The private key is never written anywhere; after the
+// election closes, k members submit their shares to the tally ceremony.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"voting-backend/internal/threshold"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "generate":
+		fs := flag.NewFlagSet("generate", flag.ExitOnError)
+		k := fs.Int("k", 3, "shares needed to decrypt")
+		n := fs.Int("n", 5, "number of committee members")
+		fs.Parse(os.Args[2:])
+
+		pub, shares, err := threshold.GenerateKey(*k, *n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("ELECTION_PUBLIC_KEY=%s\n\n", threshold.EncodePublicKey(pub))
+		fmt.Printf("Give one share to each committee member; any %d of %d can decrypt.\n", *k, *n)
+		for i, s := range shares {
+			fmt.Printf("Share %d: %s\n", i+1, s)
+		}
+
+	case "check":
+		fs := flag.NewFlagSet("check", flag.ExitOnError)
+		pubKey := fs.String("public-key", os.Getenv("ELECTION_PUBLIC_KEY"), "election public key")
+		fs.Parse(os.Args[2:])
+
+		pub, err := threshold.ParsePublicKey(*pubKey)
+		if err != nil {
+			log.Fatal("invalid public key: ", err)
+		}
+		var shares []threshold.Share
+		for _, arg := range fs.Args() {
+			s, err := threshold.ParseShare(arg)
+			if err != nil {
+				log.Fatalf("invalid share %q: %v", arg, err)
+			}
+			shares = append(shares, s)
+		}
+		if _, err := threshold.RecoverKey(shares, pub); err != nil {
+			log.Fatal("check failed: ", err)
+		}
+		fmt.Println("OK: the shares recover the election key")
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	log.Fatal("usage: electionkey generate [-k 3] [-n 5] | electionkey check [-public-key KEY] SHARE...")
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"strings"
 	"time"
 	"voting-backend/internal/db"
-	"voting-backend/internal/email"
 	"voting-backend/internal/handlers"
 	"voting-backend/internal/models"
 
@@ -14,6 +15,10 @@ import (
 )
 
 func main() {
+	bootstrapEmail := flag.String("bootstrap-admin-email", "", "email for the first admin account, used only if no admin exists (default $ADMIN_BOOTSTRAP_EMAIL)")
+	bootstrapPassword := flag.String("bootstrap-admin-password", "", "initial password for the first admin account, must be changed on first login (default $ADMIN_BOOTSTRAP_PASSWORD)")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		if err := godotenv.Load("../../.env"); err != nil {
 			log.Println("No .env file found, relying on environment variables")
@@ -33,45 +38,78 @@ func main() {
 	}
 
 	// Auto-migrate models
-	err := db.DB.AutoMigrate(&models.User{}, &models.Candidate{}, &models.Vote{}, &models.Setting{})
+	err := db.DB.AutoMigrate(
+		&models.User{}, &models.Election{}, &models.ElectionVoter{}, &models.RollEntry{}, &models.VotingSession{}, &models.Contest{}, &models.Candidate{}, &models.CandidateMember{}, &models.BallotDraw{}, &models.Participation{}, &models.Ballot{}, &models.BallotChoice{}, &models.TallyShare{}, &models.Setting{},
+		&models.PasswordResetToken{}, &models.RecoveryCode{},
+		&models.LoginAttempt{}, &models.LoginThrottle{}, &models.Session{},
+	)
 	if err != nil {
 		log.Fatal("Failed to migrate database: ", err)
 	}
+	if err := handlers.MigrateVotingTokens(); err != nil {
+		log.Fatal("Failed to hash stored voting tokens: ", err)
+	}
+	if err := handlers.MigrateVotes(); err != nil {
+		log.Fatal("Failed to split votes into ballots: ", err)
+	}
+	if err := handlers.MigrateElections(); err != nil {
+		log.Fatal("Failed to move data into the first election: ", err)
+	}
+	if err := handlers.MigrateContests(); err != nil {
+		log.Fatal("Failed to split elections into contests: ", err)
+	}
+	if err := handlers.MigrateElectionPhases(); err != nil {
+		log.Fatal("Failed to introduce election phases: ", err)
+	}
+	if err := handlers.MigrateNIMRules(); err != nil {
+		log.Fatal("Failed to carry over the NIM format: ", err)
+	}
+	if err := handlers.MigrateVotingSessions(); err != nil {
+		log.Fatal("Failed to introduce voting sessions: ", err)
+	}
+	if err := handlers.MigrateUniqueParticipations(); err != nil {
+		log.Fatal("Failed to make participations unique: ", err)
+	}
+	if err := handlers.EncryptLegacyBallots(); err != nil {
+		log.Fatal("Failed to encrypt stored ballots: ", err)
+	}
+	if err := handlers.MigrateTallyShares(); err != nil {
+		log.Fatal("Failed to seal tally shares: ", err)
+	}
+
+	// Expire registrations whose email was never confirmed and drop old sessions
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			handlers.PurgeUnconfirmedRegistrations()
+			handlers.PurgeSessions()
+			<-ticker.C
+		}
+	}()
 
+	// Remind voters of elections starting within 24 hours
 	go func() {
 		ticker := time.NewTicker(30 * time.Minute)
 		defer ticker.Stop()
 
 		for {
 			<-ticker.C
-			var startSetting models.Setting
-			if err := db.DB.Where("key = ?", "startTime").First(&startSetting).Error; err == nil && startSetting.Value != "" {
-				startTime, err := time.Parse("2006-01-02T15:04", startSetting.Value)
-				if err == nil {
-					timeLeft := time.Until(startTime)
-					if timeLeft > 0 && timeLeft < 24*time.Hour {
-						var users []models.User
-						db.DB.Where("verification_status = ? AND reminder_sent = ?", "approved", false).Find(&users)
-
-						for _, u := range users {
-							if u.Email != "" && u.Token != "" {
-								err := email.SendReminderEmail(u.Email, u.Name, u.Token)
-								if err == nil {
-									db.DB.Model(&u).Update("reminder_sent", true)
-									log.Printf("Reminder sent to %s", u.Email)
-								} else {
-									log.Printf("Failed to send reminder to %s: %v", u.Email, err)
-								}
-							}
-						}
-					}
-				}
-			}
+			handlers.SendElectionReminders()
 		}
 	}()
 
 	r := gin.Default()
 
+	// Login lockouts count per client IP, so only trust forwarding headers from
+	// known proxies when TRUSTED_PROXIES is configured.
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := r.SetTrustedProxies(strings.Split(proxies, ",")); err != nil {
+			log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+		}
+	}
+
 	r.Static("/uploads", "./uploads")
 
 	// Setup CORS
@@ -86,7 +124,7 @@ func main() {
 		} else {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 		c.Writer.Header().Set("Access-Control-Max-Age", "43200")
 
@@ -98,46 +136,132 @@ func main() {
 		c.Next()
 	})
 
+	// Phases in which an election's ballot and rules may still change
+	setup := []handlers.Phase{handlers.PhaseDraft, handlers.PhaseRegistration, handlers.PhaseVerification}
+
 	// Routes
 	r.POST("/login", handlers.Login)
-	r.POST("/register", handlers.Register)
-	r.POST("/admin/login", handlers.AdminLogin) // Added Admin Login Logic
-
-	// Users Routes
-	api := r.Group("/")
-	api.GET("/candidates", handlers.GetCandidates)
-	api.POST("/vote", handlers.Vote)
-	api.POST("/enter-voting", handlers.EnterVoting) // New Route
-	api.GET("/settings", handlers.GetSettings)      // Public for countdown
-
-	r.GET("/admin/results", handlers.GetResults)
-
-	// Admin routes
-	r.GET("/admin/users/pending", handlers.GetPendingUsers)
-	r.GET("/admin/users", handlers.GetAllUsers)        // Added GetAllUsers
-	r.GET("/admin/users/search", handlers.SearchUsers) // Search users
-	r.POST("/admin/verify", handlers.VerifyUser)
-	r.POST("/admin/candidates", handlers.CreateCandidate)
-	r.DELETE("/admin/candidates/:id", handlers.DeleteCandidate) // Added
+	r.POST("/register", handlers.RequirePhase(handlers.RequestElection, handlers.PhaseRegistration), handlers.Register)
+	r.POST("/register/confirm", handlers.ConfirmEmail)
+	r.POST("/register/resend", handlers.ResendConfirmation)
+	r.POST("/admin/login", handlers.AdminLogin)         // Added Admin Login Logic
+	r.POST("/admin/login/mfa", handlers.AdminLoginMFA)  // Second step for admins with TOTP
+	r.POST("/password/forgot", handlers.ForgotPassword) // Voters and admins
+	r.POST("/password/reset", handlers.ResetPassword)
+	r.POST("/token/reissue", handlers.ReissueToken) // Voter lost their voting token
+
+	// Campus SSO (OpenID Connect), alongside the password login
+	r.GET("/auth/oidc", handlers.GetOIDCConfig)
+	r.GET("/auth/oidc/login", handlers.OIDCLogin)
+	r.GET("/auth/oidc/callback", handlers.OIDCCallback)
+
+	// Public routes
+	r.GET("/elections", handlers.GetElections)
+	r.GET("/candidates", handlers.GetCandidates)    // ?electionId=, default the current election
+	r.GET("/contests", handlers.GetContests)        // ?electionId=, default the current election
+	r.GET("/settings", handlers.GetSettings)        // Public for countdown
+	r.GET("/config", handlers.GetPublicConfig)      // NIM rules for the registration form
+	r.GET("/bulletin", handlers.GetBulletin)        // Receipt codes, once the election has closed
+	r.GET("/time", handlers.GetServerTime)          // Clock the voting countdown follows
+	r.GET("/ballot-draws", handlers.GetBallotDraws) // ?electionId=, seeds and results of ballot number draws
+
+	// Authenticated routes (any role)
+	authed := r.Group("/", handlers.RequireAuth())
+	authed.POST("/auth/refresh", handlers.RefreshSession)
+	authed.POST(handlers.ChangePasswordPath, handlers.ChangePassword)
+	authed.POST(handlers.LogoutPath, handlers.Logout)
+	authed.GET("/me", handlers.Me)
+
+	// Voter routes
+	voter := r.Group("/", handlers.RequireAuth(models.RoleVoter))
+	voter.POST("/vote", handlers.RequirePhase(handlers.RequestElection, handlers.PhaseVoting), handlers.Vote)
+	voter.POST("/enter-voting", handlers.RequirePhase(handlers.RequestElection, handlers.PhaseVoting), handlers.EnterVoting)
+	voter.GET("/me/elections", handlers.GetMyElections)
+	// Legacy flow used /upload-verification; Register now handles the photos.
+	voter.POST("/upload-verification", handlers.RequirePhase(handlers.RequestElection, handlers.PhaseRegistration, handlers.PhaseVerification), handlers.UploadVerification)
+
+	// Admin routes, each guarded by the permission it needs
+	admin := r.Group("/admin", handlers.RequireAuth(models.StaffRoles...))
+	admin.GET("/results", handlers.RequirePermission(handlers.PermViewResults), handlers.GetResults)
+	admin.GET("/users/pending", handlers.RequirePermission(handlers.PermViewUsers), handlers.GetPendingUsers)
+	admin.GET("/users", handlers.RequirePermission(handlers.PermViewUsers), handlers.GetAllUsers)
+	admin.GET("/users/search", handlers.RequirePermission(handlers.PermViewUsers), handlers.SearchUsers)
+	admin.POST("/verify", handlers.RequirePermission(handlers.PermVerifyUsers), handlers.VerifyUser)
+	admin.POST("/users/:id/reissue-token", handlers.RequirePermission(handlers.PermVerifyUsers), handlers.ReissueTokenByAdmin)
+	admin.POST("/users/:id/voting-session", handlers.RequirePermission(handlers.PermVerifyUsers), handlers.RequirePhase(handlers.RequestElection, handlers.PhaseVoting), handlers.GrantVotingSession)
+	admin.POST("/candidates", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.RequestElection, setup...), handlers.CreateCandidate)
+	admin.PUT("/candidates/:id", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.CandidateElection, setup...), handlers.UpdateCandidate)
+	admin.DELETE("/candidates/:id", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.CandidateElection, setup...), handlers.DeleteCandidate)
+	admin.POST("/candidates/:id/withdraw", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.CandidateElection, append(setup, handlers.PhaseVoting)...), handlers.WithdrawCandidate)
+	admin.POST("/candidates/:id/reinstate", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.CandidateElection, setup...), handlers.ReinstateCandidate)
+	admin.POST("/elections/:id/ballot-draw/commit", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.PathElection, setup...), handlers.CommitBallotDraw)
+	admin.POST("/elections/:id/ballot-draw", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.PathElection, setup...), handlers.DrawBallotNumbers)
+	admin.POST("/contests", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.RequestElection, setup...), handlers.CreateContest)
+	admin.PUT("/contests/:id", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.ContestElection, setup...), handlers.UpdateContest)
+	admin.DELETE("/contests/:id", handlers.RequirePermission(handlers.PermManageCandidates), handlers.RequirePhase(handlers.ContestElection, setup...), handlers.DeleteContest)
 
 	// Vote Logic V3 routes
-	r.GET("/admin/votes/pending", handlers.GetPendingVotes)
-	r.GET("/admin/votes/rejected", handlers.GetRejectedVotes) // Rejected votes
-	r.GET("/admin/votes/search", handlers.SearchVotes)  // Search votes
-	r.POST("/admin/votes/verify", handlers.ApproveVote) // Approve/Reject Vote
+	admin.GET("/votes/pending", handlers.RequirePermission(handlers.PermViewVotes), handlers.GetPendingVotes)
+	admin.GET("/votes/rejected", handlers.RequirePermission(handlers.PermViewVotes), handlers.GetRejectedVotes)
+	admin.GET("/votes/search", handlers.RequirePermission(handlers.PermViewVotes), handlers.SearchVotes)
+	admin.POST("/votes/verify", handlers.RequirePermission(handlers.PermReviewVotes), handlers.ApproveVote)
 
 	// Settings
-	r.POST("/admin/settings", handlers.SaveSettings) // Admin only
+	admin.POST("/settings", handlers.RequirePermission(handlers.PermManageSettings), handlers.RequirePhase(handlers.RequestElection, setup...), handlers.SaveSettings)
 
-	// Legacy or Specific upload route if needed, currently Register handles it.
-	// But let's keep it if existing frontend uses it?
-	// The request said "register page... requires photo". So new flow uses Register.
-	// Old flow used /upload-verification.
-	// I'll keep it just in case, but minimal impact.
-	r.POST("/upload-verification", handlers.UploadVerification)
+	// Elections; other admin routes take ?electionId= and default to the current one
+	admin.GET("/elections", handlers.GetAdminElections)
+	admin.POST("/elections", handlers.RequirePermission(handlers.PermManageSettings), handlers.CreateElection)
+	admin.PUT("/elections/:id", handlers.RequirePermission(handlers.PermManageSettings), handlers.RequirePhase(handlers.PathElection, setup...), handlers.UpdateElection)
+	admin.POST("/elections/:id/phase", handlers.RequirePermission(handlers.PermManageSettings), handlers.SetElectionPhase)
+	admin.POST("/elections/:id/archive", handlers.RequirePermission(handlers.PermManageSettings), handlers.SetElectionArchived)
 
-	// Seed admin account only
-	handlers.SeedAdmin() // Seed Admin Account
+	// Official voter roll; frozen once voting opens
+	admin.GET("/elections/:id/roll", handlers.RequirePermission(handlers.PermViewUsers), handlers.GetRoll)
+	admin.POST("/elections/:id/roll", handlers.RequirePermission(handlers.PermManageSettings), handlers.RequirePhase(handlers.PathElection, setup...), handlers.ImportRoll)
+	admin.DELETE("/elections/:id/roll", handlers.RequirePermission(handlers.PermManageSettings), handlers.RequirePhase(handlers.PathElection, setup...), handlers.DeleteRoll)
+
+	// Two-factor enrollment for the logged-in admin
+	admin.POST("/mfa/enroll", handlers.EnrollMFA)
+	admin.POST("/mfa/verify", handlers.VerifyMFAEnrollment)
+	admin.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes)
+
+	// Admin account management (super-admin only)
+	accounts := admin.Group("/accounts", handlers.RequirePermission(handlers.PermManageAdmins))
+	accounts.GET("", handlers.GetAdminAccounts)
+	accounts.POST("", handlers.CreateAdminAccount)
+	accounts.PUT("/:id", handlers.UpdateAdminRole)
+	accounts.POST("/:id/disable", handlers.SetAdminDisabled)
+	accounts.POST("/:id/mfa/reset", handlers.ResetAdminMFA)
+
+	// Login lockouts (super-admin only)
+	lockouts := admin.Group("", handlers.RequirePermission(handlers.PermManageLockouts))
+	lockouts.GET("/lockouts", handlers.GetLockouts)
+	lockouts.DELETE("/lockouts/:id", handlers.ClearLockout)
+	lockouts.GET("/login-attempts", handlers.GetLoginAttempts)
+
+	// Sessions (super-admin only)
+	sessions := admin.Group("", handlers.RequirePermission(handlers.PermManageSessions))
+	sessions.GET("/users/:id/sessions", handlers.GetUserSessions)
+	sessions.POST("/users/:id/sessions/revoke", handlers.RevokeUserSessions)
+	sessions.POST("/sessions/:sid/revoke", handlers.RevokeSession)
+
+	// Tally ceremony: committee members submit key shares after the election
+	tally := admin.Group("/tally", handlers.RequirePermission(handlers.PermTally))
+	tally.GET("", handlers.GetTallyStatus)
+	tally.POST("/shares", handlers.SubmitTallyShare)
+
+	// Legacy admin fixes run once, then the first admin is bootstrapped if needed
+	if err := handlers.MigrateLegacyAdmins(); err != nil {
+		log.Fatal("Failed to migrate legacy admin accounts: ", err)
+	}
+	if *bootstrapEmail == "" {
+		*bootstrapEmail = os.Getenv("ADMIN_BOOTSTRAP_EMAIL")
+	}
+	if *bootstrapPassword == "" {
+		*bootstrapPassword = os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	}
+	handlers.BootstrapAdmin(*bootstrapEmail, *bootstrapPassword)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,145 @@
+// Command votestress fires parallel ballots for one voter at a running
+// server, to check that casting stays race-free: however many requests race,
+// at most one ballot may be accepted.
+//
+//	go run ./cmd/votestress -url http://localhost:8080 -token <session token> -election 1 -selections '{"1":[2]}'
+//
+// The voter must be approved, eligible and not have voted yet. With
+// -same-key every request carries the same Idempotency-Key, as retries of
+// one ballot do, and all of them must get the same receipt; without it each
+// request is a different ballot and all but one must be refused.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type result struct {
+	status  int
+	receipt string
+	err     string
+}
+
+func main() {
+	log.SetFlags(0)
+	base := flag.String("url", "http://localhost:8080", "server URL")
+	token := flag.String("token", os.Getenv("VOTESTRESS_TOKEN"), "voter session token (default $VOTESTRESS_TOKEN)")
+	electionID := flag.Uint("election", 0, "election to vote in")
+	selections := flag.String("selections", "", `ballot as JSON, contest ID to option IDs, e.g. {"1":[2]}`)
+	n := flag.Int("n", 20, "parallel requests")
+	sameKey := flag.Bool("same-key", false, "send every request with the same Idempotency-Key")
+	flag.Parse()
+
+	if *token == "" || *electionID == 0 || *selections == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	election := strconv.FormatUint(uint64(*electionID), 10)
+
+	// One session in the booth, shared by every request.
+	var entered struct {
+		SessionNonce string `json:"sessionNonce"`
+		Error        string `json:"error"`
+	}
+	status, err := post(*base+"/enter-voting?electionId="+election, *token, "", nil, &entered)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if status != http.StatusOK {
+		log.Fatalf("enter-voting: %d %s", status, entered.Error)
+	}
+
+	form := url.Values{"electionId": {election}, "selections": {*selections}, "sessionNonce": {entered.SessionNonce}}
+	key := newKey()
+	results := make([]result, *n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := key
+			if !*sameKey {
+				k = newKey()
+			}
+			var body struct {
+				Receipt string `json:"receipt"`
+				Error   string `json:"error"`
+			}
+			<-start
+			status, err := post(*base+"/vote", *token, k, form, &body)
+			if err != nil {
+				results[i] = result{err: err.Error()}
+				return
+			}
+			results[i] = result{status: status, receipt: body.Receipt, err: body.Error}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	receipts := map[string]int{}
+	statuses := map[int]int{}
+	for _, r := range results {
+		statuses[r.status]++
+		if r.status == http.StatusOK {
+			receipts[r.receipt]++
+		} else if r.err != "" {
+			log.Printf("%d: %s", r.status, r.err)
+		}
+	}
+	for status, count := range statuses {
+		fmt.Printf("HTTP %d: %d\n", status, count)
+	}
+	fmt.Printf("Distinct receipts: %d\n", len(receipts))
+
+	switch {
+	case len(receipts) > 1:
+		log.Fatal("FAIL: more than one ballot was accepted")
+	case len(receipts) == 0:
+		log.Fatal("FAIL: no ballot was accepted")
+	case *sameKey && statuses[http.StatusOK] != *n:
+		log.Fatal("FAIL: retries with the same key did not all get the original response")
+	}
+	fmt.Println("OK")
+}
+
+func post(target, token, key string, form url.Values, out interface{}) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("%s: %d, unreadable response: %v", target, resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
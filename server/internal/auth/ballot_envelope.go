@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var (
+	envelopeKeyOnce sync.Once
+	envelopeAEAD    cipher.AEAD
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid ballot envelope")
+	ErrNoEnvelopeKey   = errors.New("BALLOT_ENVELOPE_KEY is not set")
+)
+
+// envelopeCipher seals the receipt that ties a voter's participation to
+// their ballot until the participation is reviewed. The key must stay stable
+// until every pending review is done, otherwise those ballots can no longer
+// be released. There is no built-in key: without one, votes are refused.
+func envelopeCipher() (cipher.AEAD, error) {
+	envelopeKeyOnce.Do(func() {
+		k := os.Getenv("BALLOT_ENVELOPE_KEY")
+		if k == "" {
+			k = os.Getenv("SESSION_SECRET")
+		}
+		if k == "" {
+			log.Println("WARNING: BALLOT_ENVELOPE_KEY not set, votes cannot be cast")
+			return
+		}
+		key := sha256.Sum256([]byte("ballot-envelope:" + k))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			panic(err)
+		}
+		envelopeAEAD, err = cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+	})
+	if envelopeAEAD == nil {
+		return nil, ErrNoEnvelopeKey
+	}
+	return envelopeAEAD, nil
+}
+
+// Envelope versions. Version 3 holds a receipt and the choices already
+// encrypted to the election key, so the envelope key alone never reveals a
+// choice. Version 2 holds a receipt and the plaintext choices, as sealed by
+// earlier builds and by MigrateVotes. Older envelopes start with a big-endian
+// candidate ID, whose first byte is always zero.
+const (
+	envelopeVersionPlain     = 2
+	envelopeVersionEncrypted = 3
+)
+
+// BallotEnvelope is the content of a sealed envelope. Exactly one of
+// Ciphertext and Plaintext is set.
+type BallotEnvelope struct {
+	Receipt    string
+	Ciphertext string // Choices encrypted to the election key at cast time
+	Plaintext  []byte // Choices from an envelope sealed before that; encrypt them on release
+}
+
+// SealBallot seals the voter's receipt with their choices, which the caller
+// has already encrypted to the election key, for storage until review.
+func SealBallot(ciphertext, receipt string) (string, error) {
+	return seal(envelopeVersionEncrypted, []byte(ciphertext), receipt)
+}
+
+// SealLegacyBallot seals plaintext choices. It is only for votes carried over
+// from the time before ballots were encrypted, when no election key exists
+// yet to encrypt them to.
+func SealLegacyBallot(payload []byte, receipt string) (string, error) {
+	return seal(envelopeVersionPlain, payload, receipt)
+}
+
+func seal(version byte, payload []byte, receipt string) (string, error) {
+	if len(receipt) > 255 {
+		return "", errors.New("receipt too long")
+	}
+	aead, err := envelopeCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	plain := []byte{version, byte(len(receipt))}
+	plain = append(plain, receipt...)
+	plain = append(plain, payload...)
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// OpenBallot decrypts an envelope produced by SealBallot or SealLegacyBallot.
+// Envelopes sealed by earlier builds hold a single candidate ID, returned as
+// its decimal string, and an empty receipt if they predate receipts.
+func OpenBallot(envelope string) (*BallotEnvelope, error) {
+	aead, err := envelopeCipher()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(envelope)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil || len(plain) < 2 {
+		return nil, ErrInvalidEnvelope
+	}
+
+	if plain[0] == envelopeVersionEncrypted || plain[0] == envelopeVersionPlain {
+		n := int(plain[1])
+		if len(plain) < 2+n {
+			return nil, ErrInvalidEnvelope
+		}
+		env := &BallotEnvelope{Receipt: string(plain[2 : 2+n])}
+		if plain[0] == envelopeVersionEncrypted {
+			env.Ciphertext = string(plain[2+n:])
+		} else {
+			env.Plaintext = plain[2+n:]
+		}
+		return env, nil
+	}
+	if len(plain) < 8 {
+		return nil, ErrInvalidEnvelope
+	}
+	candidateID := binary.BigEndian.Uint64(plain[:8])
+	return &BallotEnvelope{Receipt: string(plain[8:]), Plaintext: strconv.AppendUint(nil, candidateID, 10)}, nil
+}
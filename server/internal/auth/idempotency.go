@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var ErrInvalidReply = errors.New("invalid sealed reply")
+
+// replyCipher derives a cipher from a client's idempotency key. The server
+// keeps only the sealed reply and a hash of the key, so a stored reply can be
+// read back by the client retrying with the key and by nobody else.
+func replyCipher(key string) (cipher.AEAD, error) {
+	k := sha256.Sum256([]byte("idempotent-reply:" + key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealReply encrypts a response under the idempotency key of the request
+// that produced it.
+func SealReply(key string, reply []byte) (string, error) {
+	aead, err := replyCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, reply, nil)), nil
+}
+
+// OpenReply decrypts a response sealed by SealReply with the same key.
+func OpenReply(key, sealed string) ([]byte, error) {
+	aead, err := replyCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrInvalidReply
+	}
+	reply, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidReply
+	}
+	return reply, nil
+}
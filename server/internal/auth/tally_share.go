@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// tallyShareAD keeps sealed key shares and ballot envelopes apart, though
+// both use the envelope key.
+var tallyShareAD = []byte("tally-share")
+
+// SealShare encrypts a committee member's key share while it waits in the
+// database for the rest of the tally ceremony, so a copy of the database
+// alone does not hold the shares.
+func SealShare(share string) (string, error) {
+	aead, err := envelopeCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(share), tallyShareAD)), nil
+}
+
+// OpenShare decrypts a share sealed by SealShare.
+func OpenShare(sealed string) (string, error) {
+	aead, err := envelopeCipher()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", ErrInvalidEnvelope
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], tallyShareAD)
+	if err != nil {
+		return "", ErrInvalidEnvelope
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Token purposes. Session tokens have no purpose; every other kind is only
+// accepted by the endpoint it was issued for.
+const (
+	PurposeSession = ""
+	PurposeMFA     = "mfa"           // password verified, second factor still pending
+	PurposeConfirm = "email_confirm" // registration email confirmation link
+)
+
+// Claims is the payload carried inside a signed token.
+type Claims struct {
+	UserID    uint   `json:"sub"`
+	Role      string `json:"role"`
+	Purpose   string `json:"pur,omitempty"`
+	Email     string `json:"email,omitempty"` // binds confirmation links to the address they were sent to
+	SessionID string `json:"sid,omitempty"`   // server-side session row backing a session token
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Header is fixed: tokens are always HS256-signed JWTs.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	secretOnce sync.Once
+	secret     []byte
+)
+
+// Secret returns the HMAC key used to sign tokens. It comes from SESSION_SECRET;
+// without it a random per-process key is used, so tokens do not survive restarts.
+func Secret() []byte {
+	secretOnce.Do(func() {
+		if s := os.Getenv("SESSION_SECRET"); s != "" {
+			secret = []byte(s)
+			return
+		}
+		log.Println("SESSION_SECRET not set, using a random key (sessions reset on restart)")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal("Failed to generate session secret: ", err)
+		}
+	})
+	return secret
+}
+
+// SessionTTL is how long an issued session token stays valid (SESSION_TTL, default 8h).
+func SessionTTL() time.Duration {
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid SESSION_TTL %q, using default", v)
+	}
+	return 8 * time.Hour
+}
+
+// SessionMaxAge caps how long a session can be kept alive by refreshing,
+// counted from when it was issued (SESSION_MAX_AGE, default 24h).
+func SessionMaxAge() time.Duration {
+	if v := os.Getenv("SESSION_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid SESSION_MAX_AGE %q, using default", v)
+	}
+	return 24 * time.Hour
+}
+
+// Issue signs a session token for the given server-side session.
+func Issue(sessionID string, userID uint, role string, expiresAt time.Time) (string, error) {
+	return Sign(Claims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+// IssuePurpose signs a short-lived token restricted to a single purpose.
+func IssuePurpose(purpose string, userID uint, role string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return Sign(Claims{
+		UserID:    userID,
+		Role:      role,
+		Purpose:   purpose,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+}
+
+// Sign encodes and signs arbitrary claims.
+func Sign(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned), nil
+}
+
+// Parse verifies the signature and expiry of a token and returns its claims.
+func Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+
+	expected := sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return &claims, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, Secret())
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
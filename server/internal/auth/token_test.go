@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignParseRoundTrip(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	token, err := Issue("session-1", 42, "voter", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if claims.UserID != 42 || claims.Role != "voter" || claims.SessionID != "session-1" || claims.ExpiresAt != exp.Unix() {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Purpose != PurposeSession {
+		t.Errorf("purpose = %q, want a session token", claims.Purpose)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	valid, err := Sign(Claims{UserID: 7, Role: "voter", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":1,"role":"super_admin","exp":9999999999}`))
+	noUser, _ := Sign(Claims{Role: "voter", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"two parts", parts[0] + "." + parts[1]},
+		{"other header", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "." + parts[2]},
+		{"forged payload", parts[0] + "." + forged + "." + parts[2]},
+		{"bad signature", parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))},
+		{"no user", noUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Parse = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestParseExpired(t *testing.T) {
+	token, err := IssuePurpose(PurposeMFA, 3, "admin", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := Parse(token)
+	if !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("Parse = %v, want ErrExpiredToken", err)
+	}
+	// The claims still come back, e.g. to tell whose token expired.
+	if claims == nil || claims.UserID != 3 || claims.Purpose != PurposeMFA {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestSessionMaxAge(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"nonsense", 24 * time.Hour},
+		{"-1h", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Setenv("SESSION_MAX_AGE", tt.env)
+		if got := SessionMaxAge(); got != tt.want {
+			t.Errorf("SESSION_MAX_AGE=%q: got %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; ours are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	key, err := b32.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("T=%d: code %s, want %s", tt.unix, got, tt.code)
+		}
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("T=%d: ValidateTOTP = %d, %v", tt.unix, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, _ := b32.DecodeString(rfc6238Secret)
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"previous period", -1, true},
+		{"current period", 0, true},
+		{"next period", 1, true},
+		{"two periods ago", -2, false},
+		{"two periods ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(rfc6238Secret, totpCode(key, step+tt.offset), now)
+			if ok != tt.ok || ok && got != step+tt.offset {
+				t.Errorf("ValidateTOTP = %d, %v; want step %d, %v", got, ok, step+tt.offset, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, tc := range []struct{ secret, code string }{
+		{rfc6238Secret, "28708"},
+		{rfc6238Secret, "2870820"},
+		{"not base32!", "287082"},
+		{rfc6238Secret, ""},
+	} {
+		if _, ok := ValidateTOTP(tc.secret, tc.code, now); ok {
+			t.Errorf("ValidateTOTP(%q, %q) accepted", tc.secret, tc.code)
+		}
+	}
+	// Secrets are accepted in lower case, as some apps display them.
+	if _, ok := ValidateTOTP("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", now); !ok {
+		t.Error("lower-case secret refused")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := b32.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := NewRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[A-Z2-7]{4}(-[A-Z2-7]{4}){3}$`).MatchString(code) {
+		t.Errorf("code %q is not XXXX-XXXX-XXXX-XXXX", code)
+	}
+	if got, want := NormalizeRecoveryCode(" abcd-efgh ijkl-mnop"), "ABCDEFGHIJKLMNOP"; got != want {
+		t.Errorf("NormalizeRecoveryCode = %q, want %q", got, want)
+	}
+}
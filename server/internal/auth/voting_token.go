@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	votingKeyOnce sync.Once
+	votingKey     []byte
+)
+
+// votingTokenKey is the HMAC key for voting token hashes. It must stay stable
+// across restarts, otherwise no stored token will ever match again.
+func votingTokenKey() []byte {
+	votingKeyOnce.Do(func() {
+		if k := os.Getenv("VOTING_TOKEN_KEY"); k != "" {
+			votingKey = []byte(k)
+			return
+		}
+		if k := os.Getenv("SESSION_SECRET"); k != "" {
+			votingKey = []byte(k)
+			return
+		}
+		log.Println("WARNING: VOTING_TOKEN_KEY not set, using an insecure development key")
+		votingKey = []byte("jobhms-development-voting-token-key")
+	})
+	return votingKey
+}
+
+// NewVotingToken returns a fresh voting token to be emailed to a voter.
+func NewVotingToken() string {
+	return uuid.New().String()
+}
+
+// HashVotingToken returns the keyed hash stored in place of the voting token.
+func HashVotingToken(token string) string {
+	mac := hmac.New(sha256.New, votingTokenKey())
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VotingTokenMatches compares a presented token with a stored hash in constant time.
+func VotingTokenMatches(token, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+	return hmac.Equal([]byte(HashVotingToken(token)), []byte(hash))
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// schemaMigration records data migrations that have already been applied.
+type schemaMigration struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex"`
+	AppliedAt time.Time
+}
+
+// RunMigration applies a named data migration exactly once. The migration and
+// its bookkeeping row are committed in the same transaction.
+func RunMigration(name string, fn func(tx *gorm.DB) error) error {
+	if err := DB.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	var count int64
+	DB.Model(&schemaMigration{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	log.Printf("Applying migration %s", name)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Name: name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		log.Printf("Migration %s failed: %v", name, err)
+		return err
+	}
+	log.Printf("Migration %s applied", name)
+	return nil
+}
@@ -91,25 +91,29 @@ func SendWelcomeEmail(toEmail, name string) error {
 	return sendEmailAPI(toEmail, name, subject, htmlContent)
 }
 
-func SendReminderEmail(toEmail, name, token string) error {
-	subject := "Election Reminder: Your Token Inside"
+func SendReminderEmail(toEmail, name, electionName string) error {
+	subject := "Election Reminder: " + electionName
 	htmlContent := fmt.Sprintf(`
         <h3>Hello, %s</h3>
-        <p>The election is starting soon!</p>
-        <p><strong>Your Voting Token is: %s</strong></p>
-        <p>Do not share this token. Use it to log in when the election starts.</p>
+        <p><strong>%s</strong> is starting soon!</p>
+        <p>Log in with the Voting Token from your approval email.</p>
+        <p>Lost it? You can request a new token from the login page; the old one will stop working.</p>
         <p>See you at the polls!</p>
-    `, name, token)
+    `, name, electionName)
 	return sendEmailAPI(toEmail, name, subject, htmlContent)
 }
 
-func SendVoteConfirmation(toEmail, name, candidateName string) error {
+// SendVoteConfirmation tells the voter their ballot was recorded. It carries
+// the receipt only: mailboxes are neither secret nor ours, so the choices
+// themselves are never emailed.
+func SendVoteConfirmation(toEmail, name, receipt string) error {
 	subject := "Vote Confirmed"
 	htmlContent := fmt.Sprintf(`
         <h3>Thank you, %s</h3>
-        <p>Your vote for <strong>%s</strong> has been successfully recorded.</p>
+        <p>Your vote has been successfully recorded.</p>
+        <p>Your ballot receipt is <strong>%s</strong>. After the election closes you can find it on the public bulletin board to check that your ballot was included.</p>
         <p>Each vote counts!</p>
-    `, name, candidateName)
+    `, name, receipt)
 	return sendEmailAPI(toEmail, name, subject, htmlContent)
 }
 
@@ -123,3 +127,36 @@ func SendApprovalEmail(toEmail, name, token string) error {
     `, name, token)
 	return sendEmailAPI(toEmail, name, subject, htmlContent)
 }
+
+func SendPasswordResetEmail(toEmail, name, resetLink string) error {
+	subject := "Reset Your Password - JobHMS Voting"
+	htmlContent := fmt.Sprintf(`
+        <h3>Hello, %s</h3>
+        <p>We received a request to reset your password.</p>
+        <p><a href="%s">Click here to choose a new password</a>. This link can be used once and expires soon.</p>
+        <p>If you did not request this, you can ignore this email.</p>
+    `, name, resetLink)
+	return sendEmailAPI(toEmail, name, subject, htmlContent)
+}
+
+func SendTokenReissueEmail(toEmail, name, token string) error {
+	subject := "Your New Voting Token"
+	htmlContent := fmt.Sprintf(`
+        <h3>Hello, %s</h3>
+        <p>A new Voting Token was issued for your account. Your previous token no longer works.</p>
+        <p><strong>Your Voting Token is: %s</strong></p>
+        <p>If you did not request this, contact the election committee immediately.</p>
+    `, name, token)
+	return sendEmailAPI(toEmail, name, subject, htmlContent)
+}
+
+func SendConfirmationEmail(toEmail, name, confirmLink string) error {
+	subject := "Confirm Your Email - JobHMS Voting"
+	htmlContent := fmt.Sprintf(`
+        <h3>Hello, %s</h3>
+        <p>Thanks for registering for the upcoming election.</p>
+        <p><a href="%s">Click here to confirm your email address</a>.</p>
+        <p>Your registration is sent to the admins for verification only after you confirm. Unconfirmed registrations expire.</p>
+    `, name, confirmLink)
+	return sendEmailAPI(toEmail, name, subject, htmlContent)
+}
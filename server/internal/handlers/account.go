@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ChangePasswordPath and LogoutPath are the only routes a user flagged with
+// MustChangePassword may call.
+const (
+	ChangePasswordPath = "/account/password"
+	LogoutPath         = "/logout"
+)
+
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user := currentUser(c)
+	if user.Password == nil || bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be at least 8 characters"})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current one"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		return
+	}
+	if err := setPassword(db.DB, user, string(hashed)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// Other sessions were revoked; hand the caller a fresh token.
+	token, expiresAt, ok := newSessionToken(c, user)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Password updated",
+		"sessionToken": token,
+		"expiresAt":    expiresAt,
+	})
+}
+
+// setPassword stores a new password hash, clears the forced-change flag and
+// revokes every existing session of the user.
+func setPassword(tx *gorm.DB, user *models.User, hashed string) error {
+	user.Password = &hashed
+	user.MustChangePassword = false
+	err := tx.Model(user).Updates(map[string]interface{}{
+		"password":             hashed,
+		"must_change_password": false,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return revokeUserSessions(tx, user.ID, revokePasswordChanged)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type adminAccountRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	NIMScope string `json:"nimScope"`
+}
+
+func validNIMScope(scope string) bool {
+	for _, p := range nimPrefixes(scope) {
+		for _, ch := range p {
+			if ch < '0' || ch > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func GetAdminAccounts(c *gin.Context) {
+	admins := []models.User{}
+	if err := db.DB.Where("role IN ?", models.StaffRoles).Order("id").Find(&admins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admin accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, admins)
+}
+
+func CreateAdminAccount(c *gin.Context) {
+	var req adminAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Name == "" || req.Email == "" || len(req.Password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name, email and a password of at least 8 characters are required"})
+		return
+	}
+	if !models.IsStaffRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if !validNIMScope(req.NIMScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "NIM scope must be comma-separated digit prefixes"})
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.User{}).Where("email = ?", req.Email).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		return
+	}
+	password := string(hashed)
+
+	account := models.User{
+		Name:               req.Name,
+		Email:              req.Email,
+		Password:           &password,
+		Role:               req.Role,
+		NIMScope:           req.NIMScope,
+		VerificationStatus: "approved",
+		NIM:                "STAFF:" + req.Email, // NIM is unique; staff have none
+	}
+	if err := db.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin account"})
+		return
+	}
+	c.JSON(http.StatusCreated, account)
+}
+
+// findStaffAccount loads the staff account named by the :id param and refuses
+// to let a caller modify their own account (which could lock everyone out).
+func findStaffAccount(c *gin.Context) (*models.User, bool) {
+	var account models.User
+	if err := db.DB.Where("role IN ?", models.StaffRoles).First(&account, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin account not found"})
+		return nil, false
+	}
+	if account.ID == currentUser(c).ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot modify your own account"})
+		return nil, false
+	}
+	return &account, true
+}
+
+func UpdateAdminRole(c *gin.Context) {
+	var req struct {
+		Role     string `json:"role"`
+		NIMScope string `json:"nimScope"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !models.IsStaffRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if !validNIMScope(req.NIMScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "NIM scope must be comma-separated digit prefixes"})
+		return
+	}
+
+	account, ok := findStaffAccount(c)
+	if !ok {
+		return
+	}
+	account.Role = req.Role
+	account.NIMScope = req.NIMScope
+	if err := db.DB.Save(account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update admin account"})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+func SetAdminDisabled(c *gin.Context) {
+	var req struct {
+		Disabled bool `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	account, ok := findStaffAccount(c)
+	if !ok {
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(account).Update("disabled", req.Disabled).Error; err != nil {
+			return err
+		}
+		if req.Disabled {
+			return revokeUserSessions(tx, account.ID, revokeDisabled)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update admin account"})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
@@ -3,11 +3,13 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"voting-backend/internal/auth"
 	"voting-backend/internal/db"
 	"voting-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func AdminLogin(c *gin.Context) {
@@ -20,95 +22,161 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
+	if !checkLockout(c, req.Email) {
+		return
+	}
+
 	var user models.User
 	if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		recordLoginFailure(c, req.Email, "admin_login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin credentials"})
 		return
 	}
 
 	// Check if password is set (not nil)
 	if user.Password == nil {
+		recordLoginFailure(c, req.Email, "admin_login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)); err != nil {
+		recordLoginFailure(c, req.Email, "admin_login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin credentials"})
 		return
 	}
 
-	if user.Role != "admin" {
+	if !models.IsStaffRole(user.Role) || user.Disabled || user.VerificationStatus != "approved" {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
+	// Enrolled admins get a short-lived challenge token instead of a session.
+	// Their failure counter is only cleared once the code checks out, so the
+	// password alone cannot reset the count of wrong codes.
+	if user.TOTPEnabled {
+		recordAttempt(c, req.Email, "admin_login", true)
+		mfaToken, err := auth.IssuePurpose(auth.PurposeMFA, user.ID, user.Role, mfaChallengeTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfaRequired": true, "mfaToken": mfaToken})
+		return
+	}
+	recordLoginSuccess(c, req.Email, "admin_login")
+
+	completeAdminLogin(c, &user)
+}
+
+// completeAdminLogin issues the admin session once every login factor has passed.
+func completeAdminLogin(c *gin.Context, user *models.User) {
+	token, expiresAt, ok := newSessionToken(c, user)
+	if !ok {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"Role": "admin",
-		"Name": user.Name,
-		"ID":   user.ID,
+		"Role":         user.Role,
+		"Name":         user.Name,
+		"ID":           user.ID,
+		"sessionToken": token,
+		"expiresAt":    expiresAt,
+		// The client must send the admin to the password change screen first,
+		// then to two-factor enrollment.
+		"mustChangePassword":    user.MustChangePassword,
+		"mfaEnrollmentRequired": !user.TOTPEnabled && adminMFARequired(),
 	})
 }
 
-func SeedAdmin() {
-	targetEmail := "admin@hms.com"
-	var user models.User
+// BootstrapAdmin creates the first super-admin account when none exists yet.
+// It never modifies existing accounts, and the new admin must change the
+// bootstrap password on first login.
+func BootstrapAdmin(adminEmail, password string) {
+	var count int64
+	db.DB.Model(&models.User{}).Where("role = ?", models.RoleSuperAdmin).Count(&count)
+	if count > 0 {
+		if adminEmail != "" {
+			log.Println("Admin bootstrap skipped: an admin account already exists")
+		}
+		return
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-	if err != nil {
-		log.Fatal("Failed to hash admin password")
+	if adminEmail == "" || password == "" {
+		log.Println("WARNING: no admin account exists. Set ADMIN_BOOTSTRAP_EMAIL and ADMIN_BOOTSTRAP_PASSWORD (or -bootstrap-admin-email/-bootstrap-admin-password) to create one")
+		return
 	}
-	password := string(hashedPassword)
-	username := "admin"
-
-	// 1. Check if the Target Email already exists
-	if err := db.DB.Where("email = ?", targetEmail).First(&user).Error; err == nil {
-		// Found user with this email. Promote/Update to Admin + Password.
-		updates := map[string]interface{}{
-			"Password":           &password,
-			"Role":               "admin",
-			"VerificationStatus": "approved",
-			// We do NOT forcefully change username to "admin" here to avoid colliding with ID 4 if ID 4 is different.
-		}
-		if err := db.DB.Model(&user).Updates(updates).Error; err != nil {
-			log.Printf("Failed to update existing admin email user: %v", err)
-		} else {
-			log.Println("Existing user promoted/restored to admin: admin@hms.com")
-		}
+	if len(password) < 8 {
+		log.Println("Admin bootstrap skipped: password must be at least 8 characters")
 		return
 	}
 
-	// 2. Email is free. Check if 'admin' username exists (Legacy/Previous Seed)
-	var legacyUser models.User
-	if err := db.DB.Where("username = ?", "admin").First(&legacyUser).Error; err == nil {
-		// Found legacy admin (e.g. ID 4), and we know targetEmail is free (from Step 1).
-		// Safe to update email.
-		updates := map[string]interface{}{
-			"Email":              targetEmail,
-			"Password":           &password,
-			"Role":               "admin",
-			"VerificationStatus": "approved",
-		}
-		if err := db.DB.Model(&legacyUser).Updates(updates).Error; err != nil {
-			log.Printf("Failed to migrate legacy admin user: %v", err)
-		} else {
-			log.Println("Legacy admin user migrated to: admin@hms.com")
-		}
+	var existing models.User
+	if err := db.DB.Where("email = ?", adminEmail).First(&existing).Error; err == nil {
+		log.Printf("Admin bootstrap skipped: %s is already registered as a %s account", adminEmail, existing.Role)
 		return
 	}
 
-	// 3. Neither exists. Create fresh.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatal("Failed to hash admin password")
+	}
+	hashed := string(hashedPassword)
+
 	newAdmin := models.User{
 		Name:               "Administrator",
-		Username:           &username,
-		Password:           &password,
-		Role:               "admin",
+		Password:           &hashed,
+		Role:               models.RoleSuperAdmin,
 		VerificationStatus: "approved",
-		NIM:                "ADMIN",
-		Email:              targetEmail,
+		NIM:                "STAFF:" + adminEmail,
+		Email:              adminEmail,
+		MustChangePassword: true,
 	}
 	if err := db.DB.Create(&newAdmin).Error; err != nil {
-		log.Printf("Failed to create new admin: %v", err)
-	} else {
-		log.Println("New admin account created: admin@hms.com")
+		log.Printf("Failed to create bootstrap admin: %v", err)
+		return
 	}
+	log.Printf("Bootstrap admin account created: %s (password change required on first login)", adminEmail)
+}
+
+// MigrateLegacyAdmins is the explicit replacement for the old SeedAdmin
+// rewrites. Earlier builds seeded a user with username "admin" and reset
+// admin@hms.com to a well-known password on every boot.
+func MigrateLegacyAdmins() error {
+	err := db.RunMigration("legacy_admin_username", func(tx *gorm.DB) error {
+		var legacy models.User
+		if err := tx.Where("username = ?", "admin").First(&legacy).Error; err != nil {
+			log.Println("legacy_admin_username: no legacy admin user found")
+			return nil
+		}
+		if legacy.Role == models.RoleSuperAdmin && legacy.VerificationStatus == "approved" {
+			log.Printf("legacy_admin_username: user %d (%s) already an approved admin", legacy.ID, legacy.Email)
+			return nil
+		}
+		log.Printf("legacy_admin_username: promoting user %d (%s) from %s to admin", legacy.ID, legacy.Email, legacy.Role)
+		return tx.Model(&legacy).Updates(map[string]interface{}{
+			"role":                models.RoleSuperAdmin,
+			"verification_status": "approved",
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.RunMigration("legacy_default_admin_password", func(tx *gorm.DB) error {
+		var admins []models.User
+		if err := tx.Where("role IN ? AND password IS NOT NULL", models.StaffRoles).Find(&admins).Error; err != nil {
+			return err
+		}
+		for _, a := range admins {
+			if bcrypt.CompareHashAndPassword([]byte(*a.Password), []byte("admin123")) != nil {
+				continue
+			}
+			log.Printf("legacy_default_admin_password: user %d (%s) still uses the old seeded password, forcing a change", a.ID, a.Email)
+			if err := tx.Model(&a).Update("must_change_password", true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"voting-backend/internal/models"
+)
+
+const (
+	kotakKosongLast     = "last"
+	kotakKosongFirst    = "first"
+	kotakKosongShuffled = "shuffled"
+)
+
+// ballotOrder is the order a voter sees each contest's options in, keyed by
+// contest ID, with Kotak Kosong as 0. With ShuffleOptions the candidates of
+// each candidate contest are sorted by HMAC-SHA256 of their ID under the
+// voting session's seed, so every voter gets their own order and keeps it
+// when they reload the page. Ballot numbers stay as drawn, and referendum
+// answers keep their order. Kotak Kosong goes first, last or, when the
+// candidates are shuffled, in among them, as the election sets.
+func ballotOrder(e *models.Election, contests []models.Contest, seed string) map[uint][]uint {
+	order := map[uint][]uint{}
+	for _, contest := range contests {
+		var options []uint
+		for _, cand := range contest.Candidates {
+			if cand.WithdrawnAt == nil {
+				options = append(options, cand.ID)
+			}
+		}
+		shuffle := e.ShuffleOptions && contest.Kind == contestCandidates
+		shuffleKotakKosong := shuffle && contest.KotakKosong && e.KotakKosongPlace == kotakKosongShuffled
+		if shuffleKotakKosong {
+			options = append(options, 0)
+		}
+		if shuffle {
+			// Each contest is shuffled on its own.
+			key := seed + "/" + strconv.FormatUint(uint64(contest.ID), 10)
+			ranks := map[uint]string{}
+			for _, id := range options {
+				ranks[id] = drawOrder(key, id)
+			}
+			sort.Slice(options, func(i, j int) bool { return ranks[options[i]] < ranks[options[j]] })
+		}
+		if contest.KotakKosong && !shuffleKotakKosong {
+			if e.KotakKosongPlace == kotakKosongFirst {
+				options = append([]uint{0}, options...)
+			} else {
+				options = append(options, 0)
+			}
+		}
+		order[contest.ID] = options
+	}
+	return order
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"voting-backend/internal/models"
+)
+
+// orderContest is a contest with the given ID whose candidates have IDs 1 to n.
+func orderContest(id uint, kind string, kotakKosong bool, n uint) models.Contest {
+	var ids []uint
+	for i := uint(1); i <= n; i++ {
+		ids = append(ids, i)
+	}
+	contest := testContest(kind, methodPlurality, 1, kotakKosong, ids...)
+	contest.ID = id
+	return *contest
+}
+
+func TestBallotOrderIsStablePerSeed(t *testing.T) {
+	e := &models.Election{ShuffleOptions: true}
+	contests := []models.Contest{orderContest(1, contestCandidates, false, 6)}
+
+	first := ballotOrder(e, contests, "seed")
+	if again := ballotOrder(e, contests, "seed"); !reflect.DeepEqual(first, again) {
+		t.Fatalf("same seed gave %v, then %v", first, again)
+	}
+	got := append([]uint(nil), first[1]...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if want := []uint{1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order %v is not a permutation of %v", first[1], want)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		seen[fmt.Sprint(ballotOrder(e, contests, fmt.Sprint("seed-", i))[1])] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("20 seeds all gave the order %v", first[1])
+	}
+}
+
+func TestBallotOrder(t *testing.T) {
+	withdrawn := time.Now()
+	dropout := orderContest(1, contestCandidates, false, 3)
+	dropout.Candidates[1].WithdrawnAt = &withdrawn
+
+	tests := []struct {
+		name     string
+		election models.Election
+		contest  models.Contest
+		want     []uint
+	}{
+		{"not shuffled", models.Election{}, orderContest(1, contestCandidates, false, 4), []uint{1, 2, 3, 4}},
+		{"Kotak Kosong last by default", models.Election{}, orderContest(1, contestCandidates, true, 2), []uint{1, 2, 0}},
+		{"Kotak Kosong first", models.Election{KotakKosongPlace: kotakKosongFirst}, orderContest(1, contestCandidates, true, 2), []uint{0, 1, 2}},
+		{"referendum keeps its order", models.Election{ShuffleOptions: true}, orderContest(1, contestReferendum, false, 4), []uint{1, 2, 3, 4}},
+		{"withdrawn candidates are left out", models.Election{}, dropout, []uint{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ballotOrder(&tt.election, []models.Contest{tt.contest}, "seed")[tt.contest.ID]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ballotOrder = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBallotOrderKotakKosongPlace(t *testing.T) {
+	contests := []models.Contest{orderContest(1, contestCandidates, true, 4)}
+	tests := []struct {
+		place string
+		check func(order []uint) bool
+	}{
+		{kotakKosongLast, func(order []uint) bool { return order[len(order)-1] == 0 }},
+		{kotakKosongFirst, func(order []uint) bool { return order[0] == 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.place, func(t *testing.T) {
+			e := &models.Election{ShuffleOptions: true, KotakKosongPlace: tt.place}
+			for i := 0; i < 20; i++ {
+				if order := ballotOrder(e, contests, fmt.Sprint("seed-", i))[1]; len(order) != 5 || !tt.check(order) {
+					t.Fatalf("seed %d: order %v", i, order)
+				}
+			}
+		})
+	}
+
+	t.Run(kotakKosongShuffled, func(t *testing.T) {
+		e := &models.Election{ShuffleOptions: true, KotakKosongPlace: kotakKosongShuffled}
+		places := map[int]bool{}
+		for i := 0; i < 40; i++ {
+			order := ballotOrder(e, contests, fmt.Sprint("seed-", i))[1]
+			for pos, id := range order {
+				if id == 0 {
+					places[pos] = true
+				}
+			}
+		}
+		if len(places) < 2 {
+			t.Errorf("Kotak Kosong always took place %v", places)
+		}
+	})
+}
@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errAlreadyReviewed = errors.New("participation already reviewed")
+
+// newBallot returns an anonymous ballot. Spoiled ballots carry no choice.
+func newBallot(electionID uint, receipt string, spoiled bool) *models.Ballot {
+	b := &models.Ballot{ID: uuid.NewString(), ElectionID: electionID, Spoiled: spoiled}
+	if receipt != "" {
+		b.Receipt = &receipt
+	}
+	return b
+}
+
+// newEncryptedBallot returns a counted ballot with the choices encrypted to
+// the election key.
+func newEncryptedBallot(election *models.Election, payload []byte, receipt string) (*models.Ballot, error) {
+	ciphertext, err := encryptChoice(election, payload)
+	if err != nil {
+		return nil, err
+	}
+	b := newBallot(election.ID, receipt, false)
+	b.Ciphertext = ciphertext
+	return b, nil
+}
+
+// ballotReleaseBatch is how many reviewed envelopes are held back before
+// they are opened together and written out as ballots in random order.
+// Ballots have random IDs and no timestamps, but rows still land in the
+// table in the order they are written, so releasing each ballot as its voter
+// is approved would line the ballots up with the review log. While voting
+// runs, a ballot is always released among at least this many.
+const ballotReleaseBatch = 20
+
+// releaseBallots writes out the ballots of reviewed participations once a
+// full batch is waiting, or whatever is waiting when all is set, as after
+// voting ends. Each envelope is emptied as its ballot is written, so none is
+// released twice.
+func releaseBallots(tx *gorm.DB, election *models.Election, all bool) error {
+	var held []models.Participation
+	// Locked, so concurrent reviews cannot release the same envelopes.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("election_id = ? AND status <> ? AND envelope <> ''", election.ID, "pending").
+		Find(&held).Error; err != nil {
+		return err
+	}
+	if len(held) == 0 || (!all && len(held) < ballotReleaseBatch) {
+		return nil
+	}
+
+	ballots := make([]*models.Ballot, 0, len(held))
+	ids := make([]uint, 0, len(held))
+	for _, p := range held {
+		env, err := auth.OpenBallot(p.Envelope)
+		ids = append(ids, p.ID)
+		if p.Status == "rejected" {
+			// The receipt still goes on the bulletin board, marked spoiled.
+			receipt := ""
+			if err == nil {
+				receipt = env.Receipt
+			}
+			ballots = append(ballots, newBallot(election.ID, receipt, true))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if env.Ciphertext != "" {
+			ballot := newBallot(election.ID, env.Receipt, false)
+			ballot.Ciphertext = env.Ciphertext
+			ballots = append(ballots, ballot)
+			continue
+		}
+		// Sealed in the clear by an earlier build.
+		ballot, err := newEncryptedBallot(election, env.Plaintext, env.Receipt)
+		if err != nil {
+			return err
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	rand.Shuffle(len(ballots), func(i, j int) { ballots[i], ballots[j] = ballots[j], ballots[i] })
+	for _, b := range ballots {
+		if err := tx.Create(b).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.Participation{}).Where("id IN ?", ids).Update("envelope", "").Error; err != nil {
+		return err
+	}
+	log.Printf("Released %d ballots of election %d", len(ballots), election.ID)
+	return nil
+}
+
+// flushBallots releases every ballot still held back, once voting has ended.
+func flushBallots(election *models.Election) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return releaseBallots(tx, election, true)
+	})
+}
+
+// participationRow is what admins see when reviewing a vote: who voted and
+// their evidence, never the choice.
+type participationRow struct {
+	ID              uint      `json:"id"`
+	UserID          uint      `json:"userId"`
+	UserName        string    `json:"userName"`
+	UserNIM         string    `json:"userNim"`
+	UserEmail       string    `json:"userEmail"`
+	KTMImage        string    `json:"ktmImage"`
+	SelfImage       string    `json:"selfImage"`
+	CastAt          time.Time `json:"castAt"`
+	Status          string    `json:"status"`
+	RejectionReason string    `json:"rejectionReason"`
+}
+
+func participationQuery(c *gin.Context, electionID uint) *gorm.DB {
+	return db.DB.Table("participations").
+		Select("participations.id, participations.user_id, users.name as user_name, users.nim as user_nim, users.email as user_email, "+
+			"participations.ktm_image, participations.self_image, participations.cast_at, participations.status, participations.rejection_reason").
+		Joins("left join users on users.id = participations.user_id").
+		Where("participations.election_id = ?", electionID).
+		Scopes(nimScope(currentUser(c), "users.nim")).
+		Order("participations.id")
+}
+
+func GetPendingVotes(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	rows := []participationRow{}
+	participationQuery(c, election.ID).Where("participations.status = ?", "pending").Scan(&rows)
+	c.JSON(http.StatusOK, rows)
+}
+
+func GetRejectedVotes(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	rows := []participationRow{}
+	participationQuery(c, election.ID).Where("participations.status = ?", "rejected").Scan(&rows)
+	c.JSON(http.StatusOK, rows)
+}
+
+func SearchVotes(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+
+	rows := []participationRow{}
+	searchPattern := "%" + query + "%"
+	participationQuery(c, election.ID).Where("users.nim ILIKE ? OR users.name ILIKE ?", searchPattern, searchPattern).Scan(&rows)
+	c.JSON(http.StatusOK, rows)
+}
+
+// ApproveVote reviews a pending participation. Approving turns the sealed
+// choice into an anonymous ballot; rejecting spoils it. The decision is
+// final; the ballot itself is released with the next batch.
+func ApproveVote(c *gin.Context) {
+	var req struct {
+		VoteID uint   `json:"voteId"` // participation ID
+		Action string `json:"action"` // 'approve' or 'reject'
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Action != "approve" && req.Action != "reject") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var participation models.Participation
+	if err := db.DB.First(&participation, req.VoteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vote not found"})
+		return
+	}
+	var voter models.User
+	if err := db.DB.First(&voter, participation.UserID).Error; err == nil && !inNIMScope(currentUser(c), voter.NIM) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vote not found"})
+		return
+	}
+	if req.Action == "approve" && voter.VerificationStatus != "approved" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Voter is not verified"})
+		return
+	}
+	var election models.Election
+	if err := db.DB.First(&election, participation.ElectionID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process vote"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": "approved"}
+		if req.Action == "reject" {
+			// User CANNOT vote again.
+			updates = map[string]interface{}{"status": "rejected", "rejection_reason": "Rejected by Admin"}
+		}
+		res := tx.Model(&models.Participation{}).Where("id = ? AND status = ?", participation.ID, "pending").Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errAlreadyReviewed
+		}
+		return releaseBallots(tx, &election, electionEnded(&election))
+	})
+	if errors.Is(err, errAlreadyReviewed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vote already reviewed"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to review participation %d: %v", participation.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process vote"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vote processed"})
+}
+
+// GetResults returns the tally of every contest of the election and its
+// outcome under the election's rules. Ballots count once the tally ceremony
+// decrypted them; until then, and until voting ends, the outcome is
+// provisional.
+func GetResults(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	type Result struct {
+		CandidateID  uint   `json:"candidateId"`
+		BallotNumber *int   `json:"ballotNumber,omitempty"`
+		Name         string `json:"name"`
+		ImageURL     string `json:"imageUrl"`
+		Count        int64  `json:"count"`
+		Withdrawn    bool   `json:"withdrawn,omitempty"`
+	}
+	type ContestResult struct {
+		ContestID uint           `json:"contestId"`
+		Title     string         `json:"title"`
+		Kind      string         `json:"kind"`
+		Method    string         `json:"method"`
+		Results   []Result       `json:"results"` // First preferences for ranked contests
+		Rounds    []irvRound     `json:"rounds,omitempty"`
+		Outcome   contestOutcome `json:"outcome"`
+	}
+
+	contests, err := electionContests(election)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil hasil"})
+		return
+	}
+
+	if electionEnded(election) {
+		if err := flushBallots(election); err != nil {
+			log.Printf("Failed to release held ballots of election %d: %v", election.ID, err)
+		}
+	}
+
+	var ballotCount int64
+	db.DB.Model(&models.Ballot{}).Where("election_id = ?", election.ID).Count(&ballotCount)
+
+	// Count Spoiled Ballots (Suara Hangus); a spoiled ballot spoils every contest
+	var spoiledCount int64
+	db.DB.Model(&models.Ballot{}).Where("election_id = ? AND spoiled = ?", election.ID, true).Count(&spoiledCount)
+
+	var sealedCount int64
+	db.DB.Model(&models.Ballot{}).Where("election_id = ? AND spoiled = ? AND decrypted = ?", election.ID, false, false).Count(&sealedCount)
+
+	turnout := electionTurnout(election)
+
+	response := []ContestResult{}
+	for _, contest := range contests {
+		var counts map[uint]int64
+		var rounds []irvRound
+		var ballots [][]uint
+		if contest.Method == methodIRV {
+			ballots, err = rankedBallots(contest.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil hasil"})
+				return
+			}
+			rounds = instantRunoff(contestOptions(election, &contest), ballots)
+			if len(rounds) > 0 {
+				counts = rounds[0].Counts
+			}
+		} else {
+			counts, err = contestCounts(contest.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil hasil"})
+				return
+			}
+		}
+
+		results := []Result{}
+		for _, cand := range contest.Candidates {
+			results = append(results, Result{CandidateID: cand.ID, BallotNumber: cand.BallotNumber, Name: cand.Name, ImageURL: cand.ImageURL, Count: counts[cand.ID], Withdrawn: cand.WithdrawnAt != nil})
+		}
+
+		// Add Kotak Kosong to results
+		if contest.KotakKosong {
+			results = append(results, Result{
+				CandidateID: 0,
+				Name:        "Kotak Kosong",
+				ImageURL:    "/kotakkosong.png",
+				Count:       counts[0],
+			})
+		}
+
+		response = append(response, ContestResult{
+			ContestID: contest.ID,
+			Title:     contest.Title,
+			Kind:      contest.Kind,
+			Method:    contest.Method,
+			Results:   results,
+			Rounds:    rounds,
+			Outcome:   decideContest(election, &contest, counts, rounds, ballots, turnout.QuorumMet),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"election":    election.Name,
+		"ballots":     ballotCount,
+		"spoiled":     spoiledCount,
+		"turnout":     turnout,
+		"provisional": !electionEnded(election) || sealedCount > 0,
+		"contests":    response,
+	})
+}
+
+// contestCounts counts the counted ballots choosing each option of a contest.
+func contestCounts(contestID uint) (map[uint]int64, error) {
+	var rows []struct {
+		CandidateID uint
+		Count       int64
+	}
+	err := db.DB.Table("ballot_choices").
+		Select("ballot_choices.candidate_id, count(*) as count").
+		Joins("join ballots on ballots.id = ballot_choices.ballot_id AND ballots.decrypted = ? AND ballots.spoiled = ?", true, false).
+		Where("ballot_choices.contest_id = ?", contestID).
+		Group("ballot_choices.candidate_id").
+		Scan(&rows).Error
+	counts := map[uint]int64{}
+	for _, row := range rows {
+		counts[row.CandidateID] = row.Count
+	}
+	return counts, err
+}
+
+// MigrateVotes splits the old votes table, which stored voter and choice in
+// one row, into participations and anonymous ballots, then drops it. Pending
+// votes keep their choice sealed until reviewed. MigrateElections and
+// MigrateContests assign the rows to the first election and its contest
+// afterwards.
+func MigrateVotes() error {
+	return db.RunMigration("split_votes_into_ballots", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable("votes") {
+			return nil
+		}
+
+		var votes []struct {
+			UserID          uint
+			CandidateID     uint
+			Timestamp       time.Time
+			KTMImage        string
+			SelfImage       string
+			Status          string
+			RejectionReason string
+		}
+		if err := tx.Raw("SELECT user_id, candidate_id, timestamp, ktm_image, self_image, status, rejection_reason FROM votes ORDER BY id").
+			Scan(&votes).Error; err != nil {
+			return err
+		}
+
+		var ballots []*models.Ballot
+		choices := map[string]uint{}
+		for _, v := range votes {
+			p := models.Participation{
+				UserID:          v.UserID,
+				CastAt:          v.Timestamp,
+				KTMImage:        v.KTMImage,
+				SelfImage:       v.SelfImage,
+				Status:          v.Status,
+				RejectionReason: v.RejectionReason,
+			}
+			switch v.Status {
+			case "approved":
+				// Plaintext until EncryptLegacyBallots runs with the election key.
+				b := newBallot(0, "", false)
+				b.Decrypted = true
+				ballots = append(ballots, b)
+				choices[b.ID] = v.CandidateID
+			case "rejected":
+				ballots = append(ballots, newBallot(0, "", true))
+			default:
+				p.Status = "pending"
+				envelope, err := auth.SealLegacyBallot(strconv.AppendUint(nil, uint64(v.CandidateID), 10), "")
+				if err != nil {
+					return err
+				}
+				p.Envelope = envelope
+			}
+			if err := tx.Create(&p).Error; err != nil {
+				return err
+			}
+		}
+
+		// Insert ballots in random order so row order reveals nothing.
+		rand.Shuffle(len(ballots), func(i, j int) { ballots[i], ballots[j] = ballots[j], ballots[i] })
+		for _, b := range ballots {
+			if err := tx.Create(b).Error; err != nil {
+				return err
+			}
+			if candidateID, ok := choices[b.ID]; ok {
+				if err := tx.Create(&models.BallotChoice{BallotID: b.ID, CandidateID: candidateID, Rank: 1}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		log.Printf("split_votes_into_ballots: migrated %d votes into %d ballots", len(votes), len(ballots))
+		return tx.Migrator().DropTable("votes")
+	})
+}
+
+// GetBulletin is the public bulletin board: every receipt code with whether
+// its ballot was counted or spoiled, published once the election has closed.
+// Choices are never listed. Sorted by receipt so the order reveals nothing.
+func GetBulletin(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	if !electionEnded(election) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Papan buletin dibuka setelah pemilihan berakhir."})
+		return
+	}
+
+	if err := flushBallots(election); err != nil {
+		log.Printf("Failed to release held ballots of election %d: %v", election.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil papan buletin"})
+		return
+	}
+
+	type entry struct {
+		Receipt string `json:"receipt"`
+		Status  string `json:"status"` // 'counted' or 'spoiled'
+	}
+	entries := []entry{}
+	err := db.DB.Model(&models.Ballot{}).
+		Select("receipt, CASE WHEN spoiled THEN 'spoiled' ELSE 'counted' END as status").
+		Where("election_id = ? AND receipt IS NOT NULL", election.ID).
+		Order("receipt").
+		Scan(&entries).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil papan buletin"})
+		return
+	}
+
+	// Ballots still under review are not on the board yet.
+	c.JSON(http.StatusOK, gin.H{
+		"election":      election.Name,
+		"ballots":       entries,
+		"pendingReview": pendingParticipations(election.ID),
+	})
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/imgbb"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxCandidateMembers bounds the members a single candidate can list.
+const maxCandidateMembers = 10
+
+var (
+	errBallotNumberTaken  = errors.New("Another candidate in this election already has that ballot number")
+	errBallotNumbersDrawn = errors.New("Ballot numbers were drawn by lottery; only a redraw can change them")
+	errDrawCompleted      = errors.New("ballot draw already completed")
+)
+
+// candidateOrder lists candidates by ballot number, those without one last,
+// and members in their listed order.
+func candidateOrder(tx *gorm.DB) *gorm.DB {
+	return tx.Order("ballot_number NULLS LAST, id").
+		Preload("Members", func(tx *gorm.DB) *gorm.DB { return tx.Order("position, id") })
+}
+
+type memberRequest struct {
+	Name     string `json:"name"`
+	NIM      string `json:"nim"`
+	Role     string `json:"role"`
+	ImageURL string `json:"imageUrl"` // Keeps a photo the member already has
+}
+
+// candidateMembers reads the members form value, a JSON list in ballot order,
+// and uploads each member's photo from member_image_<index> if one was sent.
+// Otherwise a member may keep one of the existing members' photos.
+func candidateMembers(c *gin.Context, existing []models.CandidateMember) ([]models.CandidateMember, error) {
+	raw := c.PostForm("members")
+	if raw == "" {
+		return nil, nil
+	}
+	var reqs []memberRequest
+	if err := json.Unmarshal([]byte(raw), &reqs); err != nil {
+		return nil, errors.New("Members must be a JSON list")
+	}
+	if len(reqs) > maxCandidateMembers {
+		return nil, fmt.Errorf("A candidate can have at most %d members", maxCandidateMembers)
+	}
+
+	members := make([]models.CandidateMember, len(reqs))
+	for i, req := range reqs {
+		m := models.CandidateMember{
+			Position: i + 1,
+			Name:     strings.TrimSpace(req.Name),
+			NIM:      strings.TrimSpace(req.NIM),
+			Role:     strings.TrimSpace(req.Role),
+		}
+		if m.Name == "" {
+			return nil, fmt.Errorf("Member %d has no name", i+1)
+		}
+		if strings.ContainsFunc(m.NIM, func(r rune) bool { return r < '0' || r > '9' }) {
+			return nil, fmt.Errorf("The NIM of member %d must be digits", i+1)
+		}
+		if file, err := c.FormFile("member_image_" + strconv.Itoa(i)); err == nil {
+			link, err := imgbb.UploadImage(file)
+			if err != nil {
+				return nil, errors.New("Failed to upload to ImgBB: " + err.Error())
+			}
+			m.ImageURL = link
+		} else {
+			for _, e := range existing {
+				if req.ImageURL != "" && e.ImageURL == req.ImageURL {
+					m.ImageURL = e.ImageURL
+				}
+			}
+		}
+		members[i] = m
+	}
+	return members, nil
+}
+
+// candidateName is the name given, or else the members' names joined, e.g.
+// "Budi & Sari".
+func candidateName(name string, members []models.CandidateMember) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.Name
+	}
+	return strings.Join(names, " & ")
+}
+
+// ballotNumberFromRequest reads an optional ballotNumber form value.
+func ballotNumberFromRequest(c *gin.Context) (*int, error) {
+	raw := strings.TrimSpace(c.PostForm("ballotNumber"))
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return nil, errors.New("The ballot number must be a positive number")
+	}
+	return &n, nil
+}
+
+func sameBallotNumber(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// ballotNumbersDrawn reports whether the election's numbers came from a
+// completed lottery. They are then only changed by a redraw, not by hand.
+func ballotNumbersDrawn(electionID uint) bool {
+	var draw models.BallotDraw
+	return db.DB.Where("election_id = ? AND result <> ''", electionID).Take(&draw).Error == nil
+}
+
+// ballotNumberTaken reports whether another candidate of the election holds
+// the number. The unique index still decides between racing requests.
+func ballotNumberTaken(electionID uint, number int, exceptID uint) bool {
+	var other models.Candidate
+	return db.DB.Where("election_id = ? AND ballot_number = ? AND id <> ?", electionID, number, exceptID).Take(&other).Error == nil
+}
+
+// UpdateCandidate edits a candidate. Fields left out of the form keep their
+// value. Members, when sent, replace the list, and a new image replaces the
+// old one.
+func UpdateCandidate(c *gin.Context) {
+	var candidate models.Candidate
+	if err := db.DB.Preload("Members").First(&candidate, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		return
+	}
+
+	members := candidate.Members
+	_, replaceMembers := c.GetPostForm("members")
+	if replaceMembers {
+		var err error
+		if members, err = candidateMembers(c, candidate.Members); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if name, ok := c.GetPostForm("name"); ok {
+		if candidate.Name = candidateName(name, members); candidate.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+			return
+		}
+	}
+	if visi, ok := c.GetPostForm("visi"); ok {
+		candidate.Visi = visi
+	}
+	if misi, ok := c.GetPostForm("misi"); ok {
+		candidate.Misi = misi
+	}
+	if _, ok := c.GetPostForm("ballotNumber"); ok {
+		number, err := ballotNumberFromRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !sameBallotNumber(number, candidate.BallotNumber) && ballotNumbersDrawn(candidate.ElectionID) {
+			c.JSON(http.StatusConflict, gin.H{"error": errBallotNumbersDrawn.Error()})
+			return
+		}
+		if number != nil && ballotNumberTaken(candidate.ElectionID, *number, candidate.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": errBallotNumberTaken.Error()})
+			return
+		}
+		candidate.BallotNumber = number
+	}
+	if file, err := c.FormFile("image"); err == nil {
+		link, err := imgbb.UploadImage(file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload to ImgBB: " + err.Error()})
+			return
+		}
+		candidate.ImageURL = link
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if replaceMembers {
+			if err := tx.Where("candidate_id = ?", candidate.ID).Delete(&models.CandidateMember{}).Error; err != nil {
+				return err
+			}
+			for i := range members {
+				members[i].CandidateID = candidate.ID
+			}
+			if len(members) > 0 {
+				if err := tx.Create(&members).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Omit("Members").Save(&candidate).Error
+	})
+	if err != nil {
+		if candidate.BallotNumber != nil && ballotNumberTaken(candidate.ElectionID, *candidate.BallotNumber, candidate.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": errBallotNumberTaken.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update candidate"})
+		return
+	}
+	candidate.Members = members
+
+	log.Printf("Admin %d updated candidate %d of election %d", currentUser(c).ID, candidate.ID, candidate.ElectionID)
+	c.JSON(http.StatusOK, candidate)
+}
+
+// WithdrawCandidate records that a candidate withdrew. They stay in the
+// election's history and on ballots already cast, which the election's
+// WithdrawnVotes policy counts or voids, but take no new votes.
+func WithdrawCandidate(c *gin.Context) {
+	var candidate models.Candidate
+	if err := db.DB.First(&candidate, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// The reason is optional.
+	_ = c.ShouldBindJSON(&req)
+
+	res := db.DB.Model(&models.Candidate{}).Where("id = ? AND withdrawn_at IS NULL", candidate.ID).
+		Updates(map[string]interface{}{"withdrawn_at": time.Now(), "withdrawal_reason": strings.TrimSpace(req.Reason)})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw candidate"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The candidate has already withdrawn"})
+		return
+	}
+	log.Printf("Admin %d withdrew candidate %d (%s) from election %d", currentUser(c).ID, candidate.ID, candidate.Name, candidate.ElectionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Candidate withdrawn"})
+}
+
+// ReinstateCandidate takes back a withdrawal made before voting opened.
+func ReinstateCandidate(c *gin.Context) {
+	var candidate models.Candidate
+	if err := db.DB.First(&candidate, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		return
+	}
+	if candidate.WithdrawnAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The candidate has not withdrawn"})
+		return
+	}
+	err := db.DB.Model(&candidate).Updates(map[string]interface{}{"withdrawn_at": nil, "withdrawal_reason": ""}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reinstate candidate"})
+		return
+	}
+	log.Printf("Admin %d reinstated candidate %d of election %d", currentUser(c).ID, candidate.ID, candidate.ElectionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Candidate reinstated"})
+}
+
+// drawOrder is where the draw with the given seed puts a candidate: the
+// HMAC-SHA256 of its ID keyed by the seed.
+func drawOrder(seed string, candidateID uint) string {
+	mac := hmac.New(sha256.New, []byte(seed))
+	mac.Write([]byte(strconv.FormatUint(uint64(candidateID), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// drawnNumber is one line of a ballot draw's result.
+type drawnNumber struct {
+	ContestID    uint   `json:"contestId"`
+	CandidateID  uint   `json:"candidateId"`
+	Name         string `json:"name"`
+	Order        string `json:"order"`
+	BallotNumber int    `json:"ballotNumber"`
+}
+
+// drawKey combines the committed seed with the witnesses' entropy, so
+// neither the admin who chose the seed nor the witnesses alone decide the
+// outcome.
+func drawKey(seed, witness string) string {
+	return seed + ":" + witness
+}
+
+// pendingDraw returns the election's committed draw that still awaits its
+// witness entropy, if any.
+func pendingDraw(electionID uint) (*models.BallotDraw, bool) {
+	var draw models.BallotDraw
+	if db.DB.Where("election_id = ? AND result = ''", electionID).Take(&draw).Error != nil {
+		return nil, false
+	}
+	return &draw, true
+}
+
+// CommitBallotDraw opens a ballot number lottery by fixing a fresh random
+// seed and publishing its SHA-256 only. The seed cannot be changed once
+// committed, and is revealed when DrawBallotNumbers completes the draw. A
+// commitment cannot be abandoned: it must be drawn before another is made.
+// Replacing numbers already drawn needs {"redraw": true}, and a redraw is
+// completed by a different admin than the one who committed it.
+func CommitBallotDraw(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	var req struct {
+		Redraw bool `json:"redraw"`
+	}
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+
+	if _, ok := pendingDraw(election.ID); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "A ballot draw is already committed; complete it with the witness entropy first"})
+		return
+	}
+	redraw := ballotNumbersDrawn(election.ID)
+	if redraw && !req.Redraw {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ballot numbers were already drawn; confirm a redraw to replace them"})
+		return
+	}
+
+	seed, err := auth.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit the ballot draw"})
+		return
+	}
+	admin := currentUser(c)
+	draw := models.BallotDraw{ElectionID: election.ID, CommittedBy: admin.ID, Seed: seed, SeedHash: auth.HashToken(seed), Redraw: redraw}
+	if err := db.DB.Create(&draw).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit the ballot draw"})
+		return
+	}
+
+	log.Printf("Admin %d committed ballot draw %d for election %d (seed hash %s)", admin.ID, draw.ID, election.ID, draw.SeedHash)
+	c.JSON(http.StatusOK, ballotDrawResponse(draw))
+}
+
+// DrawBallotNumbers completes the committed draw with the witness entropy,
+// e.g. dice rolls called out by the candidates' representatives at the draw,
+// and assigns the ballot numbers. The candidates of each candidate contest,
+// contest by contest, are sorted by HMAC-SHA256 of their ID keyed by the seed
+// and the witness entropy, and numbered from 1 on. The seed is revealed with
+// the result, so anyone can check it against the published hash and repeat
+// the draw.
+func DrawBallotNumbers(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	var req struct {
+		Witness string `json:"witness"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Witness) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enter the witness entropy called out at the draw"})
+		return
+	}
+	witness := strings.TrimSpace(req.Witness)
+
+	draw, ok := pendingDraw(election.ID)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Commit the ballot draw and publish its seed hash first"})
+		return
+	}
+	admin := currentUser(c)
+	if draw.Redraw && draw.CommittedBy == admin.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A redraw must be completed by a second admin"})
+		return
+	}
+
+	contests, err := electionContests(election)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to draw ballot numbers"})
+		return
+	}
+
+	key := drawKey(draw.Seed, witness)
+	result := []drawnNumber{}
+	next := 1
+	for _, contest := range contests {
+		if contest.Kind != contestCandidates {
+			continue
+		}
+		var drawn []drawnNumber
+		for _, cand := range contest.Candidates {
+			// A candidate who withdrew keeps no number.
+			if cand.WithdrawnAt != nil {
+				continue
+			}
+			drawn = append(drawn, drawnNumber{ContestID: contest.ID, CandidateID: cand.ID, Name: cand.Name, Order: drawOrder(key, cand.ID)})
+		}
+		sort.Slice(drawn, func(i, j int) bool { return drawn[i].Order < drawn[j].Order })
+		for i := range drawn {
+			drawn[i].BallotNumber = next
+			next++
+		}
+		result = append(result, drawn...)
+	}
+	if len(result) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The election has no candidates to draw numbers for"})
+		return
+	}
+
+	encoded, _ := json.Marshal(result)
+	now := time.Now()
+	draw.DrawnBy = admin.ID
+	draw.DrawnAt = &now
+	draw.Witness = witness
+	draw.Result = string(encoded)
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BallotDraw{}).Where("id = ? AND result = ''", draw.ID).Updates(map[string]interface{}{
+			"drawn_by": draw.DrawnBy,
+			"drawn_at": draw.DrawnAt,
+			"witness":  draw.Witness,
+			"result":   draw.Result,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errDrawCompleted
+		}
+		// Clear first, so the unique index never sees two candidates swap numbers.
+		if err := tx.Model(&models.Candidate{}).Where("election_id = ?", election.ID).Update("ballot_number", nil).Error; err != nil {
+			return err
+		}
+		for _, d := range result {
+			if err := tx.Model(&models.Candidate{}).Where("id = ?", d.CandidateID).Update("ballot_number", d.BallotNumber).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errDrawCompleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This ballot draw was already completed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to draw ballot numbers"})
+		return
+	}
+
+	log.Printf("Admin %d drew ballot numbers for election %d (draw %d, seed %s, witness %q)", admin.ID, election.ID, draw.ID, draw.Seed, witness)
+	c.JSON(http.StatusOK, ballotDrawResponse(*draw))
+}
+
+// ballotDrawResponse publishes a draw. The seed of a committed draw stays
+// hidden until the draw is completed; only its hash is shown.
+func ballotDrawResponse(d models.BallotDraw) gin.H {
+	response := gin.H{
+		"id":          d.ID,
+		"status":      "drawn",
+		"committedBy": d.CommittedBy,
+		"seedHash":    d.SeedHash,
+		"redraw":      d.Redraw,
+		"createdAt":   d.CreatedAt,
+	}
+	if d.Result == "" {
+		response["status"] = "committed"
+		return response
+	}
+	response["drawnBy"] = d.DrawnBy
+	response["drawnAt"] = d.DrawnAt
+	response["seed"] = d.Seed
+	response["witness"] = d.Witness
+	response["result"] = json.RawMessage(d.Result)
+	return response
+}
+
+// GetBallotDraws publishes an election's ballot number draws, latest first:
+// the seed hash of a committed draw, and the seed, witness entropy and result
+// of a completed one.
+func GetBallotDraws(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	var draws []models.BallotDraw
+	db.DB.Where("election_id = ?", election.ID).Order("id DESC").Find(&draws)
+	response := []gin.H{}
+	for _, d := range draws {
+		response = append(response, ballotDrawResponse(d))
+	}
+	c.JSON(http.StatusOK, response)
+}
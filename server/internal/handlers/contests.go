@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	contestCandidates = "candidates"
+	contestReferendum = "referendum"
+
+	methodPlurality = "plurality"
+	methodIRV       = "irv"
+)
+
+// ballotSelections maps a contest ID to the chosen candidate IDs, in order
+// of preference. 0 is Kotak Kosong. It is what gets sealed and encrypted.
+type ballotSelections map[uint][]uint
+
+// electionContests returns the election's contests in ballot order, with
+// their candidates and the election's Kotak Kosong policy applied.
+func electionContests(election *models.Election) ([]models.Contest, error) {
+	var contests []models.Contest
+	err := db.DB.Where("election_id = ?", election.ID).
+		Preload("Candidates", candidateOrder).
+		Order("position, id").
+		Find(&contests).Error
+	for i := range contests {
+		contests[i].KotakKosong = offersKotakKosong(election, &contests[i])
+	}
+	return contests, err
+}
+
+// votesCast reports whether anyone voted in the election yet; the ballot's
+// structure is fixed from then on.
+func votesCast(electionID uint) bool {
+	var cast int64
+	db.DB.Model(&models.Participation{}).Where("election_id = ?", electionID).Count(&cast)
+	return cast > 0
+}
+
+// selectionsFromRequest reads the voter's choices: the selections form value
+// as JSON, or candidateId for elections with a single contest.
+func selectionsFromRequest(c *gin.Context, contests []models.Contest) (ballotSelections, error) {
+	if raw := c.PostForm("selections"); raw != "" {
+		var sel ballotSelections
+		if err := json.Unmarshal([]byte(raw), &sel); err != nil {
+			return nil, errors.New("Format pilihan tidak valid")
+		}
+		return sel, nil
+	}
+	if id := c.PostForm("candidateId"); id != "" && len(contests) == 1 {
+		candidateID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, errors.New("Kandidat tidak valid")
+		}
+		return ballotSelections{contests[0].ID: {uint(candidateID)}}, nil
+	}
+	return nil, errors.New("Pilihan wajib diisi")
+}
+
+// parseSelections decodes a decrypted ballot payload. Ballots from before
+// contests existed hold a bare candidate ID for the first contest.
+func parseSelections(contests []models.Contest, payload []byte) (ballotSelections, error) {
+	if len(payload) > 0 && payload[0] == '{' {
+		var sel ballotSelections
+		err := json.Unmarshal(payload, &sel)
+		return sel, err
+	}
+	candidateID, err := strconv.ParseUint(string(payload), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if len(contests) == 0 {
+		return nil, errors.New("election has no contests")
+	}
+	return ballotSelections{contests[0].ID: {uint(candidateID)}}, nil
+}
+
+// validateSelections checks that every contest is answered within its rules.
+func validateSelections(contests []models.Contest, sel ballotSelections) error {
+	known := map[uint]bool{}
+	for _, contest := range contests {
+		known[contest.ID] = true
+		choices := sel[contest.ID]
+		if len(choices) == 0 {
+			return fmt.Errorf("Pilihan untuk %s wajib diisi", contest.Title)
+		}
+		if len(choices) > contest.MaxChoices {
+			return fmt.Errorf("Maksimal %d pilihan untuk %s", contest.MaxChoices, contest.Title)
+		}
+
+		seen := map[uint]bool{}
+		for _, id := range choices {
+			if seen[id] {
+				return fmt.Errorf("Pilihan ganda untuk %s", contest.Title)
+			}
+			seen[id] = true
+
+			if id == 0 {
+				if !contest.KotakKosong {
+					return fmt.Errorf("Kotak Kosong tidak tersedia untuk %s", contest.Title)
+				}
+				// On a ranked ballot Kotak Kosong is ranked like any option.
+				if len(choices) > 1 && contest.Method != methodIRV {
+					return fmt.Errorf("Kotak Kosong tidak dapat digabung dengan pilihan lain untuk %s", contest.Title)
+				}
+				continue
+			}
+			if !contestHasCandidate(&contest, id) {
+				return fmt.Errorf("Kandidat tidak valid untuk %s", contest.Title)
+			}
+		}
+	}
+	for contestID := range sel {
+		if !known[contestID] {
+			return errors.New("Pilihan tidak valid")
+		}
+	}
+	return nil
+}
+
+// withdrawnChoice refuses new votes for candidates who withdrew. Ballots cast
+// before the withdrawal still pass validateSelections when they are counted.
+func withdrawnChoice(contests []models.Contest, sel ballotSelections) error {
+	for _, contest := range contests {
+		for _, id := range sel[contest.ID] {
+			for _, cand := range contest.Candidates {
+				if cand.ID == id && cand.WithdrawnAt != nil {
+					return fmt.Errorf("%s telah mengundurkan diri dari %s", cand.Name, contest.Title)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func contestHasCandidate(contest *models.Contest, candidateID uint) bool {
+	for _, cand := range contest.Candidates {
+		if cand.ID == candidateID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetContests returns the ballot of an election: its contests and their
+// options, in order.
+func GetContests(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	contests, err := electionContests(election)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil surat suara"})
+		return
+	}
+	c.JSON(http.StatusOK, contests)
+}
+
+type contestRequest struct {
+	Title       string `json:"title"`
+	Kind        string `json:"kind"`
+	Method      string `json:"method"`
+	Position    int    `json:"position"`
+	MaxChoices  int    `json:"maxChoices"`
+	KotakKosong bool   `json:"kotakKosong"`
+}
+
+func (req *contestRequest) apply(contest *models.Contest) error {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return errors.New("Title is required")
+	}
+	if req.Kind == "" {
+		req.Kind = contestCandidates
+	}
+	if req.Kind != contestCandidates && req.Kind != contestReferendum {
+		return errors.New("Kind must be 'candidates' or 'referendum'")
+	}
+	if req.Method == "" {
+		req.Method = methodPlurality
+	}
+	if req.Method != methodPlurality && req.Method != methodIRV {
+		return errors.New("Method must be 'plurality' or 'irv'")
+	}
+	if req.MaxChoices < 1 {
+		req.MaxChoices = 1
+	}
+	// A referendum is a single yes/no answer.
+	if req.Kind == contestReferendum {
+		req.Method = methodPlurality
+		req.MaxChoices = 1
+		req.KotakKosong = false
+	}
+
+	contest.Title = title
+	contest.Kind = req.Kind
+	contest.Method = req.Method
+	contest.Position = req.Position
+	contest.MaxChoices = req.MaxChoices
+	contest.KotakKosong = req.KotakKosong
+	return nil
+}
+
+func CreateContest(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	var req contestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	contest := models.Contest{ElectionID: election.ID}
+	if err := req.apply(&contest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if votesCast(election.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The ballot cannot change once votes were cast"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&contest).Error; err != nil {
+			return err
+		}
+		if contest.Kind != contestReferendum {
+			return nil
+		}
+		for _, name := range []string{"Setuju", "Tidak Setuju"} {
+			option := models.Candidate{ElectionID: election.ID, ContestID: contest.ID, Name: name}
+			if err := tx.Create(&option).Error; err != nil {
+				return err
+			}
+			contest.Candidates = append(contest.Candidates, option)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create contest"})
+		return
+	}
+
+	log.Printf("Admin %d added contest %d (%s) to election %d", currentUser(c).ID, contest.ID, contest.Title, election.ID)
+	c.JSON(http.StatusCreated, contest)
+}
+
+// UpdateContest edits a contest. Once votes were cast only its title and
+// position may change.
+func UpdateContest(c *gin.Context) {
+	var contest models.Contest
+	if err := db.DB.First(&contest, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
+		return
+	}
+	var req contestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	before := contest
+	if err := req.apply(&contest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if contest.Kind != before.Kind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The kind of a contest cannot change"})
+		return
+	}
+	changed := contest.Method != before.Method || contest.MaxChoices != before.MaxChoices || contest.KotakKosong != before.KotakKosong
+	if changed && votesCast(contest.ElectionID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The ballot cannot change once votes were cast"})
+		return
+	}
+
+	if err := db.DB.Save(&contest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update contest"})
+		return
+	}
+	c.JSON(http.StatusOK, contest)
+}
+
+// DeleteContest removes a contest and its options while no votes were cast.
+func DeleteContest(c *gin.Context) {
+	var contest models.Contest
+	if err := db.DB.First(&contest, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
+		return
+	}
+	if votesCast(contest.ElectionID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The ballot cannot change once votes were cast"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("candidate_id IN (SELECT id FROM candidates WHERE contest_id = ?)", contest.ID).Delete(&models.CandidateMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("contest_id = ?", contest.ID).Delete(&models.Candidate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&contest).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contest"})
+		return
+	}
+	log.Printf("Admin %d deleted contest %d of election %d", currentUser(c).ID, contest.ID, contest.ElectionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Contest deleted"})
+}
+
+// newDefaultContest is the single candidate contest every election starts with.
+func newDefaultContest(election *models.Election) *models.Contest {
+	return &models.Contest{
+		ElectionID:  election.ID,
+		Position:    1,
+		Title:       election.Name,
+		Kind:        contestCandidates,
+		Method:      methodPlurality,
+		MaxChoices:  1,
+		KotakKosong: true,
+	}
+}
+
+// MigrateContests gives every election that predates contests a single
+// contest holding its candidates, and moves choices of ballots that are
+// already in plaintext out of ballots.candidate_id into ballot choices.
+func MigrateContests() error {
+	return db.RunMigration("split_elections_into_contests", func(tx *gorm.DB) error {
+		var elections []models.Election
+		if err := tx.Find(&elections).Error; err != nil {
+			return err
+		}
+		for i := range elections {
+			contest := newDefaultContest(&elections[i])
+			if err := tx.Create(contest).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Candidate{}).
+				Where("election_id = ? AND (contest_id IS NULL OR contest_id = 0)", contest.ElectionID).
+				Update("contest_id", contest.ID).Error; err != nil {
+				return err
+			}
+
+			// Choices of votes migrated by MigrateVotes have no contest yet.
+			if err := tx.Exec(`UPDATE ballot_choices SET contest_id = ?
+				WHERE contest_id = 0 AND ballot_id IN (SELECT id FROM ballots WHERE election_id = ?)`, contest.ID, contest.ElectionID).Error; err != nil {
+				return err
+			}
+			if tx.Migrator().HasColumn(&models.Ballot{}, "candidate_id") {
+				err := tx.Exec(`INSERT INTO ballot_choices (ballot_id, contest_id, candidate_id, rank)
+					SELECT id, ?, candidate_id, 1 FROM ballots
+					WHERE election_id = ? AND decrypted AND NOT spoiled`, contest.ID, contest.ElectionID).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if tx.Migrator().HasColumn(&models.Ballot{}, "candidate_id") {
+			if err := tx.Migrator().DropColumn(&models.Ballot{}, "candidate_id"); err != nil {
+				return err
+			}
+		}
+		log.Printf("split_elections_into_contests: created contests for %d elections", len(elections))
+		return nil
+	})
+}
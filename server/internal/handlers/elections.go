@@ -0,0 +1,530 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"voting-backend/internal/db"
+	"voting-backend/internal/email"
+	"voting-backend/internal/models"
+	"voting-backend/internal/threshold"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// electionTimeLayout is how schedules are entered and shown, in WIB.
+const electionTimeLayout = "2006-01-02T15:04"
+
+// electionOpen reports whether voting in e is open at now.
+func electionOpen(e *models.Election, now time.Time) bool {
+	return electionPhase(e, now) == PhaseVoting
+}
+
+// electionEnded reports whether voting in e has closed, archived or not.
+func electionEnded(e *models.Election) bool {
+	return phaseAfter(runningPhase(e, time.Now()), PhaseVoting)
+}
+
+// currentElection is the election requests refer to when they name none: the
+// one open now, else the next to start, else the most recent one.
+func currentElection() (*models.Election, error) {
+	var elections []models.Election
+	if err := db.DB.Where("archived = ?", false).Order("start_time, id").Find(&elections).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var upcoming, latest *models.Election
+	for i := range elections {
+		e := &elections[i]
+		switch {
+		case electionOpen(e, now):
+			return e, nil
+		case e.StartTime != nil && now.Before(*e.StartTime):
+			if upcoming == nil {
+				upcoming = e
+			}
+		default:
+			latest = e
+		}
+	}
+	if upcoming != nil {
+		return upcoming, nil
+	}
+	if latest != nil {
+		return latest, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// resolveElection loads the election named by the electionId query or form
+// value, or the current election. An election RequirePhase already checked
+// wins. It writes the error response and returns nil if there is none.
+func resolveElection(c *gin.Context) *models.Election {
+	if e, ok := c.Get(contextElection); ok {
+		return e.(*models.Election)
+	}
+	id := c.Query("electionId")
+	if id == "" {
+		id = c.PostForm("electionId")
+	}
+
+	var election *models.Election
+	var err error
+	if id != "" {
+		election = &models.Election{}
+		err = db.DB.First(election, "id = ?", id).Error
+	} else {
+		election, err = currentElection()
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pemilihan tidak ditemukan"})
+		return nil
+	}
+	return election
+}
+
+// electionFromParam loads the election in the :id path parameter.
+func electionFromParam(c *gin.Context) *models.Election {
+	var election models.Election
+	if err := db.DB.First(&election, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Election not found"})
+		return nil
+	}
+	return &election
+}
+
+// electionEligible reports whether the user may vote in e. The NIM must
+// meet e's own rules: the account may have been approved under another
+// election's.
+func electionEligible(e *models.Election, user *models.User) bool {
+	return !e.Archived && user.Role == models.RoleVoter && user.VerificationStatus == "approved" &&
+		nimRuleError(e, user.NIM) == "" && onRoll(e, user.NIM)
+}
+
+// electionVoter returns the user's state in an election, creating it on first
+// use. Concurrent first uses create a single row.
+func electionVoter(tx *gorm.DB, electionID, userID uint) (*models.ElectionVoter, error) {
+	var voter models.ElectionVoter
+	err := tx.Where("election_id = ? AND user_id = ?", electionID, userID).First(&voter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		row := models.ElectionVoter{ElectionID: electionID, UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return nil, err
+		}
+		err = tx.Where("election_id = ? AND user_id = ?", electionID, userID).First(&voter).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &voter, nil
+}
+
+// votedEverywhere reports whether the voter has voted and has no election
+// left that they could still vote in.
+func votedEverywhere(user *models.User) bool {
+	var elections []models.Election
+	db.DB.Where("archived = ?", false).Find(&elections)
+
+	var voted []uint
+	db.DB.Model(&models.ElectionVoter{}).Where("user_id = ? AND has_voted = ?", user.ID, true).Pluck("election_id", &voted)
+	if len(voted) == 0 {
+		return false
+	}
+	for _, e := range elections {
+		if electionEligible(&e, user) && !electionEnded(&e) && !containsID(voted, e.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GetElections lists the elections voters can see, neither drafts nor
+// archived, for the countdown and election pickers.
+func GetElections(c *gin.Context) {
+	elections := []models.Election{}
+	db.DB.Where("archived = ?", false).Order("start_time, id").Find(&elections)
+
+	visible := []models.Election{}
+	for _, e := range withPhases(elections) {
+		if Phase(e.Phase) != PhaseDraft {
+			visible = append(visible, e)
+		}
+	}
+	c.JSON(http.StatusOK, visible)
+}
+
+// GetMyElections lists the elections the logged-in voter may vote in, with
+// whether they already did.
+func GetMyElections(c *gin.Context) {
+	user := currentUser(c)
+
+	var elections []models.Election
+	db.DB.Where("archived = ?", false).Order("start_time, id").Find(&elections)
+
+	type myElection struct {
+		models.Election
+		HasVoted bool
+	}
+	result := []myElection{}
+	for _, e := range withPhases(elections) {
+		if !electionEligible(&e, user) || Phase(e.Phase) == PhaseDraft {
+			continue
+		}
+		var voted int64
+		db.DB.Model(&models.ElectionVoter{}).Where("election_id = ? AND user_id = ? AND has_voted = ?", e.ID, user.ID, true).Count(&voted)
+		result = append(result, myElection{Election: e, HasVoted: voted > 0})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAdminElections lists every election, archived ones included, and which
+// one requests without an electionId refer to.
+func GetAdminElections(c *gin.Context) {
+	elections := []models.Election{}
+	db.DB.Order("archived, start_time, id").Find(&elections)
+
+	var currentID uint
+	if current, err := currentElection(); err == nil {
+		currentID = current.ID
+	}
+	c.JSON(http.StatusOK, gin.H{"elections": withPhases(elections), "currentId": currentID})
+}
+
+type electionRequest struct {
+	Name string `json:"name"`
+
+	// Schedule in WIB, 2006-01-02T15:04; an empty time is unscheduled
+	RegistrationStart string `json:"registrationStart"`
+	RegistrationEnd   string `json:"registrationEnd"`
+	StartTime         string `json:"startTime"`
+	EndTime           string `json:"endTime"`
+
+	// Voting booth sessions
+	VotingMinutes   int `json:"votingMinutes"`
+	SessionRegrants int `json:"sessionRegrants"`
+
+	// NIM rules for registration
+	NIMPrefixes    string `json:"nimPrefixes"`
+	NIMLength      int    `json:"nimLength"`
+	NIMPattern     string `json:"nimPattern"`
+	CohortPosition int    `json:"cohortPosition"`
+	CohortMin      int    `json:"cohortMin"`
+	CohortMax      int    `json:"cohortMax"`
+
+	PublicKey string `json:"publicKey"`
+
+	ShuffleOptions   bool   `json:"shuffleOptions"`
+	KotakKosongPlace string `json:"kotakKosongPlace"`
+
+	MinTurnout        int    `json:"minTurnout"`
+	MajorityRule      string `json:"majorityRule"`
+	KotakKosongPolicy string `json:"kotakKosongPolicy"`
+	KotakKosongWins   string `json:"kotakKosongWins"`
+	WithdrawnVotes    string `json:"withdrawnVotes"`
+}
+
+// apply validates the request and copies it onto e.
+func (req *electionRequest) apply(e *models.Election) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("Name is required")
+	}
+
+	// The schedule's steps, in the order the phases follow each other.
+	var times [4]*time.Time
+	var last *time.Time
+	for i, v := range []string{req.RegistrationStart, req.RegistrationEnd, req.StartTime, req.EndTime} {
+		if v == "" {
+			continue
+		}
+		t, err := time.ParseInLocation(electionTimeLayout, v, wibLocation)
+		if err != nil {
+			return errors.New("Times must be formatted as YYYY-MM-DDTHH:MM")
+		}
+		if last != nil && (t.Before(*last) || i == 3 && t.Equal(*last)) {
+			return errors.New("Registration must close before voting opens, and voting must open before it closes")
+		}
+		times[i], last = &t, &t
+	}
+
+	if req.VotingMinutes == 0 {
+		req.VotingMinutes = 5
+	}
+	if req.VotingMinutes < 1 || req.VotingMinutes > 120 {
+		return errors.New("Voting sessions must last between 1 and 120 minutes")
+	}
+	if req.SessionRegrants < 0 || req.SessionRegrants > 10 {
+		return errors.New("An admin may grant between 0 and 10 new sessions per voter")
+	}
+
+	rules := nimRules{
+		Prefixes:       nimPrefixes(req.NIMPrefixes),
+		Length:         req.NIMLength,
+		Pattern:        strings.TrimSpace(req.NIMPattern),
+		CohortPosition: req.CohortPosition,
+		CohortMin:      req.CohortMin,
+		CohortMax:      req.CohortMax,
+	}
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
+	if req.MinTurnout < 0 || req.MinTurnout > 100 {
+		return errors.New("Minimum turnout must be between 0 and 100 percent")
+	}
+	if req.MajorityRule == "" {
+		req.MajorityRule = majoritySimple
+	}
+	if req.MajorityRule != majoritySimple && req.MajorityRule != majorityAbsolute {
+		return errors.New("Majority rule must be 'simple' or 'absolute'")
+	}
+	if req.KotakKosongPolicy == "" {
+		req.KotakKosongPolicy = kotakKosongPerContest
+	}
+	switch req.KotakKosongPolicy {
+	case kotakKosongPerContest, kotakKosongAlways, kotakKosongNever, kotakKosongSingle:
+	default:
+		return errors.New("Unknown Kotak Kosong policy")
+	}
+	if req.KotakKosongWins == "" {
+		req.KotakKosongWins = kotakKosongVoid
+	}
+	if req.KotakKosongWins != kotakKosongVoid && req.KotakKosongWins != kotakKosongRunnerUp {
+		return errors.New("Kotak Kosong outcome must be 'void' or 'runner_up'")
+	}
+	if req.WithdrawnVotes == "" {
+		req.WithdrawnVotes = withdrawnVoid
+	}
+	if req.WithdrawnVotes != withdrawnVoid && req.WithdrawnVotes != withdrawnCount {
+		return errors.New("Votes for withdrawn candidates must be 'void' or 'count'")
+	}
+
+	if req.KotakKosongPlace == "" {
+		req.KotakKosongPlace = kotakKosongLast
+	}
+	switch req.KotakKosongPlace {
+	case kotakKosongLast, kotakKosongFirst:
+	case kotakKosongShuffled:
+		if !req.ShuffleOptions {
+			return errors.New("Kotak Kosong can only be shuffled in when the candidates are shuffled")
+		}
+	default:
+		return errors.New("Kotak Kosong must be placed 'first', 'last' or 'shuffled'")
+	}
+
+	publicKey := strings.TrimSpace(req.PublicKey)
+	if publicKey != "" {
+		if _, err := threshold.ParsePublicKey(publicKey); err != nil {
+			return errors.New("Invalid election public key")
+		}
+	}
+
+	e.Name = name
+	e.RegistrationStart, e.RegistrationEnd = times[0], times[1]
+	e.StartTime, e.EndTime = times[2], times[3]
+	e.VotingMinutes, e.SessionRegrants = req.VotingMinutes, req.SessionRegrants
+	e.NIMPrefixes = strings.Join(rules.Prefixes, ",")
+	e.NIMLength, e.NIMPattern = rules.Length, rules.Pattern
+	e.CohortPosition, e.CohortMin, e.CohortMax = rules.CohortPosition, rules.CohortMin, rules.CohortMax
+	e.PublicKey = publicKey
+	e.ShuffleOptions, e.KotakKosongPlace = req.ShuffleOptions, req.KotakKosongPlace
+	e.MinTurnout = req.MinTurnout
+	e.MajorityRule = req.MajorityRule
+	e.KotakKosongPolicy = req.KotakKosongPolicy
+	e.KotakKosongWins = req.KotakKosongWins
+	e.WithdrawnVotes = req.WithdrawnVotes
+	return nil
+}
+
+func CreateElection(c *gin.Context) {
+	var req electionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	election := models.Election{Phase: string(PhaseDraft)}
+	if err := req.apply(&election); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// A new election starts with one contest so candidates can be added
+	// straight away.
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&election).Error; err != nil {
+			return err
+		}
+		return tx.Create(newDefaultContest(&election)).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create election"})
+		return
+	}
+
+	log.Printf("Admin %d created election %d (%s)", currentUser(c).ID, election.ID, election.Name)
+	c.JSON(http.StatusCreated, election)
+}
+
+func UpdateElection(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	var req electionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	before := *election
+	if err := req.apply(election); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if votesCast(election.ID) {
+		// Ballots already cast are encrypted to the old key.
+		if election.PublicKey != before.PublicKey {
+			c.JSON(http.StatusConflict, gin.H{"error": "The public key cannot change once votes were cast"})
+			return
+		}
+		if !sameRules(election, &before) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The election rules cannot change once votes were cast"})
+			return
+		}
+	}
+
+	if err := db.DB.Save(election).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update election"})
+		return
+	}
+	log.Printf("Admin %d updated election %d", currentUser(c).ID, election.ID)
+	c.JSON(http.StatusOK, election)
+}
+
+// SetElectionArchived hides an election from voters while keeping its data.
+func SetElectionArchived(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	var req struct {
+		Archived bool `json:"archived"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Archived && electionOpen(election, time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An election cannot be archived while voting is open"})
+		return
+	}
+
+	if err := db.DB.Model(election).Update("archived", req.Archived).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update election"})
+		return
+	}
+	log.Printf("Admin %d set election %d archived=%v", currentUser(c).ID, election.ID, req.Archived)
+	c.JSON(http.StatusOK, election)
+}
+
+// SendElectionReminders emails every eligible voter once when an election
+// starts within the next 24 hours.
+func SendElectionReminders() {
+	now := time.Now()
+	var elections []models.Election
+	db.DB.Where("archived = ? AND start_time > ? AND start_time < ?", false, now, now.Add(24*time.Hour)).Find(&elections)
+
+	for _, e := range elections {
+		var users []models.User
+		db.DB.Where("role = ? AND verification_status = ?", models.RoleVoter, "approved").
+			Where("NOT EXISTS (SELECT 1 FROM election_voters ev WHERE ev.user_id = users.id AND ev.election_id = ? AND ev.reminder_sent_at IS NOT NULL)", e.ID).
+			Scopes(nimPrefixScope(e.NIMPrefixes, "nim"), rollScope(e.ID, "nim")).
+			Find(&users)
+
+		for _, u := range users {
+			if u.Email == "" || u.TokenHash == "" {
+				continue
+			}
+			if err := email.SendReminderEmail(u.Email, u.Name, e.Name); err != nil {
+				log.Printf("Failed to send reminder to %s: %v", u.Email, err)
+				continue
+			}
+			voter, err := electionVoter(db.DB, e.ID, u.ID)
+			if err == nil {
+				sentAt := time.Now()
+				db.DB.Model(voter).Update("reminder_sent_at", &sentAt)
+			}
+			log.Printf("Reminder for election %d sent to %s", e.ID, u.Email)
+		}
+	}
+}
+
+// MigrateElections moves the single election of earlier builds, whose
+// schedule lived in the startTime/endTime settings and whose voter state lived
+// on users, into a first Election row that owns all existing data.
+func MigrateElections() error {
+	return db.RunMigration("scope_data_by_election", func(tx *gorm.DB) error {
+		election := models.Election{Name: "Pemilihan Ketua BP HMS", PublicKey: os.Getenv("ELECTION_PUBLIC_KEY")}
+		var settings []models.Setting
+		tx.Where("key IN ?", []string{"startTime", "endTime"}).Find(&settings)
+		for _, s := range settings {
+			t, err := time.ParseInLocation(electionTimeLayout, s.Value, wibLocation)
+			if err != nil {
+				continue
+			}
+			if s.Key == "startTime" {
+				election.StartTime = &t
+			} else {
+				election.EndTime = &t
+			}
+		}
+		if err := tx.Create(&election).Error; err != nil {
+			return err
+		}
+
+		for _, table := range []string{"candidates", "participations", "ballots", "tally_shares"} {
+			if err := tx.Table(table).Where("election_id IS NULL OR election_id = 0").Update("election_id", election.ID).Error; err != nil {
+				return err
+			}
+		}
+		// Share indexes are now unique per election.
+		if tx.Migrator().HasIndex(&models.TallyShare{}, "idx_tally_shares_x") {
+			if err := tx.Migrator().DropIndex(&models.TallyShare{}, "idx_tally_shares_x"); err != nil {
+				return err
+			}
+		}
+
+		if tx.Migrator().HasColumn(&models.User{}, "has_voted") {
+			// Entry times are left behind: voting sessions replaced them.
+			err := tx.Exec(`INSERT INTO election_voters (election_id, user_id, has_voted, reminder_sent_at)
+				SELECT ?, id, COALESCE(has_voted, false), CASE WHEN reminder_sent THEN now() END
+				FROM users WHERE has_voted OR reminder_sent`, election.ID).Error
+			if err != nil {
+				return err
+			}
+			for _, column := range []string{"has_voted", "reminder_sent", "vote_entry_time"} {
+				if err := tx.Migrator().DropColumn(&models.User{}, column); err != nil {
+					return err
+				}
+			}
+		}
+
+		log.Printf("scope_data_by_election: created election %d from the global settings", election.ID)
+		return tx.Where("key IN ?", []string{"startTime", "endTime"}).Delete(&models.Setting{}).Error
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/email"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registrationConfirmTTL is how long an unconfirmed registration (and its
+// confirmation link) stays valid (REGISTRATION_CONFIRM_TTL, default 48h).
+func registrationConfirmTTL() time.Duration {
+	if v := os.Getenv("REGISTRATION_CONFIRM_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 48 * time.Hour
+}
+
+// sendConfirmationEmail emails a signed link bound to the user's current address.
+func sendConfirmationEmail(user *models.User) error {
+	now := time.Now()
+	token, err := auth.Sign(auth.Claims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		Purpose:   auth.PurposeConfirm,
+		Email:     user.Email,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(registrationConfirmTTL()).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := clientURL() + "/confirm-email?token=" + url.QueryEscape(token)
+	go func() {
+		if err := email.SendConfirmationEmail(user.Email, user.Name, link); err != nil {
+			log.Printf("Failed to send confirmation email to %s: %v", user.Email, err)
+		} else {
+			log.Printf("Confirmation email sent to %s", user.Email)
+		}
+	}()
+	return nil
+}
+
+// ConfirmEmail moves an unconfirmed registration into the admin review queue.
+func ConfirmEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	claims, err := auth.Parse(req.Token)
+	if errors.Is(err, auth.ErrExpiredToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tautan konfirmasi sudah kedaluwarsa. Silakan daftar ulang."})
+		return
+	}
+	if err != nil || claims.Purpose != auth.PurposeConfirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tautan konfirmasi tidak valid"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, claims.UserID).Error; err != nil || user.Email != claims.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tautan konfirmasi tidak valid"})
+		return
+	}
+	if user.VerificationStatus != "unconfirmed" {
+		c.JSON(http.StatusOK, gin.H{"message": "Email sudah dikonfirmasi"})
+		return
+	}
+
+	res := db.DB.Model(&models.User{}).
+		Where("id = ? AND verification_status = ?", user.ID, "unconfirmed").
+		Update("verification_status", "pending")
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengonfirmasi email"})
+		return
+	}
+
+	go func() {
+		if err := email.SendWelcomeEmail(user.Email, user.Name); err != nil {
+			log.Printf("Failed to send welcome email to %s: %v", user.Email, err)
+		} else {
+			log.Printf("Welcome email sent to %s", user.Email)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email berhasil dikonfirmasi. Pendaftaran Anda menunggu verifikasi admin."})
+}
+
+// ResendConfirmation sends a new confirmation link for a still-unconfirmed
+// registration. It answers the same way for unknown addresses.
+func ResendConfirmation(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email wajib diisi"})
+		return
+	}
+
+	if !throttleEmailRequest(c, "resend_confirmation", req.Email) {
+		return
+	}
+
+	response := gin.H{"message": "Jika pendaftaran belum dikonfirmasi, tautan baru telah dikirim."}
+
+	var user models.User
+	if err := db.DB.Where("email = ? AND verification_status = ?", req.Email, "unconfirmed").First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	now := time.Now()
+	user.ConfirmationSentAt = &now
+	db.DB.Model(&user).Update("confirmation_sent_at", now)
+	if err := sendConfirmationEmail(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengirim email konfirmasi"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PurgeUnconfirmedRegistrations deletes registrations whose confirmation
+// window has passed, freeing their NIM and email for a new attempt.
+func PurgeUnconfirmedRegistrations() {
+	cutoff := time.Now().Add(-registrationConfirmTTL())
+	res := db.DB.Where("verification_status = ? AND (confirmation_sent_at IS NULL OR confirmation_sent_at < ?)", "unconfirmed", cutoff).
+		Delete(&models.User{})
+	if res.Error != nil {
+		log.Printf("Failed to purge unconfirmed registrations: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Purged %d expired unconfirmed registrations", res.RowsAffected)
+	}
+}
@@ -1,28 +1,40 @@
 package handlers
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
+	"voting-backend/internal/auth"
 	"voting-backend/internal/db"
 	"voting-backend/internal/email"
 	"voting-backend/internal/imgbb"
 	"voting-backend/internal/models"
+	"voting-backend/internal/threshold"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 var wibLocation = time.FixedZone("WIB", 7*3600)
 
+var errNotPending = errors.New("user is not pending verification")
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Token    string `json:"token"`
 }
 
+// LoginResponse is the user row plus the signed session token for later calls.
+type LoginResponse struct {
+	models.User
+	SessionToken string    `json:"sessionToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
 func Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -30,11 +42,16 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if !checkLockout(c, req.Email) {
+		return
+	}
+
 	// User Login (Email + Password)
 	var user models.User
 	result := db.DB.Where("email = ?", req.Email).First(&user)
 
 	if result.Error != nil {
+		recordLoginFailure(c, req.Email, "login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not registered"})
 		return
 	}
@@ -42,58 +59,85 @@ func Login(c *gin.Context) {
 	// Check Password
 	if user.Password == nil {
 		// Fallback for old users without password if necessary, or just fail
+		recordLoginFailure(c, req.Email, "login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)); err != nil {
+		recordLoginFailure(c, req.Email, "login")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	// Staff must go through AdminLogin so the second factor applies.
+	if models.IsStaffRole(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin accounts must use the admin login"})
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+		return
+	}
+
 	// Check Verification Status
-	// Admins are auto-approved. Voters need approval.
+	if user.VerificationStatus == "unconfirmed" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email belum dikonfirmasi. Cek inbox Anda."})
+		return
+	}
 	if user.VerificationStatus != "approved" {
 		c.JSON(http.StatusForbidden, gin.H{"error": "User not verified yet"})
 		return
 	}
 
-	// Check Token for Voters
-	if user.Role != "admin" {
-		if req.Token != user.Token {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing voting token"})
-			return
-		}
+	// Check Voting Token
+	if !auth.VotingTokenMatches(req.Token, user.TokenHash) {
+		recordLoginFailure(c, req.Email, "login")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing voting token"})
+		return
 	}
+	recordLoginSuccess(c, req.Email, "login")
 
-	// Check Election Timing
-	if user.Role != "admin" {
-		// Check Election Timing for Login (Allowed 24h before)
-		var startSetting models.Setting
-		if err := db.DB.Where("key = ?", "startTime").First(&startSetting).Error; err == nil && startSetting.Value != "" {
-			startTime, err := time.ParseInLocation("2006-01-02T15:04", startSetting.Value, wibLocation)
-			if err == nil {
-				// Allow login 24 hours before election starts for verification
-				allowedLoginTime := startTime.Add(-24 * time.Hour)
-				if time.Now().In(wibLocation).Before(allowedLoginTime) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "Login hanya dibuka 24 jam sebelum pemilihan dimulai."})
-					return
-				}
-			}
-		}
-		var endSetting models.Setting
-		if err := db.DB.Where("key = ?", "endTime").First(&endSetting).Error; err == nil && endSetting.Value != "" {
-			endTime, err := time.ParseInLocation("2006-01-02T15:04", endSetting.Value, wibLocation)
-			if err == nil {
-				if time.Now().In(wibLocation).After(endTime) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "Pemilihan sudah berakhir."})
-					return
-				}
+	if msg := loginWindowError(); msg != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+	if msg := rollLoginError(user.NIM); msg != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	token, expiresAt, ok := newSessionToken(c, &user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{User: user, SessionToken: token, ExpiresAt: expiresAt})
+}
+
+// loginWindowError returns why voters cannot log in right now, or "" if they
+// can. Voters log in while an election is in its verification or voting
+// phase; with several elections, one of them being there is enough.
+func loginWindowError() string {
+	var elections []models.Election
+	db.DB.Where("archived = ?", false).Find(&elections)
+
+	msg := ""
+	now := time.Now()
+	for _, e := range elections {
+		switch phase := electionPhase(&e, now); {
+		case phase == PhaseVerification || phase == PhaseVoting:
+			return ""
+		case phaseAfter(phase, PhaseVoting):
+			if msg == "" {
+				msg = "Pemilihan sudah berakhir."
 			}
+		default:
+			msg = "Login dibuka pada tahap verifikasi, menjelang pemungutan suara."
 		}
 	}
-
-	c.JSON(http.StatusOK, user)
+	return msg
 }
 
 func Register(c *gin.Context) {
@@ -107,25 +151,27 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Validate NIM format: must be 150 followed by 5 digits
-	if len(nim) != 8 || nim[:3] != "150" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Format NIM harus 150xxxxx (8 digit, dimulai dengan 150)"})
+	// The NIM must follow the election's rules and, once the official voter
+	// roll is imported, be on it.
+	election := resolveElection(c)
+	if election == nil {
 		return
 	}
-	// Check if last 5 characters are digits
-	for i := 3; i < 8; i++ {
-		if nim[i] < '0' || nim[i] > '9' {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Format NIM harus 150xxxxx (x harus angka)"})
-			return
-		}
+	if msg := nimRuleError(election, nim); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !onRoll(election, nim) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "NIM Anda tidak terdaftar dalam Daftar Pemilih Tetap"})
+		return
 	}
 
 	// Check if user exists by NIM or Email
 	var existingUser models.User
 	if err := db.DB.Where("nim = ? OR email = ?", nim, userEmail).First(&existingUser).Error; err == nil {
-		// User exists. Check status.
-		if existingUser.VerificationStatus == "rejected" {
-			// Allow Re-registration (Update)
+		// User exists. Check status; staff accounts are never taken over.
+		if existingUser.Role == models.RoleVoter && (existingUser.VerificationStatus == "rejected" || existingUser.VerificationStatus == "unconfirmed") {
+			// Allow Re-registration (Update), e.g. after a rejection or a mistyped email
 			// Proceed to update this user instead of creating new
 		} else {
 			if existingUser.NIM == nim {
@@ -172,6 +218,7 @@ func Register(c *gin.Context) {
 	passStr := string(hashedPassword)
 
 	var newUser models.User // Declare outside to make it accessible
+	now := time.Now()
 
 	if existingUser.ID != 0 {
 		// UPDATE existing rejected user
@@ -181,8 +228,9 @@ func Register(c *gin.Context) {
 		existingUser.Password = &passStr
 		existingUser.ProfileImage = profileLink
 		existingUser.KTMImage = ktmLink
-		existingUser.VerificationStatus = "pending" // Reset to pending
-		
+		existingUser.VerificationStatus = "unconfirmed" // Back to email confirmation
+		existingUser.ConfirmationSentAt = &now
+
 		if err := db.DB.Save(&existingUser).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui pendaftaran"})
 			return
@@ -198,7 +246,8 @@ func Register(c *gin.Context) {
 			Role:               "voter",
 			ProfileImage:       profileLink,
 			KTMImage:           ktmLink,
-			VerificationStatus: "pending",
+			VerificationStatus: "unconfirmed",
+			ConfirmationSentAt: &now,
 		}
 
 		if err := db.DB.Create(&newUser).Error; err != nil {
@@ -207,49 +256,108 @@ func Register(c *gin.Context) {
 		}
 	}
 
-	go func() {
-		if err := email.SendWelcomeEmail(newUser.Email, newUser.Name); err != nil {
-			log.Printf("Failed to send welcome email to %s: %v", newUser.Email, err)
-		} else {
-			log.Printf("Welcome email sent to %s", newUser.Email)
-		}
-	}()
+	// The registration only reaches the admins once the email is confirmed.
+	if err := sendConfirmationEmail(&newUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengirim email konfirmasi"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, newUser)
 }
 
 func GetCandidates(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
 	candidates := []models.Candidate{}
-	db.DB.Find(&candidates)
+	db.DB.Where("election_id = ?", election.ID).Scopes(candidateOrder).Find(&candidates)
 	c.JSON(http.StatusOK, candidates)
 }
 
 func CreateCandidate(c *gin.Context) {
-	name := c.PostForm("name")
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	// Candidates join the contest named by contestId, or the election's
+	// first candidate contest.
+	var contest models.Contest
+	query := db.DB.Where("election_id = ? AND kind = ?", election.ID, contestCandidates)
+	if contestID := c.PostForm("contestId"); contestID != "" {
+		query = query.Where("id = ?", contestID)
+	}
+	if err := query.Order("position, id").First(&contest).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Contest not found in this election"})
+		return
+	}
 	// Desc removed
 	visi := c.PostForm("visi")
 	misi := c.PostForm("misi")
 
-	file, err := c.FormFile("image")
+	// A pair lists its members in ballot order, chair first.
+	members, err := candidateMembers(c, nil)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Image is required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	link, err := imgbb.UploadImage(file)
+	name := candidateName(c.PostForm("name"), members)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+	ballotNumber, err := ballotNumberFromRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload to ImgBB: " + err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ballotNumber != nil && ballotNumbersDrawn(election.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": errBallotNumbersDrawn.Error()})
+		return
+	}
+	if ballotNumber != nil && ballotNumberTaken(election.ID, *ballotNumber, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": errBallotNumberTaken.Error()})
+		return
+	}
+
+	// The image may be left out when the members have photos; the first
+	// member's then stands for the candidate.
+	var link string
+	if file, err := c.FormFile("image"); err == nil {
+		link, err = imgbb.UploadImage(file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload to ImgBB: " + err.Error()})
+			return
+		}
+	} else {
+		for _, m := range members {
+			if m.ImageURL != "" {
+				link = m.ImageURL
+				break
+			}
+		}
+	}
+	if link == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image is required"})
 		return
 	}
 
 	candidate := models.Candidate{
-		Name:     name,
-		Visi:     visi,
-		Misi:     misi,
-		ImageURL: link,
+		ElectionID:   election.ID,
+		ContestID:    contest.ID,
+		BallotNumber: ballotNumber,
+		Name:         name,
+		Visi:         visi,
+		Misi:         misi,
+		ImageURL:     link,
+		Members:      members,
 	}
 
 	if err := db.DB.Create(&candidate).Error; err != nil {
+		if ballotNumber != nil && ballotNumberTaken(election.ID, *ballotNumber, candidate.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": errBallotNumberTaken.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create candidate"})
 		return
 	}
@@ -257,9 +365,26 @@ func CreateCandidate(c *gin.Context) {
 	c.JSON(http.StatusCreated, candidate)
 }
 
+// DeleteCandidate removes a candidate while no votes were cast. Ballots are
+// sealed until the tally, so any of them may hold the candidate; once voting
+// has started a candidate can only withdraw.
 func DeleteCandidate(c *gin.Context) {
-	id := c.Param("id")
-	if err := db.DB.Delete(&models.Candidate{}, id).Error; err != nil {
+	var candidate models.Candidate
+	if err := db.DB.First(&candidate, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		return
+	}
+	if votesCast(candidate.ElectionID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Votes were cast in this election; withdraw the candidate instead"})
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("candidate_id = ?", candidate.ID).Delete(&models.CandidateMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&candidate).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete candidate"})
 		return
 	}
@@ -267,9 +392,17 @@ func DeleteCandidate(c *gin.Context) {
 }
 
 func UploadVerification(c *gin.Context) {
-	nim := c.PostForm("nim")
-	if nim == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "NIM is required"})
+	user := currentUser(c)
+	if nim := c.PostForm("nim"); nim != "" && nim != user.NIM {
+		c.JSON(http.StatusForbidden, gin.H{"error": "NIM does not match the logged in user"})
+		return
+	}
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	if msg := nimRuleError(election, user.NIM); msg != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
 		return
 	}
 
@@ -295,24 +428,24 @@ func UploadVerification(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := db.DB.Where("nim = ?", nim).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
 	user.ProfileImage = profileLink
 	user.KTMImage = ktmLink
-	// Don't reset verification status - keep existing status
-	db.DB.Save(&user)
+	// Don't reset verification status - keep existing status. SSO accounts
+	// start at 'none' and enter the review queue with their first upload.
+	if user.VerificationStatus == "none" {
+		user.VerificationStatus = "pending"
+	}
+	db.DB.Save(user)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Verification uploaded successfully", "user": user})
 }
 
+// GetPendingUsers lists registrations awaiting verification, each compared
+// with the voter roll so the verifier sees names that do not match.
 func GetPendingUsers(c *gin.Context) {
 	users := []models.User{}
-	db.DB.Where("verification_status = ?", "pending").Find(&users)
-	c.JSON(http.StatusOK, users)
+	db.DB.Where("verification_status = ?", "pending").Scopes(nimScope(currentUser(c), "nim")).Find(&users)
+	c.JSON(http.StatusOK, matchRoll(users))
 }
 
 func SearchUsers(c *gin.Context) {
@@ -323,9 +456,11 @@ func SearchUsers(c *gin.Context) {
 	}
 
 	users := []models.User{}
-	// Search by NIM or Name (case-insensitive), exclude admin role and users without names
+	// Search by NIM or Name (case-insensitive), voters only and users without names excluded
 	searchPattern := "%" + query + "%"
-	db.DB.Where("(nim ILIKE ? OR name ILIKE ?) AND role != ? AND name != ''", searchPattern, searchPattern, "admin").Find(&users)
+	db.DB.Where("(nim ILIKE ? OR name ILIKE ?) AND role = ? AND name != ''", searchPattern, searchPattern, models.RoleVoter).
+		Scopes(nimScope(currentUser(c), "nim")).
+		Find(&users)
 	c.JSON(http.StatusOK, users)
 }
 
@@ -333,9 +468,22 @@ func GetAllUsers(c *gin.Context) {
 	query := c.Query("q")
 	verificationStatus := c.Query("verificationStatus")
 	hasVoted := c.Query("hasVoted")
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
 
-	var users []models.User
-	dbQuery := db.DB.Where("role = ?", "voter")
+	// HasVoted is the voter's state in the selected election.
+	type userRow struct {
+		models.User
+		HasVoted bool
+	}
+	var users []userRow
+	dbQuery := db.DB.Model(&models.User{}).
+		Select("users.*, COALESCE(ev.has_voted, false) AS has_voted").
+		Joins("LEFT JOIN election_voters ev ON ev.user_id = users.id AND ev.election_id = ?", election.ID).
+		Where("role = ?", models.RoleVoter).
+		Scopes(nimScope(currentUser(c), "nim"))
 
 	if query != "" {
 		searchPattern := "%" + query + "%"
@@ -348,13 +496,13 @@ func GetAllUsers(c *gin.Context) {
 
 	if hasVoted != "" && hasVoted != "all" {
 		if hasVoted == "yes" {
-			dbQuery = dbQuery.Where("has_voted = ?", true)
+			dbQuery = dbQuery.Where("ev.has_voted = ?", true)
 		} else if hasVoted == "no" {
-			dbQuery = dbQuery.Where("(has_voted = ? OR has_voted IS NULL)", false)
+			dbQuery = dbQuery.Where("(ev.has_voted = ? OR ev.has_voted IS NULL)", false)
 		}
 	}
 
-	if err := dbQuery.Find(&users).Error; err != nil {
+	if err := dbQuery.Scan(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
@@ -372,32 +520,64 @@ func VerifyUser(c *gin.Context) {
 		return
 	}
 
+	// Only voters are verified here; staff accounts are managed elsewhere.
 	var user models.User
-	if err := db.DB.First(&user, req.UserID).Error; err != nil {
+	if err := db.DB.Where("role = ?", models.RoleVoter).First(&user, req.UserID).Error; err != nil || !inNIMScope(currentUser(c), user.NIM) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	// A decision is made once; a rejected voter re-registers to be reviewed again.
+	if user.VerificationStatus != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is not pending verification"})
+		return
+	}
 
 	if req.Action == "approve" {
+		// Generate Token; only its hash is kept, the voter gets it by email
+		token := auth.NewVotingToken()
+		user.TokenHash = auth.HashVotingToken(token)
 		user.VerificationStatus = "approved"
-		// Generate Token
-		user.Token = uuid.New().String()
-		// Send Email
+		result := db.DB.Model(&models.User{}).
+			Where("id = ? AND verification_status = ?", user.ID, "pending").
+			Updates(map[string]interface{}{"verification_status": "approved", "token_hash": user.TokenHash})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve user"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is not pending verification"})
+			return
+		}
+
 		go func() {
-			if err := email.SendApprovalEmail(user.Email, user.Name, user.Token); err != nil {
+			if err := email.SendApprovalEmail(user.Email, user.Name, token); err != nil {
 				log.Printf("Failed to send approval email to %s: %v", user.Email, err)
 			} else {
 				log.Printf("Approval email sent to %s", user.Email)
 			}
 		}()
-		fmt.Printf("Sending Email to %s... Your Token is: %s\n", user.Email, user.Token)
 
-		db.DB.Save(&user)
 		c.JSON(http.StatusOK, gin.H{"message": "User approved", "user": user})
 	} else if req.Action == "reject" {
 		// Mark as rejected instead of deleting
 		user.VerificationStatus = "rejected"
-		if err := db.DB.Save(&user).Error; err != nil {
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.User{}).
+				Where("id = ? AND verification_status = ?", user.ID, "pending").
+				Update("verification_status", "rejected")
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errNotPending
+			}
+			return revokeUserSessions(tx, user.ID, revokeRejected)
+		})
+		if errors.Is(err, errNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is not pending verification"})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject user"})
 			return
 		}
@@ -408,312 +588,167 @@ func VerifyUser(c *gin.Context) {
 	}
 }
 
+// Vote casts the voter's ballot for every contest of the election at once.
+// A retry sent with the same Idempotency-Key gets the original response.
 func Vote(c *gin.Context) {
-	userIDStr := c.PostForm("userId")
-	candidateIDStr := c.PostForm("candidateId")
-
-	if userIDStr == "" || candidateIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID Pengguna dan ID Kandidat wajib diisi"})
+	user := currentUser(c)
+	election := resolveElection(c)
+	if election == nil {
 		return
 	}
-
-	var user models.User
-	if err := db.DB.First(&user, userIDStr).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pengguna tidak ditemukan"})
+	key, ok := idempotencyKey(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key tidak valid"})
 		return
 	}
-
-	if user.HasVoted {
-		c.JSON(http.StatusConflict, gin.H{"error": "Pengguna sudah memilih"})
+	if key != "" && replayVote(c, election.ID, user.ID, key) {
 		return
 	}
-
-	// Double-check election timing strictly for Voting action
-	var startSetting models.Setting
-	if err := db.DB.Where("key = ?", "startTime").First(&startSetting).Error; err == nil && startSetting.Value != "" {
-		startTime, err := time.ParseInLocation("2006-01-02T15:04", startSetting.Value, wibLocation)
-		if err == nil {
-			if time.Now().In(wibLocation).Before(startTime) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Pemilihan belum dimulai."})
-				return
-			}
-		}
-	}
-
-	// Atomic transaction
-	tx := db.DB.Begin()
-
-	if err := tx.Model(&user).Update("has_voted", true).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui status pengguna"})
+	contests, err := electionContests(election)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
 		return
 	}
-
-	vote := models.Vote{
-		UserID:      user.ID,
-		CandidateID: 0,
-		Timestamp:   time.Now(),
-		KTMImage:    user.KTMImage,     // Use User's existing images
-		SelfImage:   user.ProfileImage, // Use User's existing images
-		Status:      "pending",
+	selections, err := selectionsFromRequest(c, contests)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// CHECK 5-MINUTE TIME LIMIT
-	if user.VoteEntryTime == nil {
-		// Strict: If they never entered via EnterVoting, reject or block. 
-		// Decision: Reject as "Tidak Sah" because flow was skipped.
-		vote.Status = "rejected"
-		vote.RejectionReason = "Bypassed Voting Entry (Suspicious)"
-	} else {
-		// Calculate time difference
-		if time.Since(*user.VoteEntryTime) > 5*time.Minute {
-			vote.Status = "rejected"
-			vote.RejectionReason = "Time Limit Exceeded (> 5 minutes)"
-		} else {
-			vote.Status = "pending"
-		}
+	if user.VerificationStatus != "approved" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User not verified yet"})
+		return
 	}
-
-	// Handle Kotak Kosong (ID=0)
-	if candidateIDStr == "0" {
-		vote.CandidateID = 0
-	} else {
-		var cand models.Candidate
-		if err := db.DB.Where("id = ?", candidateIDStr).First(&cand).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Kandidat tidak valid"})
-			return
-		}
-		vote.CandidateID = cand.ID
+	if !electionEligible(election, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Anda tidak terdaftar sebagai pemilih pada pemilihan ini"})
+		return
 	}
-
-	if err := tx.Create(&vote).Error; err != nil {
-		tx.Rollback()
+	voter, err := electionVoter(db.DB, election.ID, user.ID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
 		return
 	}
-
-	tx.Commit()
-
-	// Return appropriate message based on status
-	if vote.Status == "rejected" {
-		c.JSON(http.StatusOK, gin.H{"message": "Waktu habis (5 menit). Suara Anda dianggap TIDAK SAH."})
+	if voter.HasVoted {
+		// A retry of this very ballot may have been cast since the check above.
+		if key != "" && replayVote(c, election.ID, user.ID, key) {
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": errAlreadyVoted.Error()})
 		return
 	}
 
-	go func() {
-		candidateName := "Kotak Kosong"
-		if candidateIDStr != "0" {
-			var cand models.Candidate
-			if err := db.DB.First(&cand, vote.CandidateID).Error; err == nil {
-				candidateName = cand.Name
-			}
-		}
-		if err := email.SendVoteConfirmation(user.Email, user.Name, candidateName); err != nil {
-			log.Printf("Failed to send vote confirmation to %s: %v", user.Email, err)
-		} else {
-			log.Printf("Vote confirmation email sent to %s", user.Email)
+	// The ballot must come from the voter's running session in the booth.
+	// A late ballot is refused, not spoiled, so the voter keeps their vote
+	// if an admin grants them a new session.
+	now := time.Now()
+	session, err := votingSessionFor(election.ID, user.ID, c.PostForm("sessionNonce"), now)
+	if err != nil {
+		// Casting uses the session up, so a retry may find it gone.
+		if key != "" && replayVote(c, election.ID, user.ID, key) {
+			return
 		}
-	}()
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "sessionExpired": errors.Is(err, errSessionExpired)})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Suara berhasil diberikan"})
-}
+	participation := models.Participation{
+		ElectionID: election.ID,
+		UserID:     user.ID,
+		CastAt:     now,
+		KTMImage:   user.KTMImage,     // Use User's existing images
+		SelfImage:  user.ProfileImage, // Use User's existing images
+		Status:     "pending",
+	}
 
-func EnterVoting(c *gin.Context) {
-	var req struct {
-		UserID uint `json:"userId"`
+	// Every contest must be answered within its rules (Kotak Kosong is ID 0).
+	if err := validateSelections(contests, selections); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if err := withdrawnChoice(contests, selections); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var user models.User
-	if err := db.DB.First(&user, req.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	// The choices are encrypted to the election key right away, so refuse
+	// votes until the key is set up.
+	pub, err := electionPublicKey(election)
+	if err != nil {
+		log.Printf("Vote refused: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Kunci pemilihan belum dikonfigurasi. Hubungi panitia."})
 		return
 	}
 
-	// IF user has not entered yet, set the time.
-	// If they refresh, do NOT update the time (keep the first entry time).
-	if user.VoteEntryTime == nil {
-		now := time.Now()
-		user.VoteEntryTime = &now
-		db.DB.Save(&user)
+	// The receipt lets the voter find their ballot on the bulletin board later.
+	receipt, err := auth.NewReceiptCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
+		return
 	}
 
-	// Return the entry time so frontend can sync if needed (optional)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Entry time recorded", 
-		"entryTime": user.VoteEntryTime,
-	})
-}
-
-func GetPendingVotes(c *gin.Context) {
-	// Custom struct to return user info along with vote
-	type PendingVote struct {
-		ID            uint   `json:"id"`
-		UserName      string `json:"userName"`
-		UserNIM       string `json:"userNim"`
-		UserEmail     string `json:"userEmail"`
-		KTMImage      string `json:"ktmImage"`
-		SelfImage     string `json:"selfImage"`
-		CandidateName string `json:"candidateName"`
-	}
-	votes := []PendingVote{}
-
-	db.DB.Table("votes").
-		Select("votes.id as id, users.name as user_name, users.nim as user_nim, users.email as user_email, votes.ktm_image as ktm_image, votes.self_image as self_image, COALESCE(candidates.name, 'Kotak Kosong') as candidate_name").
-		Joins("left join users on users.id = votes.user_id").
-		Joins("left join candidates on candidates.id = votes.candidate_id").
-		Where("votes.status = ?", "pending").
-		Scan(&votes)
-
-	c.JSON(http.StatusOK, votes)
-}
-
-func GetRejectedVotes(c *gin.Context) {
-	// Reusing PendingVote struct for consistency as it contains necessary user/candidate info
-	type PendingVote struct {
-		ID            uint   `json:"id"`
-		UserName      string `json:"userName"`
-		UserNIM       string `json:"userNim"`
-		UserEmail     string `json:"userEmail"`
-		KTMImage      string `json:"ktmImage"`
-		SelfImage     string `json:"selfImage"`
-		CandidateName string `json:"candidateName"`
-		Status        string `json:"status"`
-		RejectionReason string `json:"rejectionReason"`
-	}
-	votes := []PendingVote{}
-
-	db.DB.Table("votes").
-		Select("votes.id as id, users.name as user_name, users.nim as user_nim, users.email as user_email, votes.ktm_image as ktm_image, votes.self_image as self_image, COALESCE(candidates.name, 'Kotak Kosong') as candidate_name, votes.status as status, votes.rejection_reason").
-		Joins("left join users on users.id = votes.user_id").
-		Joins("left join candidates on candidates.id = votes.candidate_id").
-		Where("votes.status = ?", "rejected").
-		Scan(&votes)
-
-	c.JSON(http.StatusOK, votes)
-}
-
-func SearchVotes(c *gin.Context) {
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+	// Only the tally ceremony can read the choices. Until the participation
+	// is reviewed they are sealed with the receipt, which links them to it.
+	payload, err := json.Marshal(selections)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
 		return
 	}
-
-	type VoteResult struct {
-		ID            uint   `json:"id"`
-		UserID        uint   `json:"userId"`
-		UserName      string `json:"userName"`
-		UserNIM       string `json:"userNim"`
-		UserEmail     string `json:"userEmail"`
-		KTMImage      string `json:"ktmImage"`
-		SelfImage     string `json:"selfImage"`
-		CandidateName string `json:"candidateName"`
-		Status        string `json:"status"`
+	ciphertext, err := threshold.Encrypt(pub, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
+		return
 	}
-	votes := []VoteResult{}
-
-	searchPattern := "%" + query + "%"
-	db.DB.Table("votes").
-		Select("votes.id as id, votes.user_id as userId, users.name as userName, users.nim as userNim, users.email as userEmail, votes.ktm_image as ktm_image, votes.self_image as self_image, COALESCE(candidates.name, 'Kotak Kosong') as candidateName, votes.status as status").
-		Joins("left join users on users.id = votes.user_id").
-		Joins("left join candidates on candidates.id = votes.candidate_id").
-		Where("users.nim ILIKE ? OR users.name ILIKE ?", searchPattern, searchPattern).
-		Scan(&votes)
-
-	c.JSON(http.StatusOK, votes)
-}
-
-func ApproveVote(c *gin.Context) {
-	var req struct {
-		VoteID uint   `json:"voteId"`
-		Action string `json:"action"` // 'approve' or 'reject'
+	envelope, err := auth.SealBallot(ciphertext, receipt)
+	if errors.Is(err, auth.ErrNoEnvelopeKey) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Kunci pemilihan belum dikonfigurasi. Hubungi panitia."})
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
 		return
 	}
+	participation.Envelope = envelope
 
-	var vote models.Vote
-	if err := db.DB.First(&vote, req.VoteID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Vote not found"})
+	reply, err := json.Marshal(gin.H{"message": "Suara berhasil diberikan", "receipt": receipt})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
 		return
 	}
-
-	if req.Action == "approve" {
-		vote.Status = "approved"
-		db.DB.Save(&vote)
-	} else if req.Action == "reject" {
-		// Just mark as rejected. User CANNOT vote again.
-		vote.Status = "rejected"
-		vote.RejectionReason = "Rejected by Admin" // Default reason for manual rejection
-		db.DB.Save(&vote)
+	if key != "" {
+		participation.IdempotencyKey = auth.HashToken(key)
+		if participation.Reply, err = auth.SealReply(key, reply); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
+			return
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Vote processed"})
-}
-
-func GetResults(c *gin.Context) {
-	type Result struct {
-		CandidateID uint   `json:"candidateId"`
-		Name        string `json:"name"`
-		ImageURL    string `json:"imageUrl"`
-		Count       int64  `json:"count"`
+	err = castVote(voter, session, &participation)
+	if errors.Is(err, errAlreadyVoted) {
+		// A concurrent retry of this very ballot may have won the race.
+		if key != "" && replayVote(c, election.ID, user.ID, key) {
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
 	}
-	results := []Result{}
-
-	// Get candidate votes
-	err := db.DB.Table("candidates").
-		Select("candidates.id as candidate_id, candidates.name, candidates.image_url, COALESCE(count(votes.id), 0) as count").
-		Joins("left join votes on votes.candidate_id = candidates.id AND votes.status = ?", "approved").
-		Joins("left join users on users.id = votes.user_id AND users.verification_status = ?", "approved").
-		Group("candidates.id, candidates.name, candidates.image_url").
-		Scan(&results).Error
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil hasil"})
+	if errors.Is(err, errSessionInvalid) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Get Kotak Kosong votes (candidate_id = 0)
-	var kotakKosongCount int64
-	db.DB.Table("votes").
-		Joins("left join users on users.id = votes.user_id").
-		Where("votes.candidate_id = ? AND votes.status = ? AND users.verification_status = ?", 0, "approved", "approved").
-		Count(&kotakKosongCount)
-
-	// Add Kotak Kosong to results
-	if kotakKosongCount > 0 || len(results) > 0 {
-		kotakKosong := Result{
-			CandidateID: 0,
-			Name:        "Kotak Kosong",
-			ImageURL:    "/kotakkosong.png",
-			Count:       kotakKosongCount,
-		}
-		results = append(results, kotakKosong)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memberikan suara"})
+		return
 	}
 
-	// Count Rejected Votes (Suara Hangus)
-	var rejectedCount int64
-	db.DB.Table("votes").
-		Where("status = ?", "rejected").
-		Count(&rejectedCount)
-
-	if rejectedCount > 0 {
-		hangus := Result{
-			CandidateID: 999999, // Arbitrary ID for unique key
-			Name:        "Suara Hangus",
-			ImageURL:    "", // No image or specific image for rejected
-			Count:       rejectedCount,
+	go func() {
+		if err := email.SendVoteConfirmation(user.Email, user.Name, receipt); err != nil {
+			log.Printf("Failed to send vote confirmation to %s: %v", user.Email, err)
+		} else {
+			log.Printf("Vote confirmation email sent to %s", user.Email)
 		}
-		results = append(results, hangus)
-	}
+	}()
 
-	c.JSON(http.StatusOK, results)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", reply)
 }
 
 func GetSettings(c *gin.Context) {
@@ -727,15 +762,36 @@ func GetSettings(c *gin.Context) {
 	for _, s := range settings {
 		settingsMap[s.Key] = s.Value
 	}
+	// The countdown still reads startTime/endTime; they now come from the
+	// current election.
+	if election, err := currentElection(); err == nil {
+		if election.StartTime != nil {
+			settingsMap["startTime"] = election.StartTime.In(wibLocation).Format(electionTimeLayout)
+		}
+		if election.EndTime != nil {
+			settingsMap["endTime"] = election.EndTime.In(wibLocation).Format(electionTimeLayout)
+		}
+		settingsMap["phase"] = string(electionPhase(election, time.Now()))
+	}
 	c.JSON(http.StatusOK, settingsMap)
 }
 
+// electionSettingKeys are served by GetSettings from the current election,
+// so saving them here would have no effect.
+var electionSettingKeys = []string{"startTime", "endTime", "phase"}
+
 func SaveSettings(c *gin.Context) {
 	var req map[string]string
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	for _, k := range electionSettingKeys {
+		if _, ok := req[k]; ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": k + " belongs to the election; set the schedule with PUT /admin/elections/:id"})
+			return
+		}
+	}
 
 	for k, v := range req {
 		var setting models.Setting
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+)
+
+// irvRound is one counting round of an instant-runoff contest. Counts holds
+// the ballots each remaining option holds at the start of the round. When no
+// option has a majority of the continuing ballots the weakest is eliminated,
+// and Transfers shows where its ballots went next.
+type irvRound struct {
+	Round              int            `json:"round"`
+	Counts             map[uint]int64 `json:"counts"`
+	Exhausted          int64          `json:"exhausted"` // Ballots with no remaining option ranked
+	Elected            *uint          `json:"elected,omitempty"`
+	Eliminated         *uint          `json:"eliminated,omitempty"`
+	Transfers          map[uint]int64 `json:"transfers,omitempty"`
+	ExhaustedTransfers int64          `json:"exhaustedTransfers,omitempty"`
+}
+
+// rankedBallots returns the counted ballots of a contest as lists of option
+// IDs in order of preference.
+func rankedBallots(contestID uint) ([][]uint, error) {
+	var rows []struct {
+		BallotID    string
+		CandidateID uint
+	}
+	err := db.DB.Table("ballot_choices").
+		Select("ballot_choices.ballot_id, ballot_choices.candidate_id").
+		Joins("join ballots on ballots.id = ballot_choices.ballot_id AND ballots.decrypted = ? AND ballots.spoiled = ?", true, false).
+		Where("ballot_choices.contest_id = ?", contestID).
+		Order("ballot_choices.ballot_id, ballot_choices.rank").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var ballots [][]uint
+	last := ""
+	for _, row := range rows {
+		if row.BallotID != last || len(ballots) == 0 {
+			ballots = append(ballots, nil)
+			last = row.BallotID
+		}
+		ballots[len(ballots)-1] = append(ballots[len(ballots)-1], row.CandidateID)
+	}
+	return ballots, nil
+}
+
+// contestOptions lists the options of a contest that are in the count, in
+// ballot order, with Kotak Kosong (0) last when it is offered. Candidates who
+// withdrew are left out unless the election still counts their ballots, so a
+// ranked ballot passes to its next preference.
+func contestOptions(e *models.Election, contest *models.Contest) []uint {
+	var options []uint
+	for _, cand := range contest.Candidates {
+		if cand.WithdrawnAt != nil && e.WithdrawnVotes != withdrawnCount {
+			continue
+		}
+		options = append(options, cand.ID)
+	}
+	if contest.KotakKosong {
+		options = append(options, 0)
+	}
+	return options
+}
+
+// instantRunoff counts ranked ballots round by round until an option holds a
+// majority of the continuing ballots or only one option is left. A tie for
+// last place eliminates whichever of the tied options did worse in the
+// earliest round that separates them, and failing that the one listed last
+// on the ballot.
+func instantRunoff(options []uint, ballots [][]uint) []irvRound {
+	active := map[uint]bool{}
+	for _, id := range options {
+		active[id] = true
+	}
+	// top returns a ballot's highest ranked option still in the count.
+	top := func(ballot []uint) (uint, bool) {
+		for _, id := range ballot {
+			if active[id] {
+				return id, true
+			}
+		}
+		return 0, false
+	}
+
+	var rounds []irvRound
+	for len(active) > 0 {
+		round := irvRound{Round: len(rounds) + 1, Counts: map[uint]int64{}}
+		for id := range active {
+			round.Counts[id] = 0
+		}
+		for _, ballot := range ballots {
+			if id, ok := top(ballot); ok {
+				round.Counts[id]++
+			} else {
+				round.Exhausted++
+			}
+		}
+
+		continuing := int64(len(ballots)) - round.Exhausted
+		if continuing == 0 {
+			rounds = append(rounds, round)
+			break
+		}
+		for id, count := range round.Counts {
+			if count*2 > continuing || len(active) == 1 {
+				elected := id
+				round.Elected = &elected
+			}
+		}
+		if round.Elected != nil {
+			rounds = append(rounds, round)
+			break
+		}
+
+		loser := irvLoser(options, active, round.Counts, rounds)
+		round.Eliminated = &loser
+		round.Transfers = map[uint]int64{}
+		var moving [][]uint
+		for _, ballot := range ballots {
+			if id, ok := top(ballot); ok && id == loser {
+				moving = append(moving, ballot)
+			}
+		}
+		delete(active, loser)
+		for _, ballot := range moving {
+			if id, ok := top(ballot); ok {
+				round.Transfers[id]++
+			} else {
+				round.ExhaustedTransfers++
+			}
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds
+}
+
+// irvLoser picks the option to eliminate from this round's counts.
+func irvLoser(options []uint, active map[uint]bool, counts map[uint]int64, earlier []irvRound) uint {
+	var tied []uint
+	for _, id := range options {
+		if !active[id] {
+			continue
+		}
+		if len(tied) == 0 || counts[id] < counts[tied[0]] {
+			tied = []uint{id}
+		} else if counts[id] == counts[tied[0]] {
+			tied = append(tied, id)
+		}
+	}
+
+	for _, round := range earlier {
+		if len(tied) == 1 {
+			break
+		}
+		var fewest []uint
+		for _, id := range tied {
+			if len(fewest) == 0 || round.Counts[id] < round.Counts[fewest[0]] {
+				fewest = []uint{id}
+			} else if round.Counts[id] == round.Counts[fewest[0]] {
+				fewest = append(fewest, id)
+			}
+		}
+		tied = fewest
+	}
+	return tied[len(tied)-1]
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ptr(id uint) *uint { return &id }
+
+// repeat returns n copies of a ranked ballot.
+func repeat(n int, ballot ...uint) [][]uint {
+	ballots := make([][]uint, n)
+	for i := range ballots {
+		ballots[i] = ballot
+	}
+	return ballots
+}
+
+func ballotSet(groups ...[][]uint) [][]uint {
+	var ballots [][]uint
+	for _, g := range groups {
+		ballots = append(ballots, g...)
+	}
+	return ballots
+}
+
+func TestInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []uint
+		ballots [][]uint
+		want    []irvRound
+	}{
+		{
+			name:    "majority in the first round",
+			options: []uint{1, 2, 3},
+			ballots: ballotSet(repeat(3, 1), repeat(1, 2), repeat(1, 3)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 3, 2: 1, 3: 1}, Elected: ptr(1)},
+			},
+		},
+		{
+			name:    "transfers decide the winner",
+			options: []uint{1, 2, 3},
+			ballots: ballotSet(repeat(4, 1), repeat(3, 2, 1), repeat(2, 3, 2)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 4, 2: 3, 3: 2}, Eliminated: ptr(3), Transfers: map[uint]int64{2: 2}},
+				{Round: 2, Counts: map[uint]int64{1: 4, 2: 5}, Elected: ptr(2)},
+			},
+		},
+		{
+			name:    "exhausted ballots leave the majority",
+			options: []uint{1, 2, 3},
+			ballots: ballotSet(repeat(4, 1), repeat(3, 2), repeat(2, 3)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 4, 2: 3, 3: 2}, Eliminated: ptr(3), Transfers: map[uint]int64{}, ExhaustedTransfers: 2},
+				{Round: 2, Counts: map[uint]int64{1: 4, 2: 3}, Exhausted: 2, Elected: ptr(1)},
+			},
+		},
+		{
+			name:    "tie for last broken by an earlier round",
+			options: []uint{1, 2, 3, 4},
+			ballots: ballotSet(repeat(5, 1), repeat(3, 2), repeat(2, 3), repeat(1, 4, 3)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 5, 2: 3, 3: 2, 4: 1}, Eliminated: ptr(4), Transfers: map[uint]int64{3: 1}},
+				{Round: 2, Counts: map[uint]int64{1: 5, 2: 3, 3: 3}, Eliminated: ptr(3), Transfers: map[uint]int64{}, ExhaustedTransfers: 3},
+				{Round: 3, Counts: map[uint]int64{1: 5, 2: 3}, Exhausted: 3, Elected: ptr(1)},
+			},
+		},
+		{
+			name:    "unbroken tie eliminates the option listed last",
+			options: []uint{1, 2, 3},
+			ballots: ballotSet(repeat(2, 1), repeat(1, 2), repeat(1, 3)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 2, 2: 1, 3: 1}, Eliminated: ptr(3), Transfers: map[uint]int64{}, ExhaustedTransfers: 1},
+				{Round: 2, Counts: map[uint]int64{1: 2, 2: 1}, Exhausted: 1, Elected: ptr(1)},
+			},
+		},
+		{
+			name:    "ranks of options not in the count are skipped",
+			options: []uint{1, 2},
+			ballots: ballotSet(repeat(2, 9, 2), repeat(1, 1)),
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 1, 2: 2}, Elected: ptr(2)},
+			},
+		},
+		{
+			name:    "no ballots",
+			options: []uint{1, 2},
+			want: []irvRound{
+				{Round: 1, Counts: map[uint]int64{1: 0, 2: 0}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := instantRunoff(tt.options, tt.ballots); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("instantRunoff =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
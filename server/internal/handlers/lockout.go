@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Failures allowed before lockouts start, per account and per client IP. The
+// IP limit is higher because many students share campus NAT addresses.
+const (
+	accountFreeFailures = 5
+	ipFreeFailures      = 20
+	lockoutBase         = 30 * time.Second
+	lockoutMax          = time.Hour
+	// failureWindow resets a counter whose last failure is older than this.
+	failureWindow = 24 * time.Hour
+)
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockoutDuration doubles with every failure past the free allowance.
+func lockoutDuration(failures, free int) time.Duration {
+	if failures < free {
+		return 0
+	}
+	d := lockoutBase * time.Duration(math.Pow(2, float64(failures-free)))
+	if d > lockoutMax || d <= 0 {
+		return lockoutMax
+	}
+	return d
+}
+
+// checkLockout responds 429 and returns false if the account or the client
+// IP is currently locked out.
+func checkLockout(c *gin.Context, email string) bool {
+	return checkThrottle(c, []string{accountKey(email), ipKey(c.ClientIP())},
+		"Terlalu banyak percobaan login. Coba lagi dalam %d detik.")
+}
+
+// checkThrottle responds 429 with message, formatted with the seconds left,
+// and returns false if any of keys is locked.
+func checkThrottle(c *gin.Context, keys []string, message string) bool {
+	var throttles []models.LoginThrottle
+	db.DB.Where("key IN ? AND locked_until > ?", keys, time.Now()).
+		Find(&throttles)
+
+	var until time.Time
+	for _, t := range throttles {
+		if t.LockedUntil.After(until) {
+			until = *t.LockedUntil
+		}
+	}
+	if until.IsZero() {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	c.Header("Retry-After", fmt.Sprint(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      fmt.Sprintf(message, retryAfter),
+		"retryAfter": retryAfter,
+	})
+	return false
+}
+
+func recordAttempt(c *gin.Context, email, endpoint string, success bool) {
+	attempt := models.LoginAttempt{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Endpoint:  endpoint,
+		Success:   success,
+	}
+	if err := db.DB.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// recordLoginFailure logs the attempt and bumps the account and IP counters,
+// locking either one once it passes its allowance.
+func recordLoginFailure(c *gin.Context, email, endpoint string) {
+	recordAttempt(c, email, endpoint, false)
+	bumpThrottles(map[string]int{accountKey(email): accountFreeFailures, ipKey(c.ClientIP()): ipFreeFailures})
+}
+
+// bumpThrottles counts one more event against each key, locking any key
+// whose count passes the allowance it maps to.
+func bumpThrottles(keys map[string]int) {
+	now := time.Now()
+	for key, free := range keys {
+		// Single upsert so concurrent failures on any replica are all counted.
+		var failures int
+		err := db.DB.Raw(`
+			INSERT INTO login_throttles (key, failures, updated_at) VALUES (?, 1, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_throttles.updated_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+				updated_at = EXCLUDED.updated_at
+			RETURNING failures`, key, now, now.Add(-failureWindow)).Scan(&failures).Error
+		if err != nil {
+			log.Printf("Failed to update login throttle %s: %v", key, err)
+			continue
+		}
+
+		if d := lockoutDuration(failures, free); d > 0 {
+			db.DB.Model(&models.LoginThrottle{}).Where("key = ?", key).Update("locked_until", now.Add(d))
+			log.Printf("Login lockout for %s after %d failures (%s)", key, failures, d)
+		}
+	}
+}
+
+// Emails sent on request, such as password resets and confirmation links,
+// are throttled like logins but counted per purpose, so a burst of reset
+// requests never locks anyone out of logging in. Every request counts,
+// whether or not the address is registered, so the limit says nothing
+// about which addresses exist.
+const (
+	accountFreeEmails = 3
+	ipFreeEmails      = 10
+)
+
+func emailThrottleKeys(c *gin.Context, purpose, email string) map[string]int {
+	return map[string]int{
+		purpose + ":" + accountKey(email):   accountFreeEmails,
+		purpose + ":" + ipKey(c.ClientIP()): ipFreeEmails,
+	}
+}
+
+// throttleEmailRequest counts a request to send purpose's email to the given
+// address. It responds 429 and returns false if the address or the client IP
+// has asked too often.
+func throttleEmailRequest(c *gin.Context, purpose, email string) bool {
+	keys := emailThrottleKeys(c, purpose, email)
+	list := make([]string, 0, len(keys))
+	for key := range keys {
+		list = append(list, key)
+	}
+	if !checkThrottle(c, list, "Terlalu banyak permintaan. Coba lagi dalam %d detik.") {
+		return false
+	}
+	bumpThrottles(keys)
+	return true
+}
+
+// recordLoginSuccess logs the attempt and clears the account counter. The IP
+// counter is left alone so one valid account cannot reset guessing on others.
+func recordLoginSuccess(c *gin.Context, email, endpoint string) {
+	recordAttempt(c, email, endpoint, true)
+	db.DB.Where("key = ?", accountKey(email)).Delete(&models.LoginThrottle{})
+}
+
+// GetLockouts lists counters with recent failures, locked ones first.
+func GetLockouts(c *gin.Context) {
+	throttles := []models.LoginThrottle{}
+	if err := db.DB.Where("failures > 0 AND updated_at > ?", time.Now().Add(-failureWindow)).
+		Order("locked_until DESC NULLS LAST, updated_at DESC").
+		Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+	c.JSON(http.StatusOK, throttles)
+}
+
+// GetLoginAttempts returns the most recent login attempts, optionally filtered by email or IP.
+func GetLoginAttempts(c *gin.Context) {
+	query := db.DB.Order("created_at DESC").Limit(200)
+	if email := c.Query("email"); email != "" {
+		query = query.Where("email = ?", strings.ToLower(email))
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+
+	attempts := []models.LoginAttempt{}
+	if err := query.Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
+
+func ClearLockout(c *gin.Context) {
+	var throttle models.LoginThrottle
+	if err := db.DB.First(&throttle, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lockout not found"})
+		return
+	}
+	if err := db.DB.Delete(&throttle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+	log.Printf("Admin %d cleared login lockout %s", currentUser(c).ID, throttle.Key)
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
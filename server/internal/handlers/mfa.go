@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "JobHMS Voting"
+)
+
+// mfaEnrollmentPaths stay reachable for admins who still have to enroll.
+var mfaEnrollmentPaths = map[string]bool{
+	"/admin/mfa/enroll": true,
+	"/admin/mfa/verify": true,
+	ChangePasswordPath:  true,
+	LogoutPath:          true,
+}
+
+// adminMFARequired reports whether admins must have TOTP enabled. Enrollment
+// is optional until ADMIN_MFA_REQUIRED_FROM (a YYYY-MM-DD date in WIB, or
+// "now"), and mandatory from then on.
+func adminMFARequired() bool {
+	v := os.Getenv("ADMIN_MFA_REQUIRED_FROM")
+	if v == "" {
+		return false
+	}
+	if v == "now" {
+		return true
+	}
+	from, err := time.ParseInLocation("2006-01-02", v, wibLocation)
+	if err != nil {
+		log.Printf("Invalid ADMIN_MFA_REQUIRED_FROM %q, enforcing MFA", v)
+		return true
+	}
+	return !time.Now().Before(from)
+}
+
+// checkTOTP validates a code for the user and records its time step so the
+// same code cannot be replayed.
+func checkTOTP(user *models.User, code string) bool {
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+	res := db.DB.Model(&models.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", user.ID, step).
+		Update("totp_last_step", step)
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// useRecoveryCode consumes one of the user's unused recovery codes.
+func useRecoveryCode(user *models.User, code string) bool {
+	res := db.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, auth.HashToken(auth.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns the plaintext
+// codes, which are shown exactly once.
+func newRecoveryCodes(tx *gorm.DB, user *models.User) ([]string, error) {
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := auth.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rc := models.RecoveryCode{UserID: user.ID, CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code))}
+		if err := tx.Create(&rc).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// AdminLoginMFA is the second login step for admins with TOTP enabled.
+func AdminLoginMFA(c *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfaToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	claims, err := auth.Parse(req.MFAToken)
+	if errors.Is(err, auth.ErrExpiredToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge expired, please log in again"})
+		return
+	}
+	if err != nil || claims.Purpose != auth.PurposeMFA {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid login challenge"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, claims.UserID).Error; err != nil ||
+		!user.TOTPEnabled || user.Disabled || user.Role != claims.Role || user.VerificationStatus != "approved" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid login challenge"})
+		return
+	}
+
+	if !checkLockout(c, user.Email) {
+		return
+	}
+
+	verified := false
+	if req.Code != "" {
+		verified = checkTOTP(&user, req.Code)
+	} else if req.RecoveryCode != "" {
+		verified = useRecoveryCode(&user, req.RecoveryCode)
+		if verified {
+			log.Printf("Admin %d (%s) logged in with a recovery code", user.ID, user.Email)
+		}
+	}
+	if !verified {
+		recordLoginFailure(c, user.Email, "admin_mfa")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+	recordLoginSuccess(c, user.Email, "admin_mfa")
+
+	completeAdminLogin(c, &user)
+}
+
+// EnrollMFA starts TOTP enrollment by generating a fresh secret. The secret
+// only takes effect once VerifyMFAEnrollment sees a valid code.
+func EnrollMFA(c *gin.Context) {
+	user := currentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+	if err := db.DB.Model(user).Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":          secret,
+		"provisioningUri": auth.TOTPProvisioningURI(secret, user.Email, totpIssuer),
+	})
+}
+
+// VerifyMFAEnrollment enables TOTP after the admin proves their authenticator
+// works, and returns the one-time recovery codes.
+func VerifyMFAEnrollment(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user := currentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+		return
+	}
+	if !checkTOTP(user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = newRecoveryCodes(tx, user)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	log.Printf("Admin %d (%s) enabled two-factor authentication", user.ID, user.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recoveryCodes": codes})
+}
+
+// RegenerateRecoveryCodes invalidates all old recovery codes and issues new ones.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user := currentUser(c)
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !checkTOTP(user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	var codes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = newRecoveryCodes(tx, user)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// ResetAdminMFA lets a super-admin clear another admin's second factor after
+// a lost device. The admin has to enroll again on next login.
+func ResetAdminMFA(c *gin.Context) {
+	account, ok := findStaffAccount(c)
+	if !ok {
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", account.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		err := tx.Model(account).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, account.ID, revokeMFAReset)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	log.Printf("Admin %d reset two-factor authentication for admin %d (%s)", currentUser(c).ID, account.ID, account.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ctxUserKey = "authUser"
+
+// RequireAuth validates the bearer session token and binds the caller to the
+// request. When roles are given, the caller must have one of them.
+func RequireAuth(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || token == header {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing session token"})
+			return
+		}
+
+		claims, err := auth.Parse(token)
+		if errors.Is(err, auth.ErrExpiredToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+			return
+		}
+		if err != nil || claims.Purpose != auth.PurposeSession || claims.SessionID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
+			return
+		}
+
+		var session models.Session
+		if err := db.DB.Where("id = ?", claims.SessionID).First(&session).Error; err != nil || session.UserID != claims.UserID {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
+			return
+		}
+		if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session no longer valid"})
+			return
+		}
+
+		// Load the user so role changes take effect immediately.
+		var user models.User
+		if err := db.DB.First(&user, claims.UserID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
+			return
+		}
+		if user.Role != claims.Role || user.Disabled {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session no longer valid"})
+			return
+		}
+
+		if user.MustChangePassword && c.FullPath() != ChangePasswordPath && c.FullPath() != LogoutPath {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Password change required", "mustChangePassword": true})
+			return
+		}
+
+		if models.IsStaffRole(user.Role) && !user.TOTPEnabled && adminMFARequired() && !mfaEnrollmentPaths[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be set up", "mfaEnrollmentRequired": true})
+			return
+		}
+
+		if len(roles) > 0 && !hasRole(user.Role, roles) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+
+		touchSession(&session, c)
+		c.Set(ctxUserKey, &user)
+		c.Set(ctxSessionKey, &session)
+		c.Next()
+	}
+}
+
+// currentUser returns the authenticated user bound by RequireAuth.
+func currentUser(c *gin.Context) *models.User {
+	if v, ok := c.Get(ctxUserKey); ok {
+		return v.(*models.User)
+	}
+	return nil
+}
+
+func hasRole(role string, roles []string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// nimRules are the checks an election makes on the NIM of a registration.
+type nimRules struct {
+	Prefixes       []string `json:"prefixes"`       // Empty = any
+	Length         int      `json:"length"`         // Exact number of characters; 0 = any
+	Pattern        string   `json:"pattern"`        // Regular expression for the whole NIM; empty = digits only
+	CohortPosition int      `json:"cohortPosition"` // 1-based position of the two-digit cohort year; 0 = none
+	CohortMin      int      `json:"cohortMin"`      // Earliest cohort year admitted; 0 = no limit
+	CohortMax      int      `json:"cohortMax"`      // Latest cohort year admitted; 0 = no limit
+}
+
+func electionNIMRules(e *models.Election) nimRules {
+	return nimRules{
+		Prefixes:       nimPrefixes(e.NIMPrefixes),
+		Length:         e.NIMLength,
+		Pattern:        e.NIMPattern,
+		CohortPosition: e.CohortPosition,
+		CohortMin:      e.CohortMin,
+		CohortMax:      e.CohortMax,
+	}
+}
+
+// validate checks the rules themselves, for the election form.
+func (r nimRules) validate() error {
+	if r.Length < 0 || r.Length > 32 {
+		return errors.New("NIM length must be between 0 and 32")
+	}
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return errors.New("Invalid NIM pattern: " + err.Error())
+		}
+	}
+	if r.CohortPosition < 0 || r.Length > 0 && r.CohortPosition+1 > r.Length {
+		return errors.New("The cohort year must lie within the NIM")
+	}
+	if r.CohortMin != 0 && r.CohortMax != 0 && r.CohortMin > r.CohortMax {
+		return errors.New("The earliest cohort must not come after the latest")
+	}
+	return nil
+}
+
+// cohort reads the cohort year out of a NIM, e.g. 2022 from 15022045 with
+// the year at position 4.
+func (r nimRules) cohort(nim string) (int, bool) {
+	if r.CohortPosition == 0 || len(nim) < r.CohortPosition+1 {
+		return 0, false
+	}
+	yy := nim[r.CohortPosition-1 : r.CohortPosition+1]
+	if yy[0] < '0' || yy[0] > '9' || yy[1] < '0' || yy[1] > '9' {
+		return 0, false
+	}
+	return 2000 + int(yy[0]-'0')*10 + int(yy[1]-'0'), true
+}
+
+// check returns why a NIM breaks the rules, in Indonesian for the voter, or
+// "" if it passes.
+func (r nimRules) check(nim string) string {
+	if nim == "" {
+		return "NIM wajib diisi"
+	}
+	if r.Pattern != "" {
+		if ok, _ := regexp.MatchString("^(?:"+r.Pattern+")$", nim); !ok {
+			return "Format NIM tidak sesuai ketentuan pemilihan ini"
+		}
+	} else if strings.ContainsFunc(nim, func(c rune) bool { return c < '0' || c > '9' }) {
+		return "NIM hanya boleh berisi angka"
+	}
+	if r.Length > 0 && len(nim) != r.Length {
+		return fmt.Sprintf("NIM harus terdiri dari %d karakter", r.Length)
+	}
+	if len(r.Prefixes) > 0 {
+		prefixed := false
+		for _, p := range r.Prefixes {
+			prefixed = prefixed || strings.HasPrefix(nim, p)
+		}
+		if !prefixed {
+			return "NIM harus diawali dengan " + strings.Join(r.Prefixes, " atau ")
+		}
+	}
+	if r.CohortPosition > 0 {
+		year, ok := r.cohort(nim)
+		if !ok {
+			return "Angkatan tidak dapat dibaca dari NIM"
+		}
+		if r.CohortMin != 0 && year < r.CohortMin || r.CohortMax != 0 && year > r.CohortMax {
+			return fmt.Sprintf("Angkatan %d tidak termasuk dalam pemilihan ini", year)
+		}
+	}
+	return ""
+}
+
+// nimRuleError applies an election's NIM rules.
+func nimRuleError(e *models.Election, nim string) string {
+	return electionNIMRules(e).check(nim)
+}
+
+// nimAcceptedAnywhere returns "" if some election that is not archived
+// accepts the NIM, and otherwise why the first one refuses it.
+func nimAcceptedAnywhere(nim string) string {
+	var elections []models.Election
+	db.DB.Where("archived = ?", false).Order("start_time, id").Find(&elections)
+	msg := ""
+	for i := range elections {
+		err := nimRuleError(&elections[i], nim)
+		if err == "" {
+			return ""
+		}
+		if msg == "" {
+			msg = err
+		}
+	}
+	return msg
+}
+
+// GetPublicConfig tells the registration form which election it registers
+// for and which NIMs that election accepts, so the form can check a NIM the
+// way Register will. Without ?electionId= it describes the election open for
+// registration, like Register does.
+func GetPublicConfig(c *gin.Context) {
+	var election *models.Election
+	if c.Query("electionId") != "" {
+		if election = resolveElection(c); election == nil {
+			return
+		}
+	} else {
+		var elections []models.Election
+		db.DB.Order("start_time, id").Find(&elections)
+		now := time.Now()
+		for i := range elections {
+			if electionPhase(&elections[i], now) == PhaseRegistration {
+				election = &elections[i]
+				break
+			}
+		}
+		if election == nil {
+			if e, err := currentElection(); err == nil {
+				election = e
+			}
+		}
+	}
+	if election == nil || electionPhase(election, time.Now()) == PhaseDraft {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Belum ada pemilihan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"electionId": election.ID,
+		"name":       election.Name,
+		"phase":      electionPhase(election, time.Now()),
+		"nim":        electionNIMRules(election),
+	})
+}
+
+// MigrateNIMRules carries the NIM format earlier builds hard-coded, eight
+// digits starting with 150, over to the elections that already exist.
+func MigrateNIMRules() error {
+	return db.RunMigration("per_election_nim_rules", func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Election{}).Where("nim_prefixes IS NULL OR nim_prefixes = ''").Update("nim_prefixes", "150").Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Election{}).Where("nim_length IS NULL OR nim_length = 0").Update("nim_length", 8).Error
+	})
+}
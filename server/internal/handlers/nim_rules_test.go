@@ -0,0 +1,68 @@
+package handlers
+
+import "testing"
+
+func TestNIMRulesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   nimRules
+		wantErr bool
+	}{
+		{"no rules", nimRules{}, false},
+		{"typical rules", nimRules{Prefixes: []string{"150"}, Length: 8, CohortPosition: 4, CohortMin: 2020, CohortMax: 2024}, false},
+		{"negative length", nimRules{Length: -1}, true},
+		{"length too long", nimRules{Length: 33}, true},
+		{"longest length", nimRules{Length: 32}, false},
+		{"invalid pattern", nimRules{Pattern: "(150"}, true},
+		{"negative cohort position", nimRules{CohortPosition: -1}, true},
+		{"cohort in the last two characters", nimRules{Length: 8, CohortPosition: 7}, false},
+		{"cohort runs past the end", nimRules{Length: 8, CohortPosition: 8}, true},
+		{"cohort position without a length", nimRules{CohortPosition: 20}, false},
+		{"earliest cohort after the latest", nimRules{CohortPosition: 4, CohortMin: 2024, CohortMax: 2020}, true},
+		{"only an earliest cohort", nimRules{CohortPosition: 4, CohortMin: 2024}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNIMRulesCheck(t *testing.T) {
+	cohort := nimRules{Length: 8, CohortPosition: 4, CohortMin: 2020, CohortMax: 2023}
+	tests := []struct {
+		name  string
+		rules nimRules
+		nim   string
+		want  string
+	}{
+		{"any digits", nimRules{}, "123", ""},
+		{"empty", nimRules{}, "", "NIM wajib diisi"},
+		{"digits only by default", nimRules{}, "1502204A", "NIM hanya boleh berisi angka"},
+		{"pattern allows letters", nimRules{Pattern: `[A-Z]\d+`}, "A123", ""},
+		{"pattern must match the whole NIM", nimRules{Pattern: `\d{3}`}, "1234", "Format NIM tidak sesuai ketentuan pemilihan ini"},
+		{"pattern is anchored at the start", nimRules{Pattern: `150\d+`}, "9150123", "Format NIM tidak sesuai ketentuan pemilihan ini"},
+		{"alternatives are all anchored", nimRules{Pattern: `150|151`}, "1500", "Format NIM tidak sesuai ketentuan pemilihan ini"},
+		{"length", nimRules{Length: 8}, "1502204", "NIM harus terdiri dari 8 karakter"},
+		{"prefix", nimRules{Prefixes: []string{"150", "151"}}, "15122045", ""},
+		{"wrong prefix", nimRules{Prefixes: []string{"150", "151"}}, "16022045", "NIM harus diawali dengan 150 atau 151"},
+		{"cohort in range", cohort, "15022045", ""},
+		{"cohort on the earliest year", cohort, "15020045", ""},
+		{"cohort on the latest year", cohort, "15023045", ""},
+		{"cohort too early", cohort, "15019045", "Angkatan 2019 tidak termasuk dalam pemilihan ini"},
+		{"cohort too late", cohort, "15024045", "Angkatan 2024 tidak termasuk dalam pemilihan ini"},
+		{"cohort in the last two characters", nimRules{Length: 8, CohortPosition: 7, CohortMin: 2022}, "15000022", ""},
+		{"cohort in the last two characters too early", nimRules{Length: 8, CohortPosition: 7, CohortMin: 2022}, "15000021", "Angkatan 2021 tidak termasuk dalam pemilihan ini"},
+		{"NIM too short for the cohort", nimRules{CohortPosition: 4}, "150", "Angkatan tidak dapat dibaca dari NIM"},
+		{"cohort is not digits", nimRules{Pattern: `[0-9A-Z]+`, CohortPosition: 4}, "150AB045", "Angkatan tidak dapat dibaca dari NIM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.check(tt.nim); got != tt.want {
+				t.Errorf("check(%q) = %q, want %q", tt.nim, got, tt.want)
+			}
+		})
+	}
+}
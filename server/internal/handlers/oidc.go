@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+	"voting-backend/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+// oidcEnabled reports whether campus SSO is configured (OIDC_ISSUER).
+func oidcEnabled() bool {
+	return os.Getenv("OIDC_ISSUER") != ""
+}
+
+// getOIDCProvider discovers the identity provider on first use, and again
+// after a failed attempt, so the server starts even while the IdP is down.
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	p, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = p
+	return p, nil
+}
+
+// oidcClaimName returns the ID token claim configured by env, or def.
+func oidcClaimName(env, def string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return def
+}
+
+// oidcTrustIdentity reports whether SSO sign-in counts as identity
+// verification, so linked voters skip the KTM review (OIDC_TRUST_IDENTITY).
+func oidcTrustIdentity() bool {
+	v, _ := strconv.ParseBool(os.Getenv("OIDC_TRUST_IDENTITY"))
+	return v
+}
+
+// claimString reads a string claim; numeric claims (NIMs often are) are
+// formatted without exponent.
+func claimString(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// GetOIDCConfig tells the client whether to offer the SSO button.
+func GetOIDCConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": oidcEnabled(),
+		"name":    oidcClaimName("OIDC_PROVIDER_NAME", "SSO Kampus"),
+	})
+}
+
+// OIDCLogin starts the authorization-code flow. State and nonce are kept in a
+// short-lived HttpOnly cookie and checked on the callback.
+func OIDCLogin(c *gin.Context) {
+	if !oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SSO is not configured"})
+		return
+	}
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Layanan SSO kampus tidak dapat dihubungi"})
+		return
+	}
+
+	state, err1 := auth.RandomToken(16)
+	nonce, err2 := auth.RandomToken(16)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start SSO login"})
+		return
+	}
+
+	secure := strings.HasPrefix(os.Getenv("OIDC_REDIRECT_URL"), "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, state+"."+nonce, int(oidcFlowTTL.Seconds()), "/auth/oidc", "", secure, true)
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce))
+}
+
+// OIDCCallback finishes the flow, links or creates the voter and hands the
+// session token to the client in the URL fragment.
+func OIDCCallback(c *gin.Context) {
+	fail := func(msg string) {
+		c.Redirect(http.StatusFound, clientURL()+"/login?ssoError="+url.QueryEscape(msg))
+	}
+
+	flow, _ := c.Cookie(oidcFlowCookie)
+	c.SetCookie(oidcFlowCookie, "", -1, "/auth/oidc", "", false, true)
+
+	if e := c.Query("error"); e != "" {
+		log.Printf("OIDC provider returned error %q: %s", e, c.Query("error_description"))
+		fail("Login SSO dibatalkan atau ditolak")
+		return
+	}
+	state, nonce, found := strings.Cut(flow, ".")
+	if !found || state == "" || c.Query("state") != state {
+		fail("Sesi login SSO tidak valid, silakan coba lagi")
+		return
+	}
+
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		fail("Layanan SSO kampus tidak dapat dihubungi")
+		return
+	}
+	claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		fail("Login SSO gagal, silakan coba lagi")
+		return
+	}
+
+	user, err := linkOIDCUser(claims)
+	if err != nil {
+		recordAttempt(c, claimString(claims, oidcClaimName("OIDC_EMAIL_CLAIM", "email")), "oidc", false)
+		var se ssoError
+		if errors.As(err, &se) {
+			fail(string(se))
+		} else {
+			log.Printf("OIDC account linking failed: %v", err)
+			fail("Gagal menautkan akun SSO")
+		}
+		return
+	}
+	recordAttempt(c, user.Email, "oidc", true)
+
+	if user.VerificationStatus == "approved" {
+		if msg := loginWindowError(); msg != "" {
+			fail(msg)
+			return
+		}
+	}
+	if msg := rollLoginError(user.NIM); msg != "" {
+		fail(msg)
+		return
+	}
+
+	token, expiresAt, err := createSession(c, user)
+	if err != nil {
+		fail("Gagal membuat sesi")
+		return
+	}
+	fragment := url.Values{
+		"sessionToken": {token},
+		"expiresAt":    {expiresAt.Format(time.RFC3339)},
+	}
+	c.Redirect(http.StatusFound, clientURL()+"/sso-callback#"+fragment.Encode())
+}
+
+// ssoError is a linking failure explained to the voter as is.
+type ssoError string
+
+func (e ssoError) Error() string { return string(e) }
+
+// linkOIDCUser finds the voter for the verified ID token claims: first by SSO
+// subject, then by NIM (linking the existing registration once it is
+// approved), and otherwise creates a new voter.
+func linkOIDCUser(claims map[string]interface{}) (*models.User, error) {
+	subject := claimString(claims, "sub")
+	nim := claimString(claims, oidcClaimName("OIDC_NIM_CLAIM", "nim"))
+	name := claimString(claims, oidcClaimName("OIDC_NAME_CLAIM", "name"))
+	userEmail := claimString(claims, oidcClaimName("OIDC_EMAIL_CLAIM", "email"))
+
+	var user models.User
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("oidc_subject = ?", subject).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if msg := nimAcceptedAnywhere(nim); msg != "" {
+				log.Printf("OIDC subject %s has unusable NIM claim %q", subject, nim)
+				return ssoError("Akun SSO ini tidak memiliki NIM mahasiswa yang valid")
+			}
+
+			err = tx.Where("nim = ?", nim).First(&user).Error
+			switch {
+			case err == nil:
+				if user.OIDCSubject != nil {
+					return ssoError("NIM ini sudah ditautkan ke akun SSO lain")
+				}
+				// Anyone can register any NIM, so only a registration the
+				// panitia have approved is taken to be this student's. Linking
+				// one still under review would let its registrant in through
+				// this student's SSO login, or have SSO trust approve it.
+				if user.VerificationStatus != "approved" {
+					log.Printf("OIDC subject %s not linked to user %d (NIM %s): registration is %s", subject, user.ID, nim, user.VerificationStatus)
+					return ssoError("NIM ini sudah didaftarkan dan belum diverifikasi. Hubungi panitia.")
+				}
+				user.OIDCSubject = &subject
+				log.Printf("Linked user %d (NIM %s) to SSO subject %s", user.ID, nim, subject)
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if userEmail == "" {
+					return ssoError("Akun SSO ini tidak memiliki alamat email")
+				}
+				var taken int64
+				tx.Model(&models.User{}).Where("email = ?", userEmail).Count(&taken)
+				if taken > 0 {
+					return ssoError("Email sudah terdaftar dengan NIM lain")
+				}
+				user = models.User{
+					Name:               name,
+					NIM:                nim,
+					Email:              userEmail,
+					OIDCSubject:        &subject,
+					Role:               models.RoleVoter,
+					VerificationStatus: "none",
+				}
+			default:
+				return err
+			}
+		}
+
+		if user.Role != models.RoleVoter {
+			return ssoError("Akun admin tidak dapat login melalui SSO")
+		}
+		if user.Disabled {
+			return ssoError("Akun dinonaktifkan")
+		}
+
+		// A rejection by the admins stands even for trusted SSO identities.
+		if oidcTrustIdentity() && user.VerificationStatus != "approved" && user.VerificationStatus != "rejected" {
+			user.VerificationStatus = "approved"
+			log.Printf("User %d pre-verified through SSO", user.ID)
+		}
+		return tx.Save(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
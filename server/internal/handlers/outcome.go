@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"sort"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+)
+
+const (
+	majoritySimple   = "simple"
+	majorityAbsolute = "absolute"
+
+	kotakKosongPerContest = "per_contest"
+	kotakKosongAlways     = "always"
+	kotakKosongNever      = "never"
+	kotakKosongSingle     = "single_candidate"
+
+	kotakKosongVoid     = "void"
+	kotakKosongRunnerUp = "runner_up"
+
+	outcomeWinner = "winner"
+	outcomeRunoff = "runoff"
+	outcomeVoid   = "void"
+
+	withdrawnVoid  = "void"
+	withdrawnCount = "count"
+)
+
+// sameRules reports whether two versions of an election decide the outcome
+// the same way.
+func sameRules(a, b *models.Election) bool {
+	return a.MinTurnout == b.MinTurnout && a.MajorityRule == b.MajorityRule &&
+		a.KotakKosongPolicy == b.KotakKosongPolicy && a.KotakKosongWins == b.KotakKosongWins &&
+		a.WithdrawnVotes == b.WithdrawnVotes
+}
+
+// offersKotakKosong applies the election's Kotak Kosong policy to a contest.
+// A referendum never offers it.
+func offersKotakKosong(e *models.Election, contest *models.Contest) bool {
+	if contest.Kind == contestReferendum {
+		return false
+	}
+	switch e.KotakKosongPolicy {
+	case kotakKosongAlways:
+		return true
+	case kotakKosongNever:
+		return false
+	case kotakKosongSingle:
+		// Withdrawn candidates do not count, so a contest left with one gets
+		// Kotak Kosong. It stays if the last one withdraws too, so ballots
+		// already cast for it still count.
+		active := 0
+		for _, cand := range contest.Candidates {
+			if cand.WithdrawnAt == nil {
+				active++
+			}
+		}
+		return active <= 1 && len(contest.Candidates) > 0
+	}
+	return contest.KotakKosong
+}
+
+// turnout is how many eligible voters took part in an election.
+type turnout struct {
+	Eligible   int64   `json:"eligible"`
+	Voted      int64   `json:"voted"`
+	Percent    float64 `json:"percent"`
+	MinTurnout int     `json:"minTurnout"`
+	QuorumMet  bool    `json:"quorumMet"`
+}
+
+// electionTurnout counts the eligible voters and those who voted. With a
+// voter roll the electorate is everyone on it, registered or not.
+func electionTurnout(e *models.Election) turnout {
+	t := turnout{MinTurnout: e.MinTurnout}
+	if electionHasRoll(e.ID) {
+		db.DB.Model(&models.RollEntry{}).Where("election_id = ?", e.ID).
+			Scopes(nimPrefixScope(e.NIMPrefixes, "nim")).
+			Count(&t.Eligible)
+	} else {
+		db.DB.Model(&models.User{}).
+			Where("role = ? AND verification_status = ?", models.RoleVoter, "approved").
+			Scopes(nimPrefixScope(e.NIMPrefixes, "nim")).
+			Count(&t.Eligible)
+	}
+	db.DB.Model(&models.Participation{}).Where("election_id = ?", e.ID).Count(&t.Voted)
+
+	if t.Eligible > 0 {
+		t.Percent = float64(t.Voted) * 100 / float64(t.Eligible)
+	}
+	t.QuorumMet = e.MinTurnout == 0 || t.Voted*100 >= int64(e.MinTurnout)*t.Eligible
+	return t
+}
+
+// contestOutcome is who won a contest under the election's rules. Runoff
+// lists the options that go to a second round.
+type contestOutcome struct {
+	Status  string `json:"status"` // 'winner', 'runoff' or 'void'
+	Winners []uint `json:"winners,omitempty"`
+	Runoff  []uint `json:"runoff,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// decideContest applies the election's rules to a contest's counts. Ranked
+// contests are decided by their instant-runoff rounds; the others elect the
+// MaxChoices options with the most votes.
+func decideContest(e *models.Election, contest *models.Contest, counts map[uint]int64, rounds []irvRound, ballots [][]uint, quorumMet bool) contestOutcome {
+	if !quorumMet {
+		return contestOutcome{Status: outcomeVoid, Reason: "Turnout is below the quorum"}
+	}
+	if contest.Method == methodIRV {
+		return decideRunoff(e, contest, rounds, ballots)
+	}
+
+	options := contestOptions(e, contest)
+	var valid int64
+	for _, id := range options {
+		valid += counts[id]
+	}
+	if valid == 0 {
+		return contestOutcome{Status: outcomeVoid, Reason: "No valid votes"}
+	}
+
+	// Most votes first; ties keep ballot order.
+	ranked := append([]uint(nil), options...)
+	sort.SliceStable(ranked, func(i, j int) bool { return counts[ranked[i]] > counts[ranked[j]] })
+
+	if ranked[0] == 0 && (len(ranked) == 1 || counts[0] > counts[ranked[1]]) {
+		if e.KotakKosongWins != kotakKosongRunnerUp {
+			return contestOutcome{Status: outcomeVoid, Reason: "Kotak Kosong won"}
+		}
+	}
+	// Kotak Kosong holds no seat.
+	var seated []uint
+	for _, id := range ranked {
+		if id != 0 {
+			seated = append(seated, id)
+		}
+	}
+	if len(seated) == 0 {
+		return contestOutcome{Status: outcomeVoid, Reason: "No candidates"}
+	}
+
+	seats := contest.MaxChoices
+	if seats < 1 || contest.Kind == contestReferendum {
+		seats = 1
+	}
+	winners := seated
+	if seats < len(seated) {
+		// Options tied with the last seat share it only through a runoff.
+		cutoff := counts[seated[seats-1]]
+		var ahead, tied []uint
+		for _, id := range seated {
+			switch {
+			case counts[id] > cutoff:
+				ahead = append(ahead, id)
+			case counts[id] == cutoff:
+				tied = append(tied, id)
+			}
+		}
+		if len(ahead)+len(tied) > seats {
+			if contest.Kind == contestReferendum {
+				return contestOutcome{Status: outcomeVoid, Reason: "The referendum is tied"}
+			}
+			return contestOutcome{Status: outcomeRunoff, Winners: ahead, Runoff: tied, Reason: "Tie for the last seat"}
+		}
+		winners = append(ahead, tied...)
+	}
+
+	// An absolute majority is only meaningful for a single seat.
+	if seats == 1 && e.MajorityRule == majorityAbsolute && counts[winners[0]]*2 <= valid {
+		if contest.Kind == contestReferendum || len(seated) == 1 {
+			return contestOutcome{Status: outcomeVoid, Reason: "No option has an absolute majority"}
+		}
+		runoff := []uint{seated[0]}
+		for _, id := range seated[1:] {
+			if counts[id] == counts[seated[1]] {
+				runoff = append(runoff, id)
+			}
+		}
+		return contestOutcome{Status: outcomeRunoff, Runoff: runoff, Reason: "No candidate has an absolute majority"}
+	}
+	return contestOutcome{Status: outcomeWinner, Winners: winners}
+}
+
+// decideRunoff reads the outcome of a ranked contest from its rounds. When
+// Kotak Kosong is elected and the runner-up takes the seat, the count is
+// repeated without it.
+func decideRunoff(e *models.Election, contest *models.Contest, rounds []irvRound, ballots [][]uint) contestOutcome {
+	var elected *uint
+	if len(rounds) > 0 {
+		elected = rounds[len(rounds)-1].Elected
+	}
+	if elected == nil {
+		return contestOutcome{Status: outcomeVoid, Reason: "No valid votes"}
+	}
+	if *elected != 0 {
+		return contestOutcome{Status: outcomeWinner, Winners: []uint{*elected}}
+	}
+	if e.KotakKosongWins != kotakKosongRunnerUp {
+		return contestOutcome{Status: outcomeVoid, Reason: "Kotak Kosong won"}
+	}
+
+	var candidates []uint
+	for _, id := range contestOptions(e, contest) {
+		if id != 0 {
+			candidates = append(candidates, id)
+		}
+	}
+	recount := instantRunoff(candidates, ballots)
+	if len(recount) == 0 || recount[len(recount)-1].Elected == nil {
+		return contestOutcome{Status: outcomeVoid, Reason: "Kotak Kosong won"}
+	}
+	return contestOutcome{Status: outcomeWinner, Winners: []uint{*recount[len(recount)-1].Elected}}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"voting-backend/internal/models"
+)
+
+// testContest returns a contest whose candidates have the given IDs.
+func testContest(kind, method string, seats int, kotakKosong bool, ids ...uint) *models.Contest {
+	contest := &models.Contest{Kind: kind, Method: method, MaxChoices: seats, KotakKosong: kotakKosong}
+	for _, id := range ids {
+		contest.Candidates = append(contest.Candidates, models.Candidate{ID: id})
+	}
+	return contest
+}
+
+func TestDecideContest(t *testing.T) {
+	withdrawn := time.Now()
+	pair := testContest(contestCandidates, methodPlurality, 1, true, 1, 2)
+	single := testContest(contestCandidates, methodPlurality, 1, true, 1)
+	senate := testContest(contestCandidates, methodPlurality, 2, false, 1, 2, 3)
+	referendum := testContest(contestReferendum, methodPlurality, 1, false, 1, 2)
+	dropout := testContest(contestCandidates, methodPlurality, 1, false, 1, 2)
+	dropout.Candidates[0].WithdrawnAt = &withdrawn
+
+	tests := []struct {
+		name     string
+		election models.Election
+		contest  *models.Contest
+		counts   map[uint]int64
+		quorum   bool
+		want     contestOutcome
+	}{
+		{
+			name:    "below quorum",
+			contest: pair, counts: map[uint]int64{1: 5}, quorum: false,
+			want: contestOutcome{Status: outcomeVoid, Reason: "Turnout is below the quorum"},
+		},
+		{
+			name:    "no valid votes",
+			contest: pair, counts: map[uint]int64{}, quorum: true,
+			want: contestOutcome{Status: outcomeVoid, Reason: "No valid votes"},
+		},
+		{
+			name:    "most votes wins",
+			contest: pair, counts: map[uint]int64{1: 3, 2: 5, 0: 1}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{2}},
+		},
+		{
+			name:     "Kotak Kosong wins and voids the contest",
+			election: models.Election{KotakKosongWins: kotakKosongVoid},
+			contest:  single, counts: map[uint]int64{1: 4, 0: 6}, quorum: true,
+			want: contestOutcome{Status: outcomeVoid, Reason: "Kotak Kosong won"},
+		},
+		{
+			name:     "Kotak Kosong wins and the runner-up takes the seat",
+			election: models.Election{KotakKosongWins: kotakKosongRunnerUp},
+			contest:  pair, counts: map[uint]int64{1: 2, 2: 3, 0: 6}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{2}},
+		},
+		{
+			name:    "a tie with Kotak Kosong goes to the candidate",
+			contest: single, counts: map[uint]int64{1: 5, 0: 5}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{1}},
+		},
+		{
+			name:     "absolute majority missed sends the top two to a runoff",
+			election: models.Election{MajorityRule: majorityAbsolute},
+			contest:  pair, counts: map[uint]int64{1: 4, 2: 3, 0: 2}, quorum: true,
+			want: contestOutcome{Status: outcomeRunoff, Runoff: []uint{1, 2}, Reason: "No candidate has an absolute majority"},
+		},
+		{
+			name:     "single candidate without an absolute majority",
+			election: models.Election{MajorityRule: majorityAbsolute},
+			contest:  single, counts: map[uint]int64{1: 4, 0: 4}, quorum: true,
+			want: contestOutcome{Status: outcomeVoid, Reason: "No option has an absolute majority"},
+		},
+		{
+			name:    "two seats",
+			contest: senate, counts: map[uint]int64{1: 2, 2: 7, 3: 5}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{2, 3}},
+		},
+		{
+			name:    "tie for the last seat",
+			contest: senate, counts: map[uint]int64{1: 5, 2: 7, 3: 5}, quorum: true,
+			want: contestOutcome{Status: outcomeRunoff, Winners: []uint{2}, Runoff: []uint{1, 3}, Reason: "Tie for the last seat"},
+		},
+		{
+			name:    "tied referendum",
+			contest: referendum, counts: map[uint]int64{1: 5, 2: 5}, quorum: true,
+			want: contestOutcome{Status: outcomeVoid, Reason: "The referendum is tied"},
+		},
+		{
+			name:     "votes for a withdrawn candidate are void",
+			election: models.Election{WithdrawnVotes: withdrawnVoid},
+			contest:  dropout, counts: map[uint]int64{1: 9, 2: 3}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{2}},
+		},
+		{
+			name:     "votes for a withdrawn candidate still count",
+			election: models.Election{WithdrawnVotes: withdrawnCount},
+			contest:  dropout, counts: map[uint]int64{1: 9, 2: 3}, quorum: true,
+			want: contestOutcome{Status: outcomeWinner, Winners: []uint{1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decideContest(&tt.election, tt.contest, tt.counts, nil, nil, tt.quorum)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decideContest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecideRankedContest(t *testing.T) {
+	contest := testContest(contestCandidates, methodIRV, 1, true, 1, 2)
+	// Kotak Kosong wins outright; without it, 2 beats 1 on transfers.
+	ballots := ballotSet(repeat(6, 0, 2), repeat(3, 1, 2), repeat(2, 2, 1))
+
+	tests := []struct {
+		name string
+		wins string
+		want contestOutcome
+	}{
+		{"Kotak Kosong voids the contest", kotakKosongVoid, contestOutcome{Status: outcomeVoid, Reason: "Kotak Kosong won"}},
+		{"recount without Kotak Kosong", kotakKosongRunnerUp, contestOutcome{Status: outcomeWinner, Winners: []uint{2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &models.Election{KotakKosongWins: tt.wins}
+			rounds := instantRunoff(contestOptions(e, contest), ballots)
+			if got := decideContest(e, contest, nil, rounds, ballots, true); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decideContest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffersKotakKosong(t *testing.T) {
+	withdrawn := time.Now()
+	oneLeft := testContest(contestCandidates, methodPlurality, 1, false, 1, 2)
+	oneLeft.Candidates[1].WithdrawnAt = &withdrawn
+	noneLeft := testContest(contestCandidates, methodPlurality, 1, false, 1)
+	noneLeft.Candidates[0].WithdrawnAt = &withdrawn
+
+	tests := []struct {
+		name    string
+		policy  string
+		contest *models.Contest
+		want    bool
+	}{
+		{"per contest, offered", kotakKosongPerContest, testContest(contestCandidates, methodPlurality, 1, true, 1, 2), true},
+		{"per contest, not offered", kotakKosongPerContest, testContest(contestCandidates, methodPlurality, 1, false, 1, 2), false},
+		{"always", kotakKosongAlways, testContest(contestCandidates, methodPlurality, 1, false, 1, 2), true},
+		{"never", kotakKosongNever, testContest(contestCandidates, methodPlurality, 1, true, 1), false},
+		{"single candidate", kotakKosongSingle, testContest(contestCandidates, methodPlurality, 1, false, 1), true},
+		{"single candidate policy, two running", kotakKosongSingle, testContest(contestCandidates, methodPlurality, 1, false, 1, 2), false},
+		{"single candidate left after a withdrawal", kotakKosongSingle, oneLeft, true},
+		{"every candidate withdrew", kotakKosongSingle, noneLeft, true},
+		{"single candidate policy, no candidates", kotakKosongSingle, testContest(contestCandidates, methodPlurality, 1, false), false},
+		{"referendum", kotakKosongAlways, testContest(contestReferendum, methodPlurality, 1, true, 1, 2), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &models.Election{KotakKosongPolicy: tt.policy}
+			if got := offersKotakKosong(e, tt.contest); got != tt.want {
+				t.Errorf("offersKotakKosong = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
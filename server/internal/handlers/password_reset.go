@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/email"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var errInvalidResetLink = errors.New("invalid or expired reset link")
+
+// clientURL is the public base URL of the web client, used in emailed links.
+func clientURL() string {
+	if u := os.Getenv("CLIENT_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:3000"
+}
+
+func passwordResetTTL() time.Duration {
+	if v := os.Getenv("PASSWORD_RESET_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return time.Hour
+}
+
+// ForgotPassword emails a single-use reset link. It answers the same way
+// whether or not the email is registered, so it cannot be used to probe accounts.
+// Works for voters and admin accounts alike.
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email wajib diisi"})
+		return
+	}
+
+	if !throttleEmailRequest(c, "forgot_password", req.Email) {
+		return
+	}
+
+	response := gin.H{"message": "Jika email terdaftar, tautan reset password telah dikirim."}
+
+	var user models.User
+	if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil || user.Password == nil || user.Disabled {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := auth.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tautan reset"})
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link stays valid.
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: auth.HashToken(token),
+			ExpiresAt: time.Now().Add(passwordResetTTL()),
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tautan reset"})
+		return
+	}
+
+	link := clientURL() + "/reset-password?token=" + url.QueryEscape(token)
+	go func() {
+		if err := email.SendPasswordResetEmail(user.Email, user.Name, link); err != nil {
+			log.Printf("Failed to send password reset email to %s: %v", user.Email, err)
+		} else {
+			log.Printf("Password reset email sent to %s", user.Email)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes
+// every existing session of the account.
+func ResetPassword(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if len(req.Password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password minimal 8 karakter"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses password"})
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the token atomically so it can only be used once.
+		now := time.Now()
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", auth.HashToken(req.Token)).First(&reset).Error; err != nil {
+			return errInvalidResetLink
+		}
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", reset.ID, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvalidResetLink
+		}
+
+		var user models.User
+		if err := tx.First(&user, reset.UserID).Error; err != nil {
+			return errInvalidResetLink
+		}
+		return setPassword(tx, &user, string(hashed))
+	})
+	if errors.Is(err, errInvalidResetLink) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tautan reset tidak valid atau sudah kedaluwarsa"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diubah. Silakan login kembali."})
+}
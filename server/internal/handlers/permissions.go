@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Permission string
+
+const (
+	PermViewUsers        Permission = "users:view"
+	PermVerifyUsers      Permission = "users:verify"
+	PermViewVotes        Permission = "votes:view"
+	PermReviewVotes      Permission = "votes:review"
+	PermViewResults      Permission = "results:view"
+	PermManageCandidates Permission = "candidates:manage"
+	PermManageSettings   Permission = "settings:manage"
+	PermManageAdmins     Permission = "admins:manage"
+	PermManageLockouts   Permission = "lockouts:manage"
+	PermManageSessions   Permission = "sessions:manage"
+	PermTally            Permission = "tally:submit"
+)
+
+var rolePermissions = map[string][]Permission{
+	models.RoleSuperAdmin: {
+		PermViewUsers, PermVerifyUsers, PermViewVotes, PermReviewVotes, PermViewResults,
+		PermManageCandidates, PermManageSettings, PermManageAdmins, PermManageLockouts, PermManageSessions,
+		PermTally,
+	},
+	models.RoleVerifier: {PermViewUsers, PermVerifyUsers},
+	models.RoleAuditor:  {PermViewUsers, PermViewVotes, PermReviewVotes, PermViewResults, PermTally},
+	models.RoleObserver: {PermViewUsers, PermViewVotes, PermViewResults},
+}
+
+func hasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission rejects callers whose role lacks perm. Must run after RequireAuth.
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		if user == nil || !hasPermission(user.Role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// nimPrefixes splits a comma-separated NIM prefix list such as a user's
+// NIMScope into its prefixes.
+func nimPrefixes(scope string) []string {
+	var prefixes []string
+	for _, p := range strings.Split(scope, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// nimScope is a gorm scope restricting a query to the NIM prefixes the admin
+// is scoped to. column is the NIM column to filter on, e.g. "nim" or "users.nim".
+func nimScope(admin *models.User, column string) func(*gorm.DB) *gorm.DB {
+	return nimPrefixScope(admin.NIMScope, column)
+}
+
+// nimPrefixScope restricts a query to NIMs starting with one of the
+// comma-separated prefixes; an empty list restricts nothing.
+func nimPrefixScope(scope, column string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		prefixes := nimPrefixes(scope)
+		if len(prefixes) == 0 {
+			return query
+		}
+		conds := make([]string, len(prefixes))
+		args := make([]interface{}, len(prefixes))
+		for i, p := range prefixes {
+			conds[i] = column + " LIKE ?"
+			args[i] = p + "%"
+		}
+		return query.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+}
+
+// inNIMScope reports whether the admin may act on a user with the given NIM.
+func inNIMScope(admin *models.User, nim string) bool {
+	return hasNIMPrefix(admin.NIMScope, nim)
+}
+
+// hasNIMPrefix reports whether nim starts with one of the comma-separated
+// prefixes; an empty list matches every NIM.
+func hasNIMPrefix(scope, nim string) bool {
+	prefixes := nimPrefixes(scope)
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(nim, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Phase is a stage of an election's life. An election only moves forward:
+// an admin can advance it early, and its schedule advances it on time.
+type Phase string
+
+const (
+	PhaseDraft        Phase = "draft"        // Being set up; hidden from voters
+	PhaseRegistration Phase = "registration" // From RegistrationStart
+	PhaseVerification Phase = "verification" // From RegistrationEnd: registrations are reviewed
+	PhaseVoting       Phase = "voting"       // From StartTime
+	PhaseCounting     Phase = "counting"     // From EndTime: the tally ceremony
+	PhasePublished    Phase = "published"    // Results announced, by an admin
+	PhaseArchived     Phase = "archived"     // Hidden from voters, by an admin
+)
+
+var phaseOrder = []Phase{
+	PhaseDraft, PhaseRegistration, PhaseVerification, PhaseVoting, PhaseCounting, PhasePublished, PhaseArchived,
+}
+
+// phaseNames are the phases as voters read them.
+var phaseNames = map[Phase]string{
+	PhaseDraft:        "persiapan",
+	PhaseRegistration: "pendaftaran",
+	PhaseVerification: "verifikasi",
+	PhaseVoting:       "pemungutan suara",
+	PhaseCounting:     "penghitungan suara",
+	PhasePublished:    "pengumuman hasil",
+	PhaseArchived:     "arsip",
+}
+
+// phaseIndex is p's position in phaseOrder, or -1 for an unknown phase.
+func phaseIndex(p Phase) int {
+	for i, q := range phaseOrder {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func phaseAfter(a, b Phase) bool {
+	return phaseIndex(a) > phaseIndex(b)
+}
+
+// electionPhase is the phase e is in at now. Archiving overrides the rest.
+func electionPhase(e *models.Election, now time.Time) Phase {
+	if e.Archived {
+		return PhaseArchived
+	}
+	return runningPhase(e, now)
+}
+
+// runningPhase is the later of the phase an admin moved e to and the one its
+// schedule has reached.
+func runningPhase(e *models.Election, now time.Time) Phase {
+	phase := Phase(e.Phase)
+	if phase == "" {
+		phase = PhaseDraft
+	}
+	steps := []struct {
+		at    *time.Time
+		phase Phase
+	}{
+		{e.EndTime, PhaseCounting},
+		{e.StartTime, PhaseVoting},
+		{e.RegistrationEnd, PhaseVerification},
+		{e.RegistrationStart, PhaseRegistration},
+	}
+	for _, s := range steps {
+		if s.at != nil && !now.Before(*s.at) {
+			if phaseAfter(s.phase, phase) {
+				phase = s.phase
+			}
+			break
+		}
+	}
+	return phase
+}
+
+// withPhases fills in the current phase of elections about to be returned.
+func withPhases(elections []models.Election) []models.Election {
+	now := time.Now()
+	for i := range elections {
+		elections[i].Phase = string(electionPhase(&elections[i], now))
+	}
+	return elections
+}
+
+func phaseAllowed(p Phase, phases []Phase) bool {
+	for _, q := range phases {
+		if p == q {
+			return true
+		}
+	}
+	return false
+}
+
+// contextElection is where RequirePhase leaves the election it checked, for
+// resolveElection to pick up.
+const contextElection = "election"
+
+// RequestElection finds the election a request names with electionId. When it
+// names none, RequirePhase takes the first election in an allowed phase.
+func RequestElection(c *gin.Context) *models.Election {
+	if c.Query("electionId") == "" && c.PostForm("electionId") == "" {
+		return nil
+	}
+	return resolveElection(c)
+}
+
+// PathElection finds the election in the :id path parameter.
+func PathElection(c *gin.Context) *models.Election {
+	return electionFromParam(c)
+}
+
+// ContestElection finds the election of the contest in the :id path parameter.
+func ContestElection(c *gin.Context) *models.Election {
+	var contest models.Contest
+	if err := db.DB.First(&contest, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
+		return nil
+	}
+	return electionByID(c, contest.ElectionID)
+}
+
+// CandidateElection finds the election of the candidate in the :id path parameter.
+func CandidateElection(c *gin.Context) *models.Election {
+	var candidate models.Candidate
+	if err := db.DB.First(&candidate, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Candidate not found"})
+		return nil
+	}
+	return electionByID(c, candidate.ElectionID)
+}
+
+func electionByID(c *gin.Context, id uint) *models.Election {
+	var election models.Election
+	if err := db.DB.First(&election, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Election not found"})
+		return nil
+	}
+	return &election
+}
+
+// RequirePhase rejects requests made while the election found by lookup is
+// in none of the given phases. Requests that name no election pass if any
+// election is in one of them, and act on the first such election.
+func RequirePhase(lookup func(*gin.Context) *models.Election, phases ...Phase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		election := lookup(c)
+		if c.IsAborted() || c.Writer.Written() {
+			c.Abort()
+			return
+		}
+
+		if election == nil {
+			var elections []models.Election
+			db.DB.Order("start_time, id").Find(&elections)
+			for i := range elections {
+				if phaseAllowed(electionPhase(&elections[i], now), phases) {
+					election = &elections[i]
+					break
+				}
+			}
+			if election == nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": phaseError(c, "")})
+				return
+			}
+		}
+
+		phase := electionPhase(election, now)
+		if !phaseAllowed(phase, phases) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": phaseError(c, phase), "phase": phase})
+			return
+		}
+		c.Set(contextElection, election)
+		c.Next()
+	}
+}
+
+// phaseError explains a rejection in the caller's language: English for
+// admins, Indonesian for voters and visitors.
+func phaseError(c *gin.Context, phase Phase) string {
+	if user := currentUser(c); user != nil && user.Role != models.RoleVoter {
+		if phase == "" {
+			return "No election is in a phase that allows this"
+		}
+		return fmt.Sprintf("Not allowed while the election is in the %s phase", phase)
+	}
+	if phase == "" {
+		return "Tidak ada pemilihan yang sedang berada pada tahap ini"
+	}
+	return fmt.Sprintf("Tidak dapat dilakukan pada tahap %s", phaseNames[phase])
+}
+
+// SetElectionPhase moves an election forward to a later phase ahead of its
+// schedule, e.g. to close voting early or to publish the results. Archiving
+// has its own endpoint.
+func SetElectionPhase(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	var req struct {
+		Phase Phase `json:"phase"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || phaseIndex(req.Phase) < 0 || req.Phase == PhaseArchived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phase"})
+		return
+	}
+
+	current := electionPhase(election, time.Now())
+	if current == PhaseArchived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Restore the election before changing its phase"})
+		return
+	}
+	if !phaseAfter(req.Phase, current) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The election is already in the %s phase; phases only move forward", current)})
+		return
+	}
+	if req.Phase == PhasePublished {
+		if current != PhaseCounting {
+			c.JSON(http.StatusConflict, gin.H{"error": "Results can only be published after voting closed"})
+			return
+		}
+		if err := flushBallots(election); err != nil {
+			log.Printf("Failed to release held ballots of election %d: %v", election.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update election"})
+			return
+		}
+		var sealed int64
+		db.DB.Model(&models.Ballot{}).Where("election_id = ? AND spoiled = ? AND decrypted = ?", election.ID, false, false).Count(&sealed)
+		if sealed > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Decrypt the ballots in the tally ceremony before publishing"})
+			return
+		}
+	}
+
+	if err := db.DB.Model(election).Update("phase", string(req.Phase)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update election"})
+		return
+	}
+	log.Printf("Admin %d moved election %d from %s to %s", currentUser(c).ID, election.ID, current, req.Phase)
+	election.Phase = string(electionPhase(election, time.Now()))
+	c.JSON(http.StatusOK, election)
+}
+
+// MigrateElectionPhases puts elections that predate phases into the
+// registration phase, where their schedule takes over as before; new
+// elections start as drafts.
+func MigrateElectionPhases() error {
+	return db.RunMigration("introduce_election_phases", func(tx *gorm.DB) error {
+		return tx.Model(&models.Election{}).
+			Where("phase IS NULL OR phase IN ?", []string{"", string(PhaseDraft)}).
+			Update("phase", string(PhaseRegistration)).Error
+	})
+}
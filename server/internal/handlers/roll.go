@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// electionHasRoll reports whether an official voter roll was imported for
+// the election. Without one, eligibility falls back to NIM prefixes.
+func electionHasRoll(electionID uint) bool {
+	var entry models.RollEntry
+	return db.DB.Where("election_id = ?", electionID).Take(&entry).Error == nil
+}
+
+// rollEntry returns the NIM's line on the election's roll, or nil.
+func rollEntry(electionID uint, nim string) *models.RollEntry {
+	var entry models.RollEntry
+	if err := db.DB.Where("election_id = ? AND nim = ?", electionID, nim).First(&entry).Error; err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// onRoll reports whether the election admits the NIM: it is on the roll, or
+// the election has none.
+func onRoll(e *models.Election, nim string) bool {
+	return rollEntry(e.ID, nim) != nil || !electionHasRoll(e.ID)
+}
+
+// rollScope restricts a query to NIMs on the election's roll, if it has one.
+func rollScope(electionID uint, column string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("(NOT EXISTS (SELECT 1 FROM roll_entries r WHERE r.election_id = ?) OR "+column+" IN (SELECT r.nim FROM roll_entries r WHERE r.election_id = ?))", electionID, electionID)
+	}
+}
+
+// rollLoginError returns why a voter with this NIM may not log in, or "".
+// Only elections that are under way count: past the draft phase and not
+// archived. Once any of them has a roll, the NIM must be on the roll of one
+// of them; an election without a roll does not open the login to everyone.
+func rollLoginError(nim string) string {
+	var elections []models.Election
+	db.DB.Where("archived = ?", false).Find(&elections)
+	now := time.Now()
+	rolled := false
+	for i := range elections {
+		if electionPhase(&elections[i], now) == PhaseDraft || !electionHasRoll(elections[i].ID) {
+			continue
+		}
+		if rollEntry(elections[i].ID, nim) != nil {
+			return ""
+		}
+		rolled = true
+	}
+	if !rolled {
+		return ""
+	}
+	return "NIM Anda tidak terdaftar dalam Daftar Pemilih Tetap"
+}
+
+// normalizeName folds case, punctuation and spacing so that "M. Rizki" and
+// "m rizki" compare equal.
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// rollMatch is how a registration compares to the voter roll, shown to the
+// verifier next to it.
+type rollMatch struct {
+	models.User
+	OnRoll       bool   `json:"onRoll"`
+	RollName     string `json:"rollName,omitempty"`
+	RollCohort   string `json:"rollCohort,omitempty"`
+	NameMismatch bool   `json:"nameMismatch"`
+}
+
+// matchRoll looks registrations up on the rolls of the elections that are
+// not archived.
+func matchRoll(users []models.User) []rollMatch {
+	matches := make([]rollMatch, len(users))
+	for i, u := range users {
+		matches[i].User = u
+		var entry models.RollEntry
+		err := db.DB.Joins("JOIN elections e ON e.id = roll_entries.election_id AND e.archived = ?", false).
+			Where("roll_entries.nim = ?", u.NIM).Order("roll_entries.election_id DESC").First(&entry).Error
+		if err != nil {
+			continue
+		}
+		matches[i].OnRoll = true
+		matches[i].RollName = entry.Name
+		matches[i].RollCohort = entry.Cohort
+		matches[i].NameMismatch = normalizeName(entry.Name) != normalizeName(u.Name)
+	}
+	return matches
+}
+
+// rollFrozen reports whether voting has opened, after which the roll and so
+// the electorate and the turnout denominator no longer change.
+func rollFrozen(e *models.Election) bool {
+	return phaseAfter(electionPhase(e, time.Now()), PhaseVerification)
+}
+
+// GetRoll lists an election's voter roll.
+func GetRoll(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	entries := []models.RollEntry{}
+	db.DB.Where("election_id = ?", election.ID).Order("nim").Find(&entries)
+
+	var registered int64
+	if len(entries) > 0 {
+		db.DB.Model(&models.User{}).
+			Where("role = ? AND verification_status = ?", models.RoleVoter, "approved").
+			Scopes(rollScope(election.ID, "nim")).
+			Count(&registered)
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "approved": registered, "frozen": rollFrozen(election)})
+}
+
+// ImportRoll replaces an election's voter roll with an uploaded CSV of NIM,
+// name and cohort. A header row is optional. The whole file is refused if
+// any line is invalid.
+func ImportRoll(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the roll as a CSV file"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the file"})
+		return
+	}
+	defer f.Close()
+
+	entries, errs := parseRoll(f, election.ID)
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The roll has invalid lines", "lines": errs})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The roll is empty"})
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("election_id = ?", election.ID).Delete(&models.RollEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(entries, 500).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import roll"})
+		return
+	}
+	log.Printf("Admin %d imported a roll of %d voters for election %d", currentUser(c).ID, len(entries), election.ID)
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Imported %d voters", len(entries)), "imported": len(entries)})
+}
+
+// parseRoll reads roll lines of NIM, name and an optional cohort.
+func parseRoll(r io.Reader, electionID uint) ([]models.RollEntry, []string) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []models.RollEntry
+	var errs []string
+	seen := map[string]int{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			// Spreadsheets often save a byte order mark.
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			if strings.EqualFold(strings.TrimSpace(record[0]), "nim") {
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 {
+			errs = append(errs, fmt.Sprintf("line %d: expected NIM, name and cohort", line))
+			continue
+		}
+
+		entry := models.RollEntry{
+			ElectionID: electionID,
+			NIM:        strings.TrimSpace(record[0]),
+			Name:       strings.TrimSpace(record[1]),
+		}
+		if len(record) > 2 {
+			entry.Cohort = strings.TrimSpace(record[2])
+		}
+		switch {
+		case entry.NIM == "" || strings.ContainsFunc(entry.NIM, func(r rune) bool { return r < '0' || r > '9' }):
+			errs = append(errs, fmt.Sprintf("line %d: NIM %q must be digits", line, entry.NIM))
+		case entry.Name == "":
+			errs = append(errs, fmt.Sprintf("line %d: name is missing", line))
+		case seen[entry.NIM] != 0:
+			errs = append(errs, fmt.Sprintf("line %d: NIM %s is already on line %d", line, entry.NIM, seen[entry.NIM]))
+		default:
+			seen[entry.NIM] = line
+			entries = append(entries, entry)
+		}
+	}
+	return entries, errs
+}
+
+// DeleteRoll removes an election's roll, returning it to NIM prefixes.
+func DeleteRoll(c *gin.Context) {
+	election := electionFromParam(c)
+	if election == nil {
+		return
+	}
+	if err := db.DB.Where("election_id = ?", election.ID).Delete(&models.RollEntry{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete roll"})
+		return
+	}
+	log.Printf("Admin %d deleted the roll of election %d", currentUser(c).ID, election.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Roll deleted"})
+}
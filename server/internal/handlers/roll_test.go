@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"voting-backend/internal/models"
+)
+
+func TestParseRoll(t *testing.T) {
+	entry := func(nim, name, cohort string) models.RollEntry {
+		return models.RollEntry{ElectionID: 7, NIM: nim, Name: name, Cohort: cohort}
+	}
+	tests := []struct {
+		name     string
+		input    string
+		want     []models.RollEntry
+		wantErrs []string
+	}{
+		{
+			name:  "header and byte order mark",
+			input: "\ufeffNIM,Nama,Angkatan\n15022045,Ani,2022\n",
+			want:  []models.RollEntry{entry("15022045", "Ani", "2022")},
+		},
+		{
+			name:  "no header",
+			input: "15022045,Ani\n15022046,Budi,2022\n",
+			want:  []models.RollEntry{entry("15022045", "Ani", ""), entry("15022046", "Budi", "2022")},
+		},
+		{
+			name:  "byte order mark without a header",
+			input: "\ufeff15022045,Ani\n",
+			want:  []models.RollEntry{entry("15022045", "Ani", "")},
+		},
+		{
+			name:  "spaces are trimmed",
+			input: " 15022045 , Ani Lestari , 2022 \n",
+			want:  []models.RollEntry{entry("15022045", "Ani Lestari", "2022")},
+		},
+		{
+			name:     "blank lines are skipped but still counted",
+			input:    "15022045,Ani\n\n\n1502204X,Budi\n",
+			want:     []models.RollEntry{entry("15022045", "Ani", "")},
+			wantErrs: []string{`line 4: NIM "1502204X" must be digits`},
+		},
+		{
+			name:     "NIM missing",
+			input:    ",Ani\n",
+			wantErrs: []string{`line 1: NIM "" must be digits`},
+		},
+		{
+			name:     "name missing",
+			input:    "15022045, \n",
+			wantErrs: []string{"line 1: name is missing"},
+		},
+		{
+			name:     "NIM alone",
+			input:    "15022045\n",
+			wantErrs: []string{"line 1: expected NIM, name and cohort"},
+		},
+		{
+			name:     "duplicate NIM",
+			input:    "nim,name\n15022045,Ani\n15022046,Budi\n15022045,Ani L\n",
+			want:     []models.RollEntry{entry("15022045", "Ani", ""), entry("15022046", "Budi", "")},
+			wantErrs: []string{"line 4: NIM 15022045 is already on line 2"},
+		},
+		{
+			name:     "every bad line is reported",
+			input:    "abc,Ani\n15022045,\n15022046,Budi\n",
+			want:     []models.RollEntry{entry("15022046", "Budi", "")},
+			wantErrs: []string{`line 1: NIM "abc" must be digits`, "line 2: name is missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := parseRoll(strings.NewReader(tt.input), 7)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errors = %q, want %q", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestParseRollStopsAtMalformedCSV(t *testing.T) {
+	got, errs := parseRoll(strings.NewReader("15022045,Ani\n15022046,\"Budi\n15022047,Citra\n"), 7)
+	if len(got) != 1 || got[0].NIM != "15022045" {
+		t.Errorf("entries = %+v, want only 15022045", got)
+	}
+	if len(errs) != 1 {
+		t.Errorf("errors = %q, want one parse error", errs)
+	}
+}
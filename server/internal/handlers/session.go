@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ctxSessionKey = "authSession"
+	// lastSeenInterval limits how often LastSeenAt is written per session.
+	lastSeenInterval = time.Minute
+	// sessionRetention keeps ended sessions around for auditing before purging.
+	sessionRetention = 30 * 24 * time.Hour
+)
+
+// Session revocation reasons.
+const (
+	revokeLogout          = "logout"
+	revokeRejected        = "rejected"
+	revokePasswordChanged = "password_changed"
+	revokeTokenReissued   = "token_reissued"
+	revokeMFAReset        = "mfa_reset"
+	revokeDisabled        = "disabled"
+	revokeAdmin           = "admin"
+)
+
+// newSessionToken records a new session for the user and signs its token.
+// Responds with 500 and returns ok=false on failure.
+func newSessionToken(c *gin.Context, user *models.User) (string, time.Time, bool) {
+	token, expiresAt, err := createSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return "", time.Time{}, false
+	}
+	return token, expiresAt, true
+}
+
+// createSession records a new session for the user and signs its token.
+func createSession(c *gin.Context, user *models.User) (string, time.Time, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(auth.SessionTTL()),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	token, err := auth.Issue(session.ID, user.ID, user.Role, session.ExpiresAt)
+	return token, session.ExpiresAt, err
+}
+
+// revokeUserSessions ends every active session of the user.
+func revokeUserSessions(tx *gorm.DB, userID uint, reason string) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// touchSession records activity at most once per lastSeenInterval.
+func touchSession(session *models.Session, c *gin.Context) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < lastSeenInterval {
+		return
+	}
+	session.LastSeenAt = now
+	session.IP = c.ClientIP()
+	db.DB.Model(session).Updates(map[string]interface{}{"last_seen_at": now, "ip": session.IP})
+}
+
+// currentSession returns the session bound by RequireAuth.
+func currentSession(c *gin.Context) *models.Session {
+	if v, ok := c.Get(ctxSessionKey); ok {
+		return v.(*models.Session)
+	}
+	return nil
+}
+
+// RefreshSession extends the current session and returns a fresh token for
+// it. A session never outlives auth.SessionMaxAge from when it was issued;
+// after that the user must log in again.
+func RefreshSession(c *gin.Context) {
+	user := currentUser(c)
+	session := currentSession(c)
+
+	now := time.Now()
+	limit := session.IssuedAt.Add(auth.SessionMaxAge())
+	if !now.Before(limit) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has reached its maximum age, please log in again"})
+		return
+	}
+	session.ExpiresAt = now.Add(auth.SessionTTL())
+	if session.ExpiresAt.After(limit) {
+		session.ExpiresAt = limit
+	}
+	if err := db.DB.Model(session).Update("expires_at", session.ExpiresAt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	token, err := auth.Issue(session.ID, user.ID, user.Role, session.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sessionToken": token,
+		"expiresAt":    session.ExpiresAt,
+	})
+}
+
+// Me returns the signed-in user.
+func Me(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
+
+// Logout ends the current session.
+func Logout(c *gin.Context) {
+	err := db.DB.Model(currentSession(c)).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": revokeLogout}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// findSessionOwner loads the user whose sessions are being managed. Voters
+// outside the admin's NIM scope are reported as not found.
+func findSessionOwner(c *gin.Context, id interface{}) (*models.User, bool) {
+	var user models.User
+	if err := db.DB.First(&user, id).Error; err != nil ||
+		(user.Role == models.RoleVoter && !inNIMScope(currentUser(c), user.NIM)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// GetUserSessions lists a user's sessions, newest first.
+func GetUserSessions(c *gin.Context) {
+	user, ok := findSessionOwner(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	sessions := []models.Session{}
+	if err := db.DB.Where("user_id = ?", user.ID).Order("issued_at DESC").Limit(100).Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession ends a single session.
+func RevokeSession(c *gin.Context) {
+	var session models.Session
+	if err := db.DB.Where("id = ?", c.Param("sid")).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if _, ok := findSessionOwner(c, session.UserID); !ok {
+		return
+	}
+
+	err := db.DB.Model(&session).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": revokeAdmin}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	log.Printf("Admin %d revoked session %s of user %d", currentUser(c).ID, session.ID, session.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeUserSessions ends every active session of a user.
+func RevokeUserSessions(c *gin.Context) {
+	user, ok := findSessionOwner(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := revokeUserSessions(db.DB, user.ID, revokeAdmin); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	log.Printf("Admin %d revoked all sessions of user %d", currentUser(c).ID, user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// PurgeSessions deletes sessions that ended more than sessionRetention ago.
+func PurgeSessions() {
+	cutoff := time.Now().Add(-sessionRetention)
+	res := db.DB.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.Session{})
+	if res.Error != nil {
+		log.Printf("Failed to purge sessions: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Purged %d old sessions", res.RowsAffected)
+	}
+}
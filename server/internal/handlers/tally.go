@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+	"voting-backend/internal/threshold"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errNoElectionKey = errors.New("election public key is not set")
+
+// electionPublicKey is the key the election's ballots are encrypted to,
+// generated with cmd/electionkey; ELECTION_PUBLIC_KEY when the election has
+// none of its own. Its private key only exists as committee shares.
+func electionPublicKey(election *models.Election) (*ecdh.PublicKey, error) {
+	v := election.PublicKey
+	if v == "" {
+		v = os.Getenv("ELECTION_PUBLIC_KEY")
+	}
+	if v == "" {
+		return nil, errNoElectionKey
+	}
+	return threshold.ParsePublicKey(v)
+}
+
+func encryptChoice(election *models.Election, payload []byte) (string, error) {
+	pub, err := electionPublicKey(election)
+	if err != nil {
+		return "", err
+	}
+	return threshold.Encrypt(pub, payload)
+}
+
+// GetTallyStatus shows the progress of an election's tally ceremony.
+func GetTallyStatus(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	var shares []models.TallyShare
+	db.DB.Where("election_id = ?", election.ID).Order("x").Find(&shares)
+	required := 0
+	if len(shares) > 0 {
+		required = shares[0].Threshold
+	}
+
+	var encrypted, decrypted int64
+	db.DB.Model(&models.Ballot{}).Where("election_id = ? AND spoiled = ? AND decrypted = ?", election.ID, false, false).Count(&encrypted)
+	db.DB.Model(&models.Ballot{}).Where("election_id = ? AND spoiled = ? AND decrypted = ?", election.ID, false, true).Count(&decrypted)
+
+	_, keyErr := electionPublicKey(election)
+	c.JSON(http.StatusOK, gin.H{
+		"electionId":          election.ID,
+		"publicKeyConfigured": keyErr == nil,
+		"electionClosed":      electionEnded(election),
+		"sharesSubmitted":     len(shares),
+		"sharesRequired":      required,
+		"encryptedBallots":    encrypted,
+		"decryptedBallots":    decrypted,
+		"pendingReview":       pendingParticipations(election.ID),
+	})
+}
+
+// pendingParticipations counts the votes of an election still awaiting
+// ApproveVote; their ballots are not sealed yet.
+func pendingParticipations(electionID uint) int64 {
+	var pending int64
+	db.DB.Model(&models.Participation{}).Where("election_id = ? AND status = ?", electionID, "pending").Count(&pending)
+	return pending
+}
+
+// SubmitTallyShare records one committee member's key share, sealed, and
+// takes one share per admin so no single account can supply the threshold.
+// Once enough shares are in they are deleted, whatever comes of them, and
+// the election key recovered from them in memory decrypts every encrypted
+// ballot. The share that would complete the set is refused while votes are
+// still under review, since ballots approved after the key is gone could
+// not be decrypted.
+func SubmitTallyShare(c *gin.Context) {
+	var req struct {
+		Share string `json:"share"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	if !electionEnded(election) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The tally opens after the election has closed"})
+		return
+	}
+	pub, err := electionPublicKey(election)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Election public key is not configured"})
+		return
+	}
+	share, err := threshold.ParseShare(req.Share)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key share"})
+		return
+	}
+
+	var existing models.TallyShare
+	if err := db.DB.Where("election_id = ?", election.ID).First(&existing).Error; err == nil && existing.Threshold != share.Threshold {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Key share belongs to a different key split"})
+		return
+	}
+	var mine int64
+	db.DB.Model(&models.TallyShare{}).Where("election_id = ? AND submitted_by = ?", election.ID, currentUser(c).ID).Count(&mine)
+	if mine > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already submitted a key share; the next one must come from another committee member"})
+		return
+	}
+	var submitted int64
+	db.DB.Model(&models.TallyShare{}).Where("election_id = ?", election.ID).Count(&submitted)
+	if pending := pendingParticipations(election.ID); pending > 0 && int(submitted)+1 >= share.Threshold {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Votes are still awaiting review. Approve or reject them before submitting the last key share.",
+			"pendingReview": pending,
+		})
+		return
+	}
+	sealed, err := auth.SealShare(req.Share)
+	if err != nil {
+		log.Printf("Failed to seal tally share: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "BALLOT_ENVELOPE_KEY is not configured"})
+		return
+	}
+	row := models.TallyShare{ElectionID: election.ID, X: int(share.X), Threshold: share.Threshold, Share: sealed, SubmittedBy: currentUser(c).ID}
+	if err := db.DB.Create(&row).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This key share was already submitted"})
+		return
+	}
+	log.Printf("Admin %d submitted tally share %d for election %d", currentUser(c).ID, share.X, election.ID)
+
+	var rows []models.TallyShare
+	db.DB.Where("election_id = ?", election.ID).Find(&rows)
+	if len(rows) < share.Threshold {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":         "Key share accepted",
+			"sharesSubmitted": len(rows),
+			"sharesRequired":  share.Threshold,
+		})
+		return
+	}
+
+	shares := make([]threshold.Share, 0, len(rows))
+	for _, r := range rows {
+		plain, err := auth.OpenShare(r.Share)
+		if err != nil {
+			continue
+		}
+		if s, err := threshold.ParseShare(plain); err == nil {
+			shares = append(shares, s)
+		}
+	}
+	// The shares have served their purpose either way; a failed attempt
+	// starts over rather than keep a bad one around.
+	if err := db.DB.Where("election_id = ?", election.ID).Delete(&models.TallyShare{}).Error; err != nil {
+		log.Printf("Failed to delete tally shares of election %d: %v", election.ID, err)
+	}
+	priv, err := threshold.RecoverKey(shares, pub)
+	if err != nil {
+		log.Printf("Tally ceremony for election %d failed: %v", election.ID, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The submitted shares do not recover the election key. All shares were discarded; please submit again."})
+		return
+	}
+
+	if err := flushBallots(election); err != nil {
+		log.Printf("Failed to release held ballots of election %d: %v", election.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt ballots. The shares were discarded; please submit them again."})
+		return
+	}
+	decrypted, err := decryptBallots(election, priv)
+	if err != nil {
+		log.Printf("Tally decryption failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt ballots. The shares were discarded; please submit them again."})
+		return
+	}
+
+	log.Printf("Tally ceremony decrypted %d ballots of election %d", decrypted, election.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Ballots decrypted", "decrypted": decrypted})
+}
+
+// decryptBallots writes out the choices of every encrypted ballot of the
+// election. A ballot that does not decrypt to valid choices is spoiled rather
+// than guessed.
+func decryptBallots(election *models.Election, priv *ecdh.PrivateKey) (int, error) {
+	contests, err := electionContests(election)
+	if err != nil {
+		return 0, err
+	}
+	var ballots []models.Ballot
+	if err := db.DB.Where("election_id = ? AND spoiled = ? AND decrypted = ?", election.ID, false, false).Order("id").Find(&ballots).Error; err != nil {
+		return 0, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, b := range ballots {
+			updates := map[string]interface{}{"decrypted": true}
+			sel, err := decryptSelections(contests, priv, b.Ciphertext)
+			if err != nil {
+				log.Printf("Ballot %s could not be decrypted, spoiling it: %v", b.ID, err)
+				updates["spoiled"] = true
+			}
+			res := tx.Model(&models.Ballot{}).Where("id = ? AND decrypted = ?", b.ID, false).Updates(updates)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 || sel == nil {
+				continue
+			}
+			for contestID, choices := range sel {
+				for i, candidateID := range choices {
+					choice := models.BallotChoice{BallotID: b.ID, ContestID: contestID, CandidateID: candidateID, Rank: i + 1}
+					if err := tx.Create(&choice).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return len(ballots), err
+}
+
+func decryptSelections(contests []models.Contest, priv *ecdh.PrivateKey, ciphertext string) (ballotSelections, error) {
+	plain, err := threshold.Decrypt(priv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := parseSelections(contests, plain)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSelections(contests, sel); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// EncryptLegacyBallots encrypts ballots stored in plaintext by earlier
+// builds and hides their choices until the tally. It only runs once every
+// election holding such ballots has a key configured.
+func EncryptLegacyBallots() error {
+	var legacy []models.Ballot
+	if err := db.DB.Where("spoiled = ? AND ciphertext = ''", false).Find(&legacy).Error; err != nil {
+		return err
+	}
+	elections := map[uint]*models.Election{}
+	for _, b := range legacy {
+		if _, ok := elections[b.ElectionID]; ok {
+			continue
+		}
+		var election models.Election
+		if err := db.DB.First(&election, b.ElectionID).Error; err != nil {
+			return err
+		}
+		if _, err := electionPublicKey(&election); err != nil {
+			return nil
+		}
+		elections[b.ElectionID] = &election
+	}
+
+	return db.RunMigration("encrypt_legacy_ballots", func(tx *gorm.DB) error {
+		for _, b := range legacy {
+			var choices []models.BallotChoice
+			if err := tx.Where("ballot_id = ?", b.ID).Order("contest_id, rank").Find(&choices).Error; err != nil {
+				return err
+			}
+			sel := ballotSelections{}
+			for _, choice := range choices {
+				sel[choice.ContestID] = append(sel[choice.ContestID], choice.CandidateID)
+			}
+			payload, err := json.Marshal(sel)
+			if err != nil {
+				return err
+			}
+			ciphertext, err := encryptChoice(elections[b.ElectionID], payload)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Ballot{}).Where("id = ?", b.ID).Updates(map[string]interface{}{
+				"ciphertext": ciphertext,
+				"decrypted":  false,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("ballot_id = ?", b.ID).Delete(&models.BallotChoice{}).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("encrypt_legacy_ballots: encrypted %d ballots", len(legacy))
+		return nil
+	})
+}
+
+// MigrateTallyShares drops shares stored in the clear by earlier builds, so
+// a ceremony in progress across the upgrade has its shares submitted again,
+// sealed, and makes shares unique per admin with idx_tally_share_admin.
+func MigrateTallyShares() error {
+	return db.RunMigration("seal_tally_shares", func(tx *gorm.DB) error {
+		res := tx.Where("1 = 1").Delete(&models.TallyShare{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("seal_tally_shares: dropped %d unsealed tally shares; the committee must submit them again", res.RowsAffected)
+		}
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_tally_share_admin ON tally_shares (election_id, submitted_by)").Error
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAlreadyVoted = errors.New("Pengguna sudah memilih")
+
+// idempotencyKey reads the key a client sends so that retrying a ballot
+// returns the first response instead of failing. Keys are optional but must
+// be hard to guess: a UUID or longer.
+func idempotencyKey(c *gin.Context) (string, bool) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return "", true
+	}
+	return key, len(key) >= 16 && len(key) <= 128
+}
+
+// replayVote answers a retried ballot with the response the first attempt
+// got. It reports false when no ballot was cast with the key.
+func replayVote(c *gin.Context, electionID, userID uint, key string) bool {
+	var participation models.Participation
+	err := db.DB.Where("election_id = ? AND user_id = ? AND idempotency_key = ?", electionID, userID, auth.HashToken(key)).
+		First(&participation).Error
+	if err != nil {
+		return false
+	}
+	reply, err := auth.OpenReply(key, participation.Reply)
+	if err != nil {
+		return false
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", reply)
+	return true
+}
+
+// castVote records the participation in one transaction. Marking the voter
+// is a conditional update, so of two racing ballots only one gets past it;
+// the other waits for it to commit and then fails with errAlreadyVoted.
+func castVote(voter *models.ElectionVoter, session *models.VotingSession, participation *models.Participation) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.ElectionVoter{}).Where("id = ? AND has_voted = ?", voter.ID, false).Update("has_voted", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errAlreadyVoted
+		}
+		if err := claimVotingSession(tx, session, participation.CastAt); err != nil {
+			return err
+		}
+		return tx.Create(participation).Error
+	})
+}
+
+// MigrateUniqueParticipations backs race-free casting with a unique index on
+// participations. Casting used to race, so a voter may have more than one:
+// the first is kept and the rest are dropped. A dropped participation that
+// was already approved released a ballot that stays in the count, which is
+// logged for the committee.
+func MigrateUniqueParticipations() error {
+	return db.RunMigration("unique_participations", func(tx *gorm.DB) error {
+		var duplicates []models.Participation
+		err := tx.Raw(`SELECT * FROM participations p WHERE EXISTS (
+			SELECT 1 FROM participations q WHERE q.election_id = p.election_id AND q.user_id = p.user_id AND q.id < p.id)`).
+			Scan(&duplicates).Error
+		if err != nil {
+			return err
+		}
+		for _, p := range duplicates {
+			log.Printf("unique_participations: dropping duplicate participation %d of user %d in election %d (%s)", p.ID, p.UserID, p.ElectionID, p.Status)
+			if p.Status == "approved" {
+				log.Printf("unique_participations: WARNING participation %d already released a ballot, which cannot be withdrawn", p.ID)
+			}
+			if err := tx.Delete(&models.Participation{}, p.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_participation_voter ON participations (election_id, user_id)").Error
+	})
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+	"voting-backend/internal/threshold"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects db.DB to the Postgres database in TEST_DATABASE_URL and
+// skips the test without one. The database should be a scratch one: tests
+// migrate it and write to it.
+func testDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: dsn, PreferSimpleProtocol: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	db.DB = conn
+	err = db.DB.AutoMigrate(&models.User{}, &models.Election{}, &models.ElectionVoter{}, &models.RollEntry{},
+		&models.VotingSession{}, &models.Contest{}, &models.Candidate{}, &models.CandidateMember{}, &models.Participation{})
+	if err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	if err := MigrateUniqueParticipations(); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+}
+
+// Many copies of one ballot, as a client retrying on a flaky connection
+// sends them, must cast it once and all get the same reply.
+func TestConcurrentVoteCastsOnce(t *testing.T) {
+	testDB(t)
+	t.Setenv("BALLOT_ENVELOPE_KEY", "test-envelope-key")
+	gin.SetMode(gin.TestMode)
+
+	pub, _, err := threshold.GenerateKey(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	user := models.User{Name: "Voter", NIM: suffix, Email: suffix + "@test.invalid", Role: models.RoleVoter, VerificationStatus: "approved"}
+	election := models.Election{Name: "Concurrency " + suffix, Phase: string(PhaseVoting), PublicKey: threshold.EncodePublicKey(pub)}
+	for _, row := range []interface{}{&user, &election} {
+		if err := db.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	contest := models.Contest{ElectionID: election.ID, Title: "Ketua", Kind: contestCandidates, Method: methodPlurality, MaxChoices: 1}
+	if err := db.DB.Create(&contest).Error; err != nil {
+		t.Fatal(err)
+	}
+	candidate := models.Candidate{ElectionID: election.ID, ContestID: contest.ID, Name: "Candidate"}
+	if err := db.DB.Create(&candidate).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	expires := now.Add(5 * time.Minute)
+	nonce := "nonce-" + suffix
+	session := models.VotingSession{ElectionID: election.ID, UserID: user.ID, NonceHash: auth.HashToken(nonce), StartedAt: &now, ExpiresAt: &expires}
+	if err := db.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, table := range []string{"participations", "election_voters", "voting_sessions", "candidates", "contests"} {
+			db.DB.Exec("DELETE FROM "+table+" WHERE election_id = ?", election.ID)
+		}
+		db.DB.Delete(&election)
+		db.DB.Delete(&user)
+	})
+
+	form := url.Values{
+		"selections":   {fmt.Sprintf(`{"%d":[%d]}`, contest.ID, candidate.ID)},
+		"sessionNonce": {nonce},
+	}.Encode()
+	key := "retry-" + suffix
+
+	const attempts = 20
+	codes := make([]int, attempts)
+	bodies := make([][]byte, attempts)
+	var start, done sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < attempts; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/vote", strings.NewReader(form))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			c.Request.Header.Set("Idempotency-Key", key)
+			u, e := user, election
+			c.Set(ctxUserKey, &u)
+			c.Set(contextElection, &e)
+			start.Wait()
+			Vote(c)
+			codes[i], bodies[i] = w.Code, w.Body.Bytes()
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+
+	for i := range codes {
+		if codes[i] != http.StatusOK {
+			t.Errorf("attempt %d: status %d, body %s", i, codes[i], bodies[i])
+		} else if !bytes.Equal(bodies[i], bodies[0]) {
+			t.Errorf("attempt %d replied %s, attempt 0 replied %s", i, bodies[i], bodies[0])
+		}
+	}
+
+	var participations, voters, used int64
+	db.DB.Model(&models.Participation{}).Where("election_id = ? AND user_id = ?", election.ID, user.ID).Count(&participations)
+	db.DB.Model(&models.ElectionVoter{}).Where("election_id = ? AND user_id = ? AND has_voted = ?", election.ID, user.ID, true).Count(&voters)
+	db.DB.Model(&models.VotingSession{}).Where("id = ? AND used_at IS NOT NULL", session.ID).Count(&used)
+	if participations != 1 {
+		t.Errorf("%d participations, want 1", participations)
+	}
+	if voters != 1 {
+		t.Errorf("%d election voters marked as voted, want 1", voters)
+	}
+	if used != 1 {
+		t.Errorf("voting session used %d times, want 1", used)
+	}
+}
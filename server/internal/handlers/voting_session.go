@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// votingSessionGrace covers a ballot submitted as the countdown ends that is
+// still in flight when the session expires.
+const votingSessionGrace = 10 * time.Second
+
+var (
+	errSessionInvalid = errors.New("Sesi pemungutan suara tidak valid. Silakan masuk kembali ke bilik suara.")
+	errSessionExpired = errors.New("Waktu sesi pemungutan suara Anda telah habis. Hubungi panitia jika koneksi Anda terputus.")
+)
+
+// votingDuration is how long a voter has in the booth.
+func votingDuration(e *models.Election) time.Duration {
+	if e.VotingMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(e.VotingMinutes) * time.Minute
+}
+
+// latestVotingSession returns the voter's most recent session in an
+// election, or nil if they never entered the booth.
+func latestVotingSession(electionID, userID uint) (*models.VotingSession, error) {
+	var session models.VotingSession
+	err := db.DB.Where("election_id = ? AND user_id = ?", electionID, userID).Order("id DESC").First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// votingSessionFor finds the running session a nonce belongs to.
+func votingSessionFor(electionID, userID uint, nonce string, now time.Time) (*models.VotingSession, error) {
+	if nonce == "" {
+		return nil, errSessionInvalid
+	}
+	var session models.VotingSession
+	err := db.DB.Where("election_id = ? AND user_id = ? AND nonce_hash = ? AND used_at IS NULL AND started_at IS NOT NULL",
+		electionID, userID, auth.HashToken(nonce)).First(&session).Error
+	if err != nil {
+		return nil, errSessionInvalid
+	}
+	if now.After(session.ExpiresAt.Add(votingSessionGrace)) {
+		return nil, errSessionExpired
+	}
+	return &session, nil
+}
+
+// claimVotingSession uses up a session as the ballot is cast, so a nonce
+// cannot cast a second ballot even when two requests race.
+func claimVotingSession(tx *gorm.DB, session *models.VotingSession, now time.Time) error {
+	res := tx.Model(&models.VotingSession{}).Where("id = ? AND used_at IS NULL", session.ID).Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errSessionInvalid
+	}
+	return nil
+}
+
+// EnterVoting starts the voter's session in the booth and returns the nonce
+// their ballot must carry and the order to show them the options in.
+// Entering again, e.g. after a refresh, replaces the nonce but keeps the
+// deadline; once the deadline passes only an admin can grant a new session.
+func EnterVoting(c *gin.Context) {
+	user := currentUser(c)
+	if user.VerificationStatus != "approved" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User not verified yet"})
+		return
+	}
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	if !electionEligible(election, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Anda tidak terdaftar sebagai pemilih pada pemilihan ini"})
+		return
+	}
+	voter, err := electionVoter(db.DB, election.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+		return
+	}
+	if voter.HasVoted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pengguna sudah memilih"})
+		return
+	}
+
+	now := time.Now()
+	session, err := latestVotingSession(election.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+		return
+	}
+	switch {
+	case session == nil:
+		session = &models.VotingSession{ElectionID: election.ID, UserID: user.ID}
+	case session.UsedAt != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "Pengguna sudah memilih"})
+		return
+	case session.StartedAt != nil && !now.Before(*session.ExpiresAt):
+		c.JSON(http.StatusForbidden, gin.H{"error": errSessionExpired.Error(), "sessionExpired": true})
+		return
+	}
+
+	if session.StartedAt == nil {
+		expires := now.Add(votingDuration(election))
+		// The session never outlasts voting itself.
+		if election.EndTime != nil && expires.After(*election.EndTime) {
+			expires = *election.EndTime
+		}
+		session.StartedAt, session.ExpiresAt = &now, &expires
+	}
+	nonce, err := auth.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+		return
+	}
+	session.NonceHash = auth.HashToken(nonce)
+	// The seed outlives the nonce, so the ballot keeps its order on re-entry.
+	if session.Seed == "" {
+		if session.Seed, err = auth.RandomToken(16); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+			return
+		}
+	}
+	if err := db.DB.Save(session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+		return
+	}
+	contests, err := electionContests(election)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai sesi pemungutan suara"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessionNonce": nonce,
+		"startedAt":    session.StartedAt,
+		"expiresAt":    session.ExpiresAt,
+		"serverTime":   now,
+		"order":        ballotOrder(election, contests, session.Seed),
+	})
+}
+
+// GetServerTime lets clients correct their clock, so countdowns match the
+// deadlines the server enforces.
+func GetServerTime(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"serverTime": time.Now()})
+}
+
+// GrantVotingSession gives a voter whose session ran out without a ballot,
+// e.g. after their connection failed, a fresh one. The election decides how
+// many times that may happen per voter.
+func GrantVotingSession(c *gin.Context) {
+	election := resolveElection(c)
+	if election == nil {
+		return
+	}
+	var user models.User
+	if err := db.DB.Where("role = ?", models.RoleVoter).First(&user, c.Param("id")).Error; err != nil || !inNIMScope(currentUser(c), user.NIM) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	voter, err := electionVoter(db.DB, election.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant session"})
+		return
+	}
+	if voter.HasVoted {
+		c.JSON(http.StatusConflict, gin.H{"error": "The voter has already voted"})
+		return
+	}
+
+	session, err := latestVotingSession(election.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant session"})
+		return
+	}
+	switch {
+	case session == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "The voter has not entered the voting booth yet"})
+		return
+	case session.StartedAt == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "A new session is already waiting for the voter"})
+		return
+	case time.Now().Before(*session.ExpiresAt):
+		c.JSON(http.StatusConflict, gin.H{"error": "The voter's session is still running"})
+		return
+	}
+
+	var granted int64
+	db.DB.Model(&models.VotingSession{}).Where("election_id = ? AND user_id = ? AND granted_by IS NOT NULL", election.ID, user.ID).Count(&granted)
+	if granted >= int64(election.SessionRegrants) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The election allows no further sessions for this voter"})
+		return
+	}
+
+	admin := currentUser(c)
+	if err := db.DB.Create(&models.VotingSession{ElectionID: election.ID, UserID: user.ID, GrantedBy: &admin.ID}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant session"})
+		return
+	}
+	log.Printf("Admin %d granted user %d a new voting session in election %d", admin.ID, user.ID, election.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "New voting session granted", "remaining": int64(election.SessionRegrants) - granted - 1})
+}
+
+// MigrateVotingSessions drops the entry times voting sessions replaced.
+func MigrateVotingSessions() error {
+	return db.RunMigration("introduce_voting_sessions", func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&models.ElectionVoter{}, "vote_entry_time") {
+			return tx.Migrator().DropColumn(&models.ElectionVoter{}, "vote_entry_time")
+		}
+		return nil
+	})
+}
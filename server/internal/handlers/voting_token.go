@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"voting-backend/internal/auth"
+	"voting-backend/internal/db"
+	"voting-backend/internal/email"
+	"voting-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// reissueVotingToken replaces the voter's token, revokes their sessions and
+// emails the new token. The old token stops working immediately.
+func reissueVotingToken(user *models.User) error {
+	token := auth.NewVotingToken()
+	user.TokenHash = auth.HashVotingToken(token)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("token_hash", user.TokenHash).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, revokeTokenReissued)
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := email.SendTokenReissueEmail(user.Email, user.Name, token); err != nil {
+			log.Printf("Failed to send reissued token to %s: %v", user.Email, err)
+		} else {
+			log.Printf("Reissued token email sent to %s", user.Email)
+		}
+	}()
+	return nil
+}
+
+// ReissueTokenByAdmin issues a fresh voting token for an approved voter.
+func ReissueTokenByAdmin(c *gin.Context) {
+	var user models.User
+	if err := db.DB.Where("role = ?", models.RoleVoter).First(&user, c.Param("id")).Error; err != nil || !inNIMScope(currentUser(c), user.NIM) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.VerificationStatus != "approved" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is not approved"})
+		return
+	}
+
+	if err := reissueVotingToken(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reissue token"})
+		return
+	}
+	log.Printf("Admin %d reissued the voting token of user %d", currentUser(c).ID, user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "New token sent to " + user.Email})
+}
+
+// ReissueToken lets a voter who lost their token request a new one with their
+// email and password. It counts towards login lockouts like Login does.
+func ReissueToken(c *gin.Context) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if !checkLockout(c, req.Email) {
+		return
+	}
+
+	var user models.User
+	if err := db.DB.Where("email = ? AND role = ?", req.Email, models.RoleVoter).First(&user).Error; err != nil ||
+		user.Password == nil || bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)) != nil {
+		recordLoginFailure(c, req.Email, "token_reissue")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	recordLoginSuccess(c, req.Email, "token_reissue")
+
+	if user.VerificationStatus != "approved" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User not verified yet"})
+		return
+	}
+	if votedEverywhere(&user) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pengguna sudah memilih"})
+		return
+	}
+
+	if err := reissueVotingToken(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token baru"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token baru telah dikirim ke email Anda."})
+}
+
+// MigrateVotingTokens hashes voting tokens that earlier builds stored in
+// plaintext in users.token, then drops that column.
+func MigrateVotingTokens() error {
+	return db.RunMigration("hash_voting_tokens", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&models.User{}, "token") {
+			return nil
+		}
+
+		var rows []struct {
+			ID    uint
+			Token string
+		}
+		if err := tx.Raw("SELECT id, token FROM users WHERE token IS NOT NULL AND token <> ''").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := tx.Model(&models.User{}).Where("id = ?", r.ID).Update("token_hash", auth.HashVotingToken(r.Token)).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("hash_voting_tokens: hashed %d voting tokens", len(rows))
+		return tx.Migrator().DropColumn(&models.User{}, "token")
+	})
+}
@@ -8,36 +8,270 @@ type User struct {
 	ID                 uint    `gorm:"primaryKey"`
 	Name               string  // Added Name
 	Username           *string `gorm:"uniqueIndex"` // Added for Admin, nullable
-	Password           *string // Added for Admin, nullable
+	Password           *string `json:"-"` // Added for Admin, nullable
 	NIM                string  `gorm:"uniqueIndex"`
 	Email              string  `gorm:"uniqueIndex"`
-	Role               string  `gorm:"default:'voter'"` // 'admin' or 'voter'
-	HasVoted           bool    `gorm:"default:false"`
-	ReminderSent       bool    `gorm:"default:false"`
+	OIDCSubject        *string `gorm:"uniqueIndex" json:"-"` // Campus SSO subject once the account is linked
+	Role               string  `gorm:"default:'voter'"` // see roles.go
+	NIMScope           string  // Comma-separated NIM prefixes a scoped admin may see; empty = all
+	Disabled           bool    `gorm:"default:false"`
+	MustChangePassword bool    `gorm:"default:false"` // Set for bootstrapped admins until first password change
+	TOTPSecret         string  `json:"-"`                  // Admin second factor; set on enrollment
+	TOTPEnabled        bool    `gorm:"default:false"`
+	TOTPLastStep       int64   `json:"-"` // Last accepted TOTP time step, to refuse replays
 	ProfileImage       string
 	KTMImage           string
-	VerificationStatus string `gorm:"default:'none'"` // 'none', 'pending', 'approved', 'rejected'
-	Token              string // Added Token
-	VoteEntryTime      *time.Time // Added for 5-minute timeout check
+	VerificationStatus string `gorm:"default:'none'"` // 'none', 'unconfirmed', 'pending', 'approved', 'rejected'
+	ConfirmationSentAt *time.Time // When the email confirmation link was sent; unconfirmed rows expire from here
+	TokenHash          string     `json:"-"` // Keyed hash of the emailed voting token; the token itself is never stored
 }
 
+// Election is one vote run in this deployment, e.g. the chair election or a
+// referendum. Candidates, participations, ballots and tally shares belong to
+// one election, so several can run side by side and past ones stay on record.
+type Election struct {
+	ID                uint `gorm:"primaryKey"`
+	Name              string
+	Phase             string `gorm:"default:'draft'"` // Phase an admin moved it to; the schedule may have moved it further
+	RegistrationStart *time.Time
+	RegistrationEnd   *time.Time // Verification of registrations follows
+	StartTime         *time.Time // Voting opens
+	EndTime           *time.Time // Voting closes and counting begins
+	VotingMinutes     int        `gorm:"default:5"` // Length of a voter's session in the voting booth
+	SessionRegrants   int        // New sessions an admin may grant a voter whose session was cut off; 0 = none
+	ShuffleOptions    bool       // Each voter sees the candidates in their own random order
+	KotakKosongPlace  string     `gorm:"default:'last'"` // Where Kotak Kosong appears: 'last', 'first' or 'shuffled' in with the candidates
+	NIMPrefixes       string     // Comma-separated NIM prefixes of eligible voters; empty = every approved voter
+	NIMLength         int        // Exact NIM length; 0 = any
+	NIMPattern        string     // Regular expression a NIM must match; empty = digits only
+	CohortPosition    int        // 1-based position of the two-digit cohort year in a NIM; 0 = none
+	CohortMin         int        // Earliest cohort year admitted; 0 = no limit
+	CohortMax         int        // Latest cohort year admitted; 0 = no limit
+	PublicKey         string     // Key ballots are encrypted to (cmd/electionkey); empty = ELECTION_PUBLIC_KEY
+	Archived          bool       `gorm:"default:false"`
+	CreatedAt         time.Time
+
+	// Rules deciding the outcome
+	MinTurnout        int    // Percent of eligible voters needed for a valid election; 0 = no quorum
+	MajorityRule      string `gorm:"default:'simple'"`      // 'simple' (most votes) or 'absolute' (more than half)
+	KotakKosongPolicy string `gorm:"default:'per_contest'"` // 'per_contest', 'always', 'never' or 'single_candidate'
+	KotakKosongWins   string `gorm:"default:'void'"`        // 'void' or 'runner_up' (best candidate elected anyway)
+	WithdrawnVotes    string `gorm:"default:'void'"`        // Ballots cast for a candidate who withdrew: 'void' (count for no one) or 'count'
+}
+
+// ElectionVoter is a voter's state in one election.
+type ElectionVoter struct {
+	ID             uint `gorm:"primaryKey"`
+	ElectionID     uint `gorm:"uniqueIndex:idx_election_voter"`
+	UserID         uint `gorm:"uniqueIndex:idx_election_voter;index"`
+	HasVoted       bool `gorm:"default:false"`
+	ReminderSentAt *time.Time
+}
+
+// RollEntry is a student on an election's official voter roll (Daftar
+// Pemilih Tetap). An election with a roll admits only the NIMs on it.
+type RollEntry struct {
+	ID         uint   `gorm:"primaryKey"`
+	ElectionID uint   `gorm:"uniqueIndex:idx_roll_entry"`
+	NIM        string `gorm:"uniqueIndex:idx_roll_entry;index"`
+	Name       string
+	Cohort     string // Angkatan, e.g. 2023
+}
+
+// VotingSession is a voter's turn in the voting booth. EnterVoting starts it
+// and hands out a single-use nonce that Vote must present before ExpiresAt;
+// only the SHA-256 of the nonce is stored. A session an admin granted after
+// a failure waits unstarted until the voter enters again.
+type VotingSession struct {
+	ID         uint   `gorm:"primaryKey"`
+	ElectionID uint   `gorm:"index:idx_voting_session"`
+	UserID     uint   `gorm:"index:idx_voting_session"`
+	NonceHash  string `gorm:"index" json:"-"`
+	Seed       string `json:"-"` // Orders the options this voter sees
+	StartedAt  *time.Time
+	ExpiresAt  *time.Time
+	UsedAt     *time.Time // The ballot was cast with it
+	GrantedBy  *uint      // Admin who granted it; nil for the voter's first session
+	CreatedAt  time.Time
+}
+
+// Contest is one question on an election's ballot: an office with its
+// candidates, or a yes/no referendum. Voters answer every contest of an
+// election in a single submission.
+type Contest struct {
+	ID          uint `gorm:"primaryKey"`
+	ElectionID  uint `gorm:"index"`
+	Position    int  // Order on the ballot
+	Title       string
+	Kind        string      `gorm:"default:'candidates'"` // 'candidates' or 'referendum'
+	Method      string      `gorm:"default:'plurality'"`  // 'plurality' or 'irv' (ranked, instant-runoff)
+	MaxChoices  int         `gorm:"default:1"`            // Options a voter may pick or rank, e.g. the number of senate seats
+	KotakKosong bool        // Whether Kotak Kosong is offered
+	Candidates  []Candidate `gorm:"foreignKey:ContestID"`
+}
+
+// Candidate is an option in a contest: a single person, a pair such as a
+// chair and vice-chair, or a referendum answer.
 type Candidate struct {
-	ID       uint `gorm:"primaryKey"`
-	Name     string
-	Visi     string
-	Misi     string
-	ImageURL string
+	ID           uint `gorm:"primaryKey"`
+	ElectionID   uint `gorm:"index;uniqueIndex:idx_candidate_ballot_number"`
+	ContestID    uint `gorm:"index"`
+	BallotNumber *int `gorm:"uniqueIndex:idx_candidate_ballot_number"` // Official number on the ballot, unique in the election
+	Name         string
+	Visi         string
+	Misi         string
+	ImageURL     string
+	Members      []CandidateMember `gorm:"foreignKey:CandidateID"`
+
+	// A candidate who withdraws stays on record, and on ballots already cast,
+	// but takes no new votes.
+	WithdrawnAt      *time.Time
+	WithdrawalReason string
 }
 
-type Vote struct {
+// CandidateMember is one person standing as part of a candidate, listed in
+// Position order, e.g. the chair before the vice-chair.
+type CandidateMember struct {
 	ID          uint `gorm:"primaryKey"`
-	UserID      uint
-	CandidateID uint
-	Timestamp   time.Time
-	KTMImage    string
-	SelfImage   string
-	Status         string `gorm:"default:'pending'"` // 'pending', 'approved', 'rejected'
-	RejectionReason string // New field for rejection reason
+	CandidateID uint `gorm:"index"`
+	Position    int
+	Name        string
+	NIM         string
+	Role        string // e.g. 'Ketua', 'Wakil Ketua'
+	ImageURL    string
+}
+
+// BallotDraw records a lottery of ballot numbers. It is committed first, with
+// only SeedHash published, and completed with witness entropy supplied at the
+// draw. Each contest's candidates are numbered in the order of
+// HMAC-SHA256(Seed + ":" + Witness, candidate ID), so anyone can check the
+// result against the published hash. Draws from before commitments have
+// neither SeedHash nor Witness and were keyed by Seed alone.
+type BallotDraw struct {
+	ID          uint `gorm:"primaryKey"`
+	ElectionID  uint `gorm:"index"`
+	CommittedBy uint
+	Seed        string // Secret until the draw is completed
+	SeedHash    string // SHA-256 of Seed, published at commitment
+	Redraw      bool   // Replaces earlier numbers; completed by a second admin
+	DrawnBy     uint
+	DrawnAt     *time.Time
+	Witness     string // Entropy from the witnesses at the draw
+	Result      string // JSON list of candidate IDs, names and the numbers drawn; empty until drawn
+	CreatedAt   time.Time
+}
+
+// Participation records that a voter cast a ballot, together with the
+// evidence admins review. It never holds the choice in the clear: the choice,
+// encrypted to the election key, waits in a sealed envelope and is moved into
+// an unlinked Ballot (or spoiled) in a shuffled batch once the review is done.
+// A voter has at most one per election, enforced by the unique index
+// idx_participation_voter (see MigrateUniqueParticipations).
+type Participation struct {
+	ID              uint `gorm:"primaryKey"`
+	ElectionID      uint `gorm:"index"`
+	UserID          uint `gorm:"index"`
+	CastAt          time.Time
+	KTMImage        string
+	SelfImage       string
+	Status          string `gorm:"default:'pending'"` // 'pending', 'approved', 'rejected'
+	RejectionReason string
+	Envelope        string `json:"-"`              // Sealed receipt and encrypted choice; emptied once its ballot is released
+	IdempotencyKey  string `gorm:"index" json:"-"` // SHA-256 of the Idempotency-Key the ballot was sent with
+	Reply           string `json:"-"`              // Response to the ballot, sealed with that key for retries
+}
+
+// Ballot is an anonymous set of choices, one per contest of the election. It
+// deliberately has no user, participation or timestamp column so it cannot be
+// traced back to a voter. The choices are encrypted to the election key and
+// only written out as BallotChoice rows by the tally ceremony after the
+// election closes.
+type Ballot struct {
+	ID         string  `gorm:"primaryKey;type:varchar(36)"`
+	ElectionID uint    `gorm:"index"`
+	Ciphertext string  `json:"-"`             // Choices encrypted to the election public key
+	Decrypted  bool    `gorm:"default:false"` // Set by the tally ceremony
+	Spoiled    bool    `gorm:"default:false"` // Participation rejected; counted as Suara Hangus
+	Receipt    *string `gorm:"uniqueIndex"`   // Code handed to the voter; published on the bulletin board
+}
+
+// BallotChoice is one decrypted selection on a ballot.
+type BallotChoice struct {
+	ID          uint   `gorm:"primaryKey"`
+	BallotID    string `gorm:"type:varchar(36);index"`
+	ContestID   uint   `gorm:"index"`
+	CandidateID uint   // 0 = Kotak Kosong
+	Rank        int    // Order of the selection within the contest, from 1
+}
+
+// TallyShare is a committee member's share of the election private key,
+// submitted during the tally ceremony. Shares are sealed at rest, one per
+// admin (idx_tally_share_admin, see MigrateTallyShares), and deleted once
+// combined.
+type TallyShare struct {
+	ID          uint `gorm:"primaryKey"`
+	ElectionID  uint `gorm:"uniqueIndex:idx_tally_share"`
+	X           int  `gorm:"uniqueIndex:idx_tally_share"` // Share index printed by cmd/electionkey
+	Threshold   int
+	Share       string `json:"-"` // Sealed with auth.SealShare
+	SubmittedBy uint
+	CreatedAt   time.Time
+}
+
+// PasswordResetToken is a single-use reset link. Only the SHA-256 of the
+// emailed token is stored.
+type PasswordResetToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RecoveryCode is a single-use fallback for a lost authenticator. Only the
+// SHA-256 of the normalized code is stored.
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"index"`
+	CodeHash string `gorm:"index"`
+	UsedAt   *time.Time
+}
+
+// LoginAttempt records every credential check for auditing.
+type LoginAttempt struct {
+	ID        uint   `gorm:"primaryKey"`
+	Email     string `gorm:"index"`
+	IP        string `gorm:"index"`
+	UserAgent string
+	Endpoint  string // 'login', 'admin_login' or 'admin_mfa'
+	Success   bool
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// LoginThrottle counts consecutive failures for one account or client IP and
+// holds the lockout that follows. Stored in the database so it is shared by
+// all replicas and survives restarts.
+type LoginThrottle struct {
+	ID          uint   `gorm:"primaryKey"`
+	Key         string `gorm:"uniqueIndex"` // 'account:<email>' or 'ip:<address>'
+	Failures    int
+	LockedUntil *time.Time
+	UpdatedAt   time.Time
+}
+
+// Session is one signed-in device. Session tokens carry the session ID and
+// stop working as soon as the row is revoked or expires.
+type Session struct {
+	ID            string `gorm:"primaryKey;type:varchar(36)"`
+	UserID        uint   `gorm:"index"`
+	IssuedAt      time.Time
+	LastSeenAt    time.Time
+	ExpiresAt     time.Time `gorm:"index"`
+	IP            string
+	UserAgent     string
+	RevokedAt     *time.Time
+	RevokedReason string // 'logout', 'rejected', 'password_changed', 'admin', ...
 }
 
 type Setting struct {
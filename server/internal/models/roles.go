@@ -0,0 +1,22 @@
+package models
+
+const (
+	RoleVoter = "voter"
+	// RoleSuperAdmin keeps the legacy "admin" value so existing admin rows stay valid.
+	RoleSuperAdmin = "admin"
+	RoleVerifier   = "verifier" // reviews registrations (KTM/self photos)
+	RoleAuditor    = "auditor"  // reviews cast votes and results
+	RoleObserver   = "observer" // read-only access to the admin dashboard
+)
+
+// StaffRoles lists every role allowed into the admin dashboard.
+var StaffRoles = []string{RoleSuperAdmin, RoleVerifier, RoleAuditor, RoleObserver}
+
+func IsStaffRole(role string) bool {
+	for _, r := range StaffRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider serves discovery and a key set holding key under kid "k1",
+// and returns the provider discovered from it.
+func testProvider(t *testing.T, key *rsa.PrivateKey) *Provider {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                srv.URL,
+			AuthorizationEndpoint: srv.URL + "/authorize",
+			TokenEndpoint:         srv.URL + "/token",
+			JWKSURI:               srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	p, err := Discover(context.Background(), Config{Issuer: srv.URL, ClientID: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func segment(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// signToken signs an RS256 token with key.
+func signToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	signed := segment(t, header) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testProvider(t, key)
+
+	now := time.Now()
+	header := func() map[string]interface{} { return map[string]interface{}{"alg": "RS256", "kid": "k1"} }
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   p.config.Issuer,
+			"aud":   "client",
+			"sub":   "user-1",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce-1",
+		}
+	}
+	with := func(m map[string]interface{}, k string, v interface{}) map[string]interface{} {
+		m[k] = v
+		return m
+	}
+
+	valid := signToken(t, key, header(), claims())
+	got, err := p.Verify(context.Background(), valid, "nonce-1")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got["sub"] != "user-1" {
+		t.Errorf("sub = %v, want user-1", got["sub"])
+	}
+	listed := signToken(t, key, header(), with(claims(), "aud", []string{"other", "client"}))
+	if _, err := p.Verify(context.Background(), listed, "nonce-1"); err != nil {
+		t.Errorf("Verify with the client among several audiences: %v", err)
+	}
+
+	parts := strings.Split(valid, ".")
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"malformed", parts[0] + "." + parts[1], "nonce-1"},
+		{"alg none", segment(t, map[string]string{"alg": "none", "kid": "k1"}) + "." + parts[1] + ".", "nonce-1"},
+		{"alg HS256", segment(t, map[string]string{"alg": "HS256", "kid": "k1"}) + "." + parts[1] + "." + parts[2], "nonce-1"},
+		{"unknown kid", signToken(t, key, with(header(), "kid", "k2"), claims()), "nonce-1"},
+		{"signed by another key", signToken(t, other, header(), claims()), "nonce-1"},
+		{"tampered claims", parts[0] + "." + segment(t, with(claims(), "sub", "admin")) + "." + parts[2], "nonce-1"},
+		{"wrong issuer", signToken(t, key, header(), with(claims(), "iss", "https://evil.example")), "nonce-1"},
+		{"wrong audience", signToken(t, key, header(), with(claims(), "aud", "other")), "nonce-1"},
+		{"expired", signToken(t, key, header(), with(claims(), "exp", now.Add(-time.Hour).Unix())), "nonce-1"},
+		{"issued in the future", signToken(t, key, header(), with(claims(), "iat", now.Add(time.Hour).Unix())), "nonce-1"},
+		{"nonce mismatch", valid, "nonce-2"},
+		{"no nonce expected", signToken(t, key, header(), with(claims(), "nonce", "")), ""},
+		{"no subject", signToken(t, key, header(), with(claims(), "sub", "")), "nonce-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.Verify(context.Background(), tt.token, tt.nonce); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("Verify = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package threshold
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("invalid ballot ciphertext")
+	ErrInvalidShare      = errors.New("invalid key share")
+	ErrWrongKey          = errors.New("shares do not match the election public key")
+)
+
+const kdfLabel = "jobhms-ballot-v1"
+
+// GenerateKey creates an election key pair and splits the private key into
+// n shares with threshold k. The private key itself is not returned.
+func GenerateKey(k, n int) (*ecdh.PublicKey, []Share, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares, err := Split(priv.Bytes(), k, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv.PublicKey(), shares, nil
+}
+
+// EncodePublicKey / ParsePublicKey use standard base64.
+func EncodePublicKey(pub *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub.Bytes())
+}
+
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// String encodes a share as "<k>-<x>-<hex>" so it carries its own threshold.
+func (s Share) String() string {
+	return fmt.Sprintf("%d-%d-%s", s.Threshold, s.X, hex.EncodeToString(s.Y))
+}
+
+// ParseShare reverses Share.String.
+func ParseShare(s string) (Share, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 3 {
+		return Share{}, ErrInvalidShare
+	}
+	k, err1 := strconv.Atoi(parts[0])
+	x, err2 := strconv.Atoi(parts[1])
+	y, err3 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil || k < 2 || x < 1 || x > 255 || len(y) != 32 {
+		return Share{}, ErrInvalidShare
+	}
+	return Share{X: byte(x), Threshold: k, Y: y}, nil
+}
+
+// RecoverKey combines shares into the election private key and checks it
+// against the published public key.
+func RecoverKey(shares []Share, pub *ecdh.PublicKey) (*ecdh.PrivateKey, error) {
+	secret, err := Combine(shares)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(secret)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	if !bytes.Equal(priv.PublicKey().Bytes(), pub.Bytes()) {
+		return nil, ErrWrongKey
+	}
+	return priv, nil
+}
+
+func aeadFor(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(kdfLabel))
+	h.Write(shared)
+	h.Write(ephemeral)
+	h.Write(recipient)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals plaintext to the election public key (ephemeral X25519 +
+// AES-256-GCM). Output: base64(ephemeral public key || nonce || ciphertext).
+func Encrypt(pub *ecdh.PublicKey, plaintext []byte) (string, error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return "", err
+	}
+	aead, err := aeadFor(shared, eph.PublicKey().Bytes(), pub.Bytes())
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	out := append(eph.PublicKey().Bytes(), nonce...)
+	out = aead.Seal(out, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt.
+func Decrypt(priv *ecdh.PrivateKey, ciphertext string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(raw) < 32+12 {
+		return nil, ErrInvalidCiphertext
+	}
+	eph, err := ecdh.X25519().NewPublicKey(raw[:32])
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	shared, err := priv.ECDH(eph)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	aead, err := aeadFor(shared, raw[:32], priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	nonce := raw[32 : 32+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, raw[32+aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plain, nil
+}
@@ -0,0 +1,128 @@
+// Package threshold holds the election key cryptography: ballots are
+// encrypted to an X25519 public key whose private key is split k-of-n among
+// committee members with Shamir secret sharing over GF(2^8).
+package threshold
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must satisfy 2 <= k <= n <= 255")
+	ErrNotEnoughShares  = errors.New("not enough shares")
+	ErrDuplicateShare   = errors.New("duplicate share index")
+)
+
+// GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+var expTable, logTable [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+	expTable[255] = expTable[0]
+}
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Share is one committee member's piece of the secret. X is never zero.
+type Share struct {
+	X         byte
+	Threshold int
+	Y         []byte
+}
+
+// Split divides secret into n shares, any k of which recover it.
+func Split(secret []byte, k, n int) ([]Share, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, ErrInvalidThreshold
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Threshold: k, Y: make([]byte, len(secret))}
+	}
+
+	// One random polynomial of degree k-1 per secret byte, constant term = the byte.
+	coeffs := make([]byte, k)
+	for b, s := range secret {
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		coeffs[0] = s
+		for i := range shares {
+			x := shares[i].X
+			var y byte
+			for j := k - 1; j >= 0; j-- { // Horner
+				y = gfMul(y, x) ^ coeffs[j]
+			}
+			shares[i].Y[b] = y
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from at least Threshold shares by Lagrange
+// interpolation at zero. Wrong shares yield a wrong secret, not an error, so
+// callers must check the result (e.g. against the public key).
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 || len(shares) < shares[0].Threshold {
+		return nil, ErrNotEnoughShares
+	}
+	shares = shares[:shares[0].Threshold]
+
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if s.X == 0 || seen[s.X] || len(s.Y) != len(shares[0].Y) {
+			return nil, ErrDuplicateShare
+		}
+		seen[s.X] = true
+	}
+
+	secret := make([]byte, len(shares[0].Y))
+	for i, si := range shares {
+		// Lagrange basis polynomial for si evaluated at 0.
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(si.Y[b], basis)
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,138 @@
+package threshold
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	tests := []struct {
+		name string
+		k, n int
+		use  []int // indexes of the shares handed to Combine
+	}{
+		{"2 of 2", 2, 2, []int{0, 1}},
+		{"2 of 3, last two", 2, 3, []int{1, 2}},
+		{"3 of 5, any order", 3, 5, []int{4, 0, 2}},
+		{"3 of 5, more than needed", 3, 5, []int{3, 1, 4, 0}},
+		{"5 of 5", 5, 5, []int{0, 1, 2, 3, 4}},
+		{"2 of 255, far apart", 2, 255, []int{0, 254}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := Split(secret, tt.k, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != tt.n {
+				t.Fatalf("got %d shares, want %d", len(shares), tt.n)
+			}
+			var picked []Share
+			for _, i := range tt.use {
+				picked = append(picked, shares[i])
+			}
+			got, err := Combine(picked)
+			if err != nil {
+				t.Fatalf("Combine: %v", err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Errorf("Combine = %x, want %x", got, secret)
+			}
+		})
+	}
+}
+
+func TestSplitInvalidThreshold(t *testing.T) {
+	for _, kn := range [][2]int{{1, 3}, {0, 0}, {4, 3}, {2, 256}} {
+		if _, err := Split([]byte("secret"), kn[0], kn[1]); !errors.Is(err, ErrInvalidThreshold) {
+			t.Errorf("Split(k=%d, n=%d) = %v, want ErrInvalidThreshold", kn[0], kn[1], err)
+		}
+	}
+}
+
+func TestCombineRejects(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := shares[1]
+	short.Y = short.Y[:3]
+
+	tests := []struct {
+		name   string
+		shares []Share
+		want   error
+	}{
+		{"none", nil, ErrNotEnoughShares},
+		{"below threshold", shares[:2], ErrNotEnoughShares},
+		{"same share twice", []Share{shares[0], shares[0], shares[1]}, ErrDuplicateShare},
+		{"index zero", []Share{{X: 0, Threshold: 3, Y: shares[0].Y}, shares[1], shares[2]}, ErrDuplicateShare},
+		{"mismatched length", []Share{shares[0], short, shares[2]}, ErrDuplicateShare},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Combine(tt.shares); !errors.Is(err, tt.want) {
+				t.Errorf("Combine = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Fewer shares than the threshold say nothing about the secret: with k-1
+// shares fixed, every value of a byte of the secret is still possible.
+func TestSharesBelowThresholdDoNotFixSecret(t *testing.T) {
+	seen := map[byte]bool{}
+	for i := 0; i < 2000 && len(seen) < 256; i++ {
+		shares, err := Split([]byte{0x42}, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[shares[0].Y[0]] = true
+	}
+	if len(seen) < 250 {
+		t.Errorf("a single share took only %d distinct values", len(seen))
+	}
+}
+
+func TestKeyCeremony(t *testing.T) {
+	pub, shares, err := GenerateKey(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err = ParsePublicKey(EncodePublicKey(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := Encrypt(pub, []byte(`{"1":[2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed []Share
+	for _, s := range []Share{shares[4], shares[1], shares[3]} {
+		p, err := ParseShare(s.String())
+		if err != nil {
+			t.Fatalf("ParseShare(%s): %v", s, err)
+		}
+		parsed = append(parsed, p)
+	}
+	priv, err := RecoverKey(parsed, pub)
+	if err != nil {
+		t.Fatalf("RecoverKey: %v", err)
+	}
+	plain, err := Decrypt(priv, ciphertext)
+	if err != nil || string(plain) != `{"1":[2]}` {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+
+	// A share of another key recovers something else, which is refused.
+	_, other, err := GenerateKey(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecoverKey([]Share{parsed[0], parsed[1], other[0]}, pub); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("RecoverKey with a foreign share = %v, want ErrWrongKey", err)
+	}
+}